@@ -3,7 +3,7 @@ package main
 import (
 	"os"
 
-	"github.com/grafana/grafana-plugin-sdk-go/backend/app"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/rpatt/assetlog/pkg/plugin"
 )
@@ -11,12 +11,17 @@ import (
 func main() {
 	// Start listening to requests sent from Grafana. This call is blocking so
 	// it won't finish until Grafana shuts down the process or the plugin choose
-	// to exit by itself using os.Exit. Manage automatically manages life cycle
-	// of app instances. It accepts app instance factory as first
-	// argument. This factory will be automatically called on incoming request
-	// from Grafana to create different instances of `App` (per plugin
-	// ID).
-	if err := app.Manage("rpatt-assetlog-app", plugin.NewApp, app.ManageOpts{}); err != nil {
+	// to exit by itself using os.Exit. The same executable backs the app and
+	// its nested data source; the handler creates `App` instances for either
+	// plugin context (per plugin ID and organization).
+	backend.SetupPluginEnvironment("rpatt-assetlog-app")
+	handler := plugin.NewHandler()
+	if err := backend.Manage("rpatt-assetlog-app", backend.ServeOpts{
+		CheckHealthHandler:  handler,
+		CallResourceHandler: handler,
+		QueryDataHandler:    handler,
+		StreamHandler:       handler,
+	}); err != nil {
 		log.DefaultLogger.Error(err.Error())
 		os.Exit(1)
 	}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -20,6 +21,14 @@ type App struct {
 	storageInitErr error
 	// config stores the current plugin configuration for reuse by handlers.
 	config Config
+	// stopSweeper stops the trash sweeper, if one was started.
+	stopSweeper func()
+	// stopDispatcher stops the webhook dispatcher, if one was started, and
+	// webhookWake nudges it when an event is queued.
+	stopDispatcher func()
+	webhookWake    chan struct{}
+	// webhookClient sends webhook deliveries.
+	webhookClient *http.Client
 }
 
 type withContextHandler struct {
@@ -31,8 +40,25 @@ func (h *withContextHandler) CallResource(ctx context.Context, req *backend.Call
 	return h.inner.CallResource(ctx, req, sender)
 }
 
+// NewApp builds the App instance of an org and starts its background workers:
+// the trash sweeper and the webhook dispatcher.
 func NewApp(ctx context.Context, settings backend.AppInstanceSettings) (instancemgmt.Instance, error) {
+	a, err := newApp(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	if orgID := backend.PluginConfigFromContext(ctx).OrgID; orgID != 0 {
+		a.startTrashSweeper(orgID)
+		a.startWebhookDispatcher(orgID)
+	}
+	return a, nil
+}
+
+// newApp builds an App without background workers, as used by the data source
+// instances: the org's app instance already runs them.
+func newApp(ctx context.Context, settings backend.AppInstanceSettings) (*App, error) {
 	a := &App{}
+	a.webhookClient = newWebhookHTTPClient(func() []*net.IPNet { return a.config.WebhookAllowedNetworks })
 	if err := a.initDatabase(ctx); err != nil {
 		return nil, fmt.Errorf("initDatabase: %w", err)
 	}
@@ -100,18 +126,27 @@ func NewApp(ctx context.Context, settings backend.AppInstanceSettings) (instance
 			log.Printf("storage initialization failed: %v", err)
 			a.storageInitErr = err
 		} else {
-			a.storage = storageClient
+			a.storage = meteredStorage{storageClient}
 		}
 	}
 
 	mux := http.NewServeMux()
 	a.registerRoutes(mux)
-	a.CallResourceHandler = &withContextHandler{inner: httpadapter.New(mux)}
+	a.CallResourceHandler = &withContextHandler{inner: httpadapter.New(instrumentRoutes(mux))}
 	return a, nil
 }
 
 func (a *App) Dispose() {
+	if a.stopSweeper != nil {
+		a.stopSweeper()
+		a.stopSweeper = nil
+	}
+	if a.stopDispatcher != nil {
+		a.stopDispatcher()
+		a.stopDispatcher = nil
+	}
 	if a.db != nil {
+		databases.untrack(a.db)
 		_ = a.db.Close()
 		a.db = nil
 	}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +18,8 @@ import (
 
 type validationError struct {
 	message string
+	// fields lists each violation when the error reports several.
+	fields []AssetFieldError
 }
 
 func (e validationError) Error() string {
@@ -28,6 +31,16 @@ var (
 	errAssetFileNotFound = errors.New("asset file not found")
 )
 
+// errAssetVersionMismatch is returned when an If-Match precondition names a
+// version other than the asset's current one.
+type errAssetVersionMismatch struct {
+	current int64
+}
+
+func (e errAssetVersionMismatch) Error() string {
+	return fmt.Sprintf("asset was modified; current version is %d", e.current)
+}
+
 const (
 	defaultAssetsPageSize = 25
 	maxAssetsPageSize     = 200
@@ -35,15 +48,29 @@ const (
 
 const emptyFilterValue = "__EMPTY__"
 
+// sqliteTimestampLayout matches the format understood by SQLite's date functions.
+const sqliteTimestampLayout = "2006-01-02 15:04:05"
+
+// assetWindowEndExpr treats date-only end dates as covering the whole day, so
+// it ends at the next one. A missing or unparseable end date makes the entry a
+// point in time at its start date, matching how newAssetAnnotationFrame
+// renders it.
+const assetWindowEndExpr = `CASE WHEN julianday(end_date) IS NULL THEN start_date WHEN length(end_date) = 10 THEN date(end_date, '+1 day') ELSE end_date END`
+
 var assetFilterColumns = map[string]string{
 	"title":              "title",
 	"entry_date":         "entry_date",
 	"commissioning_date": "commissioning_date",
-	"station_name":       "station_name",
+	"station_name":       assetStationKeyExpr,
+	"station_id":         "station_id",
 	"technician":         "technician",
 	"service":            "service",
 }
 
+// assetStationKeyExpr matches filter[station_name] through the station
+// registry, so any spelling variant of a code finds the station's assets.
+const assetStationKeyExpr = `(SELECT code_key FROM stations WHERE stations.id = assets.station_id)`
+
 var assetSortColumns = map[string]string{
 	"title":              "title",
 	"entry_date":         "entry_date",
@@ -53,6 +80,8 @@ var assetSortColumns = map[string]string{
 	"service":            "service",
 	"start_date":         "start_date",
 	"end_date":           "end_date",
+	// distance is resolved against AssetListOptions.Near at query time.
+	distanceSortKey: distanceSortKey,
 }
 
 type AssetSortDirection string
@@ -74,6 +103,8 @@ type AssetRecord struct {
 	EntryDate         string      `json:"entry_date"`
 	CommissioningDate string      `json:"commissioning_date"`
 	StationName       string      `json:"station_name"`
+	StationID         int64       `json:"station_id,omitempty"`
+	ParentID          int64       `json:"parent_id,omitempty"`
 	Technician        string      `json:"technician"`
 	StartDate         string      `json:"start_date"`
 	EndDate           string      `json:"end_date"`
@@ -84,9 +115,27 @@ type AssetRecord struct {
 	Pitch             float64     `json:"pitch"`
 	Roll              float64     `json:"roll"`
 	Attachments       []AssetFile `json:"attachments"`
+	Tags              []string    `json:"tags"`
 	ImageURLs         []string    `json:"image_urls,omitempty"`
 	CreatedAt         string      `json:"created_at"`
 	UpdatedAt         string      `json:"updated_at"`
+	// CustomFields holds the values of the org's custom fields by name.
+	CustomFields map[string]interface{} `json:"custom_fields"`
+	// Version increases with every update and is exposed as the ETag.
+	Version int64 `json:"version"`
+	// Search is only populated when the record was returned by a full-text query.
+	Search *AssetSearchMatch `json:"search,omitempty"`
+	// DistanceKm is only populated when the list was filtered with near.
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+}
+
+// AssetSearchMatch describes how a record matched a full-text query. Lower
+// ranks are better matches. Snippet is plain, unescaped text; Highlights holds
+// the [start, end) UTF-16 offsets of the matched terms within it.
+type AssetSearchMatch struct {
+	Rank       float64  `json:"rank"`
+	Snippet    string   `json:"snippet"`
+	Highlights [][2]int `json:"highlights"`
 }
 
 type AssetFile struct {
@@ -105,6 +154,7 @@ type AssetPayload struct {
 	EntryDate         string   `json:"entry_date"`
 	CommissioningDate string   `json:"commissioning_date"`
 	StationName       string   `json:"station_name"`
+	StationID         int64    `json:"station_id,omitempty"`
 	Technician        string   `json:"technician"`
 	StartDate         string   `json:"start_date"`
 	EndDate           string   `json:"end_date"`
@@ -114,16 +164,27 @@ type AssetPayload struct {
 	Longitude         float64  `json:"longitude"`
 	Pitch             float64  `json:"pitch"`
 	Roll              float64  `json:"roll"`
+	// CustomFields is left nil when a client omits it; updates then keep the
+	// stored values.
+	CustomFields map[string]interface{} `json:"custom_fields,omitempty"`
+	// Tags are tag names, registered on first use. Like CustomFields, nil
+	// keeps the stored tags on update.
+	Tags []string `json:"tags"`
+	// ParentID nests the asset under another asset of the org; 0 makes it a
+	// root and nil keeps the stored parent on update.
+	ParentID *int64 `json:"parent_id,omitempty"`
 }
 
-func (p *AssetPayload) normalize() {
+// normalize trims the payload and converts its dates to their stored form,
+// reading dates without an offset in loc. Dates that do not parse are kept
+// for validate to reject.
+func (p *AssetPayload) normalize(loc *time.Location) {
 	p.Title = strings.TrimSpace(p.Title)
-	p.EntryDate = strings.TrimSpace(p.EntryDate)
-	p.CommissioningDate = strings.TrimSpace(p.CommissioningDate)
 	p.StationName = strings.TrimSpace(p.StationName)
 	p.Technician = strings.TrimSpace(p.Technician)
-	p.StartDate = strings.TrimSpace(p.StartDate)
-	p.EndDate = strings.TrimSpace(p.EndDate)
+	for _, value := range p.dateFields() {
+		*value, _ = normalizeAssetDate(strings.TrimSpace(*value), loc)
+	}
 	p.Service = strings.TrimSpace(p.Service)
 	if p.Staff == nil {
 		p.Staff = []string{}
@@ -132,34 +193,64 @@ func (p *AssetPayload) normalize() {
 			p.Staff[i] = strings.TrimSpace(member)
 		}
 	}
+	p.Tags = normalizeTags(p.Tags)
+	for name, value := range p.CustomFields {
+		switch v := value.(type) {
+		case nil:
+			delete(p.CustomFields, name)
+		case string:
+			p.CustomFields[name] = strings.TrimSpace(v)
+		}
+	}
 }
 
-func (p AssetPayload) validate() error {
-	switch {
-	case p.Title == "":
-		return validationError{message: "title is required"}
-	case p.EntryDate == "":
-		return validationError{message: "entry_date is required"}
-	case p.CommissioningDate == "":
-		return validationError{message: "commissioning_date is required"}
-	case p.StationName == "":
-		return validationError{message: "station_name is required"}
-	case p.Technician == "":
-		return validationError{message: "technician is required"}
-	case p.StartDate == "":
-		return validationError{message: "start_date is required"}
-	case p.EndDate == "":
-		return validationError{message: "end_date is required"}
+// validate checks the payload, including its custom field values against the
+// org's field definitions and the cross-field rules of v. Every violation is
+// reported in the returned validationError.
+func (p AssetPayload) validate(fields assetFieldDefinitions, v *assetValidator) error {
+	var violations []AssetFieldError
+	required := []struct {
+		field   string
+		missing bool
+	}{
+		{"title", p.Title == ""},
+		{"entry_date", p.EntryDate == ""},
+		{"commissioning_date", p.CommissioningDate == ""},
+		{"station_name", p.StationName == "" && p.StationID == 0},
+		{"technician", p.Technician == ""},
+		{"start_date", p.StartDate == ""},
+		{"end_date", p.EndDate == ""},
+	}
+	for _, r := range required {
+		if r.missing {
+			violations = append(violations, AssetFieldError{Field: r.field, Rule: "required", Message: r.field + " is required"})
+		}
+	}
+
+	dates := p.dateFields()
+	for _, column := range assetDateColumns {
+		if value := *dates[column]; value != "" && !isStoredAssetDate(value) {
+			violations = append(violations, AssetFieldError{
+				Field:   column,
+				Rule:    "format",
+				Message: fmt.Sprintf("%s must be an RFC 3339 date (YYYY-MM-DD) or date-time, got %q", column, value),
+			})
+		}
 	}
 
 	if math.Abs(p.Latitude) > 90 {
-		return validationError{message: "latitude must be between -90 and 90"}
+		violations = append(violations, AssetFieldError{Field: "latitude", Rule: "range", Message: "latitude must be between -90 and 90"})
 	}
 	if math.Abs(p.Longitude) > 180 {
-		return validationError{message: "longitude must be between -180 and 180"}
+		violations = append(violations, AssetFieldError{Field: "longitude", Rule: "range", Message: "longitude must be between -180 and 180"})
 	}
 
-	return nil
+	violations = append(violations, tagErrors(p.Tags)...)
+	violations = append(violations, fields.valueErrors(p.CustomFields)...)
+	if v != nil {
+		violations = append(violations, v.check(p)...)
+	}
+	return fieldValidationError(violations)
 }
 
 type AssetListOptions struct {
@@ -167,6 +258,34 @@ type AssetListOptions struct {
 	PageSize int
 	Filters  map[string][]string
 	Sort     *AssetListSort
+	// Search is a free-text query matched against the assets_fts index.
+	Search string
+	BBox   *AssetBoundingBox
+	Near   *AssetNearFilter
+	// Window limits results to entries whose start_date/end_date range overlaps it.
+	Window *AssetTimeWindow
+	// IncludeDescendants widens the filters to the descendants of the assets
+	// they match.
+	IncludeDescendants bool
+	// Cursor continues the list after, or before, the row it marks instead of
+	// reading Page; see AssetListResult.NextCursor.
+	Cursor string
+	// SkipCount leaves out the total count, page count and tag counts, which
+	// each scan every matching asset.
+	SkipCount bool
+	// fields resolves custom.<name> filter and sort keys; it is loaded by
+	// listAssets and streamAssets.
+	fields assetFieldDefinitions
+	// location renders the dates of the listed records. It is set by
+	// listAssets and streamAssets.
+	location *time.Location
+}
+
+// AssetTimeWindow is an inclusive time range matched against the start_date and
+// end_date columns.
+type AssetTimeWindow struct {
+	From time.Time
+	To   time.Time
 }
 
 type AssetListResult struct {
@@ -177,6 +296,26 @@ type AssetListResult struct {
 	PageCount      int
 	AppliedFilters map[string][]string
 	AppliedSort    *AssetListSort
+	AppliedSearch  string
+	AppliedBBox    *AssetBoundingBox
+	AppliedNear    *AssetNearFilter
+	// AppliedIncludeDescendants reports whether the filters matched the
+	// descendants of the assets they select.
+	AppliedIncludeDescendants bool
+	// TagCounts counts the tags over all matching assets.
+	TagCounts []AssetTagCount
+	// NextCursor and PrevCursor list the rows after and before this page; they
+	// are empty at either end of the list, and always for near queries.
+	NextCursor string
+	PrevCursor string
+}
+
+// assetPage is one page of matching assets. total is only set when counted.
+type assetPage struct {
+	records    []AssetRecord
+	total      int64
+	page       int
+	next, prev string
 }
 
 func (opts *AssetListOptions) normalize() {
@@ -196,6 +335,10 @@ func (opts *AssetListOptions) normalize() {
 		key := strings.TrimSpace(opts.Sort.Key)
 		direction := strings.ToLower(strings.TrimSpace(string(opts.Sort.Direction)))
 		column, ok := assetSortColumns[key]
+		if strings.HasPrefix(key, customFieldPrefix) {
+			// resolved against the field definitions by buildAssetQuery
+			ok = true
+		}
 		if !ok {
 			opts.Sort = nil
 		} else {
@@ -215,137 +358,314 @@ func (opts *AssetListOptions) normalize() {
 	}
 }
 
-func (a *App) listAssets(ctx context.Context, orgID int64, opts AssetListOptions) (AssetListResult, error) {
-	opts.normalize()
+// assetSelectColumns lists the assets columns scanned by assetQuery.scan.
+const assetSelectColumns = `id, title, entry_date, commissioning_date, station_name, technician, start_date, end_date, service, staff, latitude, longitude, pitch, roll, created_at, updated_at, version, custom_fields, station_id, parent_id`
+
+// assetQuery holds the SQL fragments shared by the paginated list and the
+// streaming exports so both honour the same filters and sort.
+type assetQuery struct {
+	from           string
+	where          string
+	order          string
+	columns        string
+	args           []interface{}
+	search         string
+	near           *AssetNearFilter
+	location       *time.Location
+	appliedFilters map[string][]string
+	// sortExpr orders the rows before id DESC; sortKey names the sort in
+	// cursors.
+	sortExpr string
+	sortDesc bool
+	sortKey  string
+}
 
-	whereParts := []string{"org_id = ?"}
+// buildAssetQuery translates normalized list options into SQL fragments.
+func buildAssetQuery(orgID int64, opts AssetListOptions) (assetQuery, error) {
+	if opts.Sort != nil && opts.Sort.Key == distanceSortKey && opts.Near == nil {
+		return assetQuery{}, validationError{message: "sorting by distance requires near"}
+	}
+
+	loc := opts.location
+	if loc == nil {
+		loc = time.UTC
+	}
+	whereParts := []string{"org_id = ?", "deleted_at IS NULL"}
 	args := []interface{}{orgID}
 	appliedFilters := make(map[string][]string)
+	filterStart, filterArgsStart := len(whereParts), len(args)
 
 	for key, values := range opts.Filters {
-		column, ok := assetFilterColumns[key]
-		if !ok {
+		name, op := splitFilterKey(key)
+		if name == tagFilterKey {
+			clause, clauseArgs, applied, err := buildTagFilter(op, values)
+			if err != nil {
+				return assetQuery{}, err
+			}
+			if clause == "" {
+				continue
+			}
+			whereParts = append(whereParts, clause)
+			args = append(args, clauseArgs...)
+			appliedFilters[key] = applied
 			continue
 		}
-		includeEmpty := false
-		cleaned := make([]string, 0, len(values))
-		for _, raw := range values {
-			trimmed := strings.TrimSpace(raw)
-			if trimmed == "" {
-				continue
+		if strings.HasPrefix(name, customFieldPrefix) {
+			clause, clauseArgs, applied, err := opts.fields.buildFilter(name, op, values, loc)
+			if err != nil {
+				return assetQuery{}, err
 			}
-			if trimmed == emptyFilterValue {
-				includeEmpty = true
+			if clause == "" {
 				continue
 			}
-			cleaned = append(cleaned, trimmed)
-		}
-		if len(cleaned) == 0 && !includeEmpty {
+			whereParts = append(whereParts, clause)
+			args = append(args, clauseArgs...)
+			appliedFilters[key] = applied
 			continue
 		}
-		sort.Strings(cleaned)
-		conditions := make([]string, 0, 2)
-		switch len(cleaned) {
-		case 0:
-			// no explicit values
-		case 1:
-			conditions = append(conditions, fmt.Sprintf("%s = ?", column))
-			args = append(args, cleaned[0])
-		default:
-			placeholders := strings.TrimRight(strings.Repeat("?,", len(cleaned)), ",")
-			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", column, placeholders))
-			for _, value := range cleaned {
-				args = append(args, value)
+		if op != "" {
+			clause, clauseArgs, applied, err := buildOperatorFilter(name, op, values, loc)
+			if err != nil {
+				return assetQuery{}, err
 			}
+			whereParts = append(whereParts, clause)
+			args = append(args, clauseArgs...)
+			appliedFilters[operatorFilterKey(name, op)] = applied
+			continue
 		}
-		if includeEmpty {
-			conditions = append(conditions, fmt.Sprintf("(%s IS NULL OR %s = '')", column, column))
+		if _, ok := assetDirectoryFilters[key]; ok {
+			clause, clauseArgs, applied, err := buildDirectoryFilter(key, values)
+			if err != nil {
+				return assetQuery{}, err
+			}
+			if clause == "" {
+				continue
+			}
+			whereParts = append(whereParts, clause)
+			args = append(args, clauseArgs...)
+			appliedFilters[key] = applied
+			continue
 		}
-		if len(conditions) == 0 {
+		column, ok := assetFilterColumns[key]
+		if !ok {
 			continue
 		}
-		if len(conditions) == 1 {
-			whereParts = append(whereParts, conditions[0])
-		} else {
-			whereParts = append(whereParts, fmt.Sprintf("(%s)", strings.Join(conditions, " OR ")))
+		var convert func(string) (interface{}, error)
+		switch key {
+		case "station_name":
+			convert = func(value string) (interface{}, error) { return stationCodeKey(value), nil }
+		case "station_id":
+			convert = func(value string) (interface{}, error) {
+				id, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, validationError{message: fmt.Sprintf("filter station_id expects an id, got %q", value)}
+				}
+				return id, nil
+			}
 		}
-		applied := append([]string{}, cleaned...)
-		if includeEmpty {
-			applied = append(applied, emptyFilterValue)
+		clause, clauseArgs, applied, err := buildEqualityFilter(column, values, convert)
+		if err != nil {
+			return assetQuery{}, err
+		}
+		if clause == "" {
+			continue
 		}
+		whereParts = append(whereParts, clause)
+		args = append(args, clauseArgs...)
 		appliedFilters[key] = applied
 	}
 
+	if opts.IncludeDescendants && len(whereParts) > filterStart {
+		filters := strings.Join(whereParts[filterStart:], " AND ")
+		filterArgs := append([]interface{}{orgID}, args[filterArgsStart:]...)
+		whereParts = append(whereParts[:filterStart], includesDescendantsCondition(filters))
+		args = append(args[:filterArgsStart], filterArgs...)
+	}
+
+	locationParts, locationArgs := opts.locationConditions()
+	whereParts = append(whereParts, locationParts...)
+	args = append(args, locationArgs...)
+
+	if opts.Window != nil {
+		starts, startsArgs := assetDateComparison("start_date", "<=", opts.Window.To, loc)
+		ends, endsArgs := assetDateComparison(assetWindowEndExpr, ">=", opts.Window.From, loc)
+		whereParts = append(whereParts, starts, ends)
+		args = append(append(args, startsArgs...), endsArgs...)
+	}
+
 	whereClause := strings.Join(whereParts, " AND ")
 
-	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM assets WHERE %s`, whereClause)
-	var total int64
-	if err := a.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
-		return AssetListResult{}, err
+	q := assetQuery{
+		from:           "assets",
+		where:          whereClause,
+		columns:        assetSelectColumns,
+		near:           opts.Near,
+		location:       opts.location,
+		appliedFilters: appliedFilters,
+	}
+	if match := buildSearchMatchExpression(opts.Search); match != "" {
+		q.from = fmt.Sprintf("assets JOIN (%s) AS search ON search.asset_id = assets.id", assetSearchSubquery)
+		q.columns += ", search.search_rank, search.search_snippet"
+		args = append([]interface{}{match}, args...)
+		q.search = strings.TrimSpace(opts.Search)
 	}
+	q.args = args
 
-	page := opts.Page
-	if total > 0 {
-		maxPage := int((total + int64(opts.PageSize) - 1) / int64(opts.PageSize))
-		if page > maxPage {
-			page = maxPage
+	switch {
+	case opts.Sort != nil:
+		q.sortExpr = opts.Sort.column
+		q.sortDesc = opts.Sort.Direction == sortDirectionDesc
+		q.sortKey = opts.Sort.Key + ":" + string(opts.Sort.Direction)
+		if opts.Sort.Key == distanceSortKey {
+			q.sortExpr = opts.Near.distanceExpression()
 		}
-	} else {
-		page = 1
+		if strings.HasPrefix(opts.Sort.Key, customFieldPrefix) {
+			def, err := opts.fields.resolve(opts.Sort.Key)
+			if err != nil {
+				return assetQuery{}, err
+			}
+			q.sortExpr = def.sortExpression()
+		}
+	case q.search != "":
+		q.sortExpr, q.sortKey = "search.search_rank", "search_rank:asc"
+	default:
+		q.sortExpr, q.sortDesc, q.sortKey = "entry_date", true, "entry_date:desc"
 	}
+	q.order = q.orderBy(false)
 
-	offset := (page - 1) * opts.PageSize
-	queryArgs := append(append([]interface{}{}, args...), opts.PageSize, offset)
+	return q, nil
+}
 
-	orderParts := make([]string, 0, 2)
-	if opts.Sort != nil {
-		direction := "ASC"
-		if opts.Sort.Direction == sortDirectionDesc {
-			direction = "DESC"
-		}
-		orderParts = append(orderParts, fmt.Sprintf("%s %s", opts.Sort.column, direction))
-	} else {
-		orderParts = append(orderParts, "entry_date DESC")
+// orderBy is the ORDER BY clause of q: the sort expression followed by id
+// DESC, or the exact reverse of that.
+func (q assetQuery) orderBy(reverse bool) string {
+	sortDirection, idDirection := "ASC", "DESC"
+	if q.sortDesc != reverse {
+		sortDirection = "DESC"
+	}
+	if reverse {
+		idDirection = "ASC"
+	}
+	return fmt.Sprintf("%s %s, id %s", q.sortExpr, sortDirection, idDirection)
+}
+
+// scan reads a row selected with q.columns followed by any extra columns.
+func (q assetQuery) scan(rows *sql.Rows, extra ...interface{}) (AssetRecord, error) {
+	var record AssetRecord
+	var service sqlNullString
+	var staffRaw sqlNullString
+	var customRaw string
+	var stationID, parentID sql.NullInt64
+	dest := []interface{}{&record.ID, &record.Title, &record.EntryDate, &record.CommissioningDate, &record.StationName, &record.Technician, &record.StartDate, &record.EndDate, &service, &staffRaw, &record.Latitude, &record.Longitude, &record.Pitch, &record.Roll, &record.CreatedAt, &record.UpdatedAt, &record.Version, &customRaw, &stationID, &parentID}
+	var match AssetSearchMatch
+	if q.search != "" {
+		dest = append(dest, &match.Rank, &match.Snippet)
+	}
+	dest = append(dest, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return AssetRecord{}, err
+	}
+	if q.search != "" {
+		match.Snippet, match.Highlights = parseSearchSnippet(match.Snippet)
+		record.Search = &match
+	}
+	if q.near != nil {
+		distance := haversineKm(q.near.Latitude, q.near.Longitude, record.Latitude, record.Longitude)
+		record.DistanceKm = &distance
+	}
+	if service.Valid {
+		record.Service = service.String
+	}
+	if staffRaw.Valid && strings.TrimSpace(staffRaw.String) != "" {
+		_ = json.Unmarshal([]byte(staffRaw.String), &record.Staff)
+	}
+	if record.Staff == nil {
+		record.Staff = []string{}
 	}
-	orderParts = append(orderParts, "id DESC")
-	orderClause := strings.Join(orderParts, ", ")
+	record.CustomFields = decodeCustomFields(customRaw)
+	record.StationID = stationID.Int64
+	record.ParentID = parentID.Int64
+	record.localizeDates(q.location)
+	return record, nil
+}
 
-	rows, err := a.db.QueryContext(ctx, fmt.Sprintf(`SELECT id, title, entry_date, commissioning_date, station_name, technician, start_date, end_date, service, staff, latitude, longitude, pitch, roll, created_at, updated_at FROM assets WHERE %s ORDER BY %s LIMIT ? OFFSET ?`, whereClause, orderClause), queryArgs...)
+// decodeCustomFields reads the custom_fields column, which always holds a JSON
+// object.
+func decodeCustomFields(raw string) map[string]interface{} {
+	values := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(raw), &values); err != nil || values == nil {
+		return map[string]interface{}{}
+	}
+	return values
+}
+
+// encodeCustomFields is the custom_fields column value of a payload.
+func encodeCustomFields(values map[string]interface{}) (string, error) {
+	if values == nil {
+		return "{}", nil
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("marshal custom fields: %w", err)
+	}
+	return string(raw), nil
+}
+
+func (a *App) listAssets(ctx context.Context, orgID int64, opts AssetListOptions) (AssetListResult, error) {
+	opts.normalize()
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
+	if err != nil {
+		return AssetListResult{}, err
+	}
+	opts.fields = fields
+	opts.location = a.location()
+	q, err := buildAssetQuery(orgID, opts)
 	if err != nil {
 		return AssetListResult{}, err
 	}
-	defer rows.Close()
 
-	var assets []AssetRecord
-	var assetIDs []int64
-	for rows.Next() {
-		var record AssetRecord
-		var service sqlNullString
-		var staffRaw sqlNullString
-		if err := rows.Scan(&record.ID, &record.Title, &record.EntryDate, &record.CommissioningDate, &record.StationName, &record.Technician, &record.StartDate, &record.EndDate, &service, &staffRaw, &record.Latitude, &record.Longitude, &record.Pitch, &record.Roll, &record.CreatedAt, &record.UpdatedAt); err != nil {
-			return AssetListResult{}, err
-		}
-		if service.Valid {
-			record.Service = service.String
-		}
-		if staffRaw.Valid && strings.TrimSpace(staffRaw.String) != "" {
-			_ = json.Unmarshal([]byte(staffRaw.String), &record.Staff)
-		} else {
-			record.Staff = []string{}
-		}
-		assets = append(assets, record)
-		assetIDs = append(assetIDs, record.ID)
+	var result assetPage
+	switch {
+	case q.near != nil && opts.Cursor != "":
+		return AssetListResult{}, validationError{message: "cursor cannot be combined with near, use page instead"}
+	case q.near != nil:
+		result, err = a.queryNearAssetsPage(ctx, q, opts)
+	case opts.Cursor != "":
+		result, err = a.queryAssetsAtCursor(ctx, q, opts)
+	default:
+		result, err = a.queryAssetsPage(ctx, q, opts)
 	}
-	if err := rows.Err(); err != nil {
+	if err != nil {
 		return AssetListResult{}, err
 	}
+	assets := result.records
+
+	assetIDs := make([]int64, 0, len(assets))
+	for _, asset := range assets {
+		assetIDs = append(assetIDs, asset.ID)
+	}
 
-	attachments, err := a.loadAssetFiles(ctx, orgID, assetIDs)
+	attachments, err := a.loadAssetFiles(ctx, a.db, orgID, assetIDs)
 	if err != nil {
 		return AssetListResult{}, err
 	}
+	tags, err := loadAssetTags(ctx, a.db, assetIDs)
+	if err != nil {
+		return AssetListResult{}, err
+	}
+	var tagCounts []AssetTagCount
+	if !opts.SkipCount {
+		tagCounts, err = a.countAssetTags(ctx, q)
+		if err != nil {
+			return AssetListResult{}, err
+		}
+	}
 
 	for i, asset := range assets {
+		assets[i].Tags = tags[asset.ID]
+		if assets[i].Tags == nil {
+			assets[i].Tags = []string{}
+		}
 		if files, ok := attachments[asset.ID]; ok {
 			assets[i].Attachments = files
 			assets[i].ImageURLs = collectFileNames(files)
@@ -359,8 +679,8 @@ func (a *App) listAssets(ctx context.Context, orgID int64, opts AssetListOptions
 	}
 
 	pageCount := 0
-	if total > 0 {
-		pageCount = int((total + int64(opts.PageSize) - 1) / int64(opts.PageSize))
+	if result.total > 0 {
+		pageCount = int((result.total + int64(opts.PageSize) - 1) / int64(opts.PageSize))
 	}
 
 	var appliedSort *AssetListSort
@@ -369,21 +689,153 @@ func (a *App) listAssets(ctx context.Context, orgID int64, opts AssetListOptions
 	}
 
 	return AssetListResult{
-		Records:        assets,
-		TotalCount:     total,
-		Page:           page,
-		PageSize:       opts.PageSize,
-		PageCount:      pageCount,
-		AppliedFilters: appliedFilters,
-		AppliedSort:    appliedSort,
+		Records:                   assets,
+		TotalCount:                result.total,
+		Page:                      result.page,
+		PageSize:                  opts.PageSize,
+		PageCount:                 pageCount,
+		AppliedFilters:            q.appliedFilters,
+		AppliedSort:               appliedSort,
+		AppliedSearch:             q.search,
+		AppliedBBox:               opts.BBox,
+		AppliedNear:               opts.Near,
+		TagCounts:                 tagCounts,
+		AppliedIncludeDescendants: opts.IncludeDescendants && len(q.appliedFilters) > 0,
+		NextCursor:                result.next,
+		PrevCursor:                result.prev,
 	}, nil
 }
 
+// clampPage limits page to the last page of total results.
+func clampPage(page, pageSize int, total int64) int {
+	if total == 0 {
+		return 1
+	}
+	if maxPage := int((total + int64(pageSize) - 1) / int64(pageSize)); page > maxPage {
+		return maxPage
+	}
+	return page
+}
+
+// queryAssetsPage reads a page by offset. The page is only clamped to the
+// last one when the matches are counted.
+func (a *App) queryAssetsPage(ctx context.Context, q assetQuery, opts AssetListOptions) (assetPage, error) {
+	result := assetPage{page: opts.Page}
+	if !opts.SkipCount {
+		total, err := a.countAssets(ctx, q)
+		if err != nil {
+			return assetPage{}, err
+		}
+		result.total = total
+		result.page = clampPage(opts.Page, opts.PageSize, total)
+	}
+
+	offset := (result.page - 1) * opts.PageSize
+	records, values, err := a.queryAssetRows(ctx, q, "", nil, false, opts.PageSize+1, offset)
+	if err != nil {
+		return assetPage{}, err
+	}
+	if len(records) > opts.PageSize {
+		records, values = records[:opts.PageSize], values[:opts.PageSize]
+		result.next = q.cursor(records[len(records)-1].ID, values[len(values)-1], false)
+	}
+	if offset > 0 && len(records) > 0 {
+		result.prev = q.cursor(records[0].ID, values[0], true)
+	}
+	result.records = records
+	return result, nil
+}
+
+func (a *App) countAssets(ctx context.Context, q assetQuery) (int64, error) {
+	var total int64
+	err := a.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s`, q.from, q.where), q.args...).Scan(&total)
+	return total, err
+}
+
+// queryAssetRows reads up to limit rows of q, narrowed by the extra condition
+// and read in reverse order if asked, with the value each row sorts by.
+func (a *App) queryAssetRows(ctx context.Context, q assetQuery, condition string, conditionArgs []interface{}, reverse bool, limit, offset int) ([]AssetRecord, []interface{}, error) {
+	where := q.where
+	if condition != "" {
+		where += " AND " + condition
+	}
+	args := append(append(append([]interface{}{}, q.args...), conditionArgs...), limit, offset)
+	rows, err := a.db.QueryContext(ctx, fmt.Sprintf(`SELECT %s, %s FROM %s WHERE %s ORDER BY %s LIMIT ? OFFSET ?`, q.columns, q.sortExpr, q.from, where, q.orderBy(reverse)), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var records []AssetRecord
+	var values []interface{}
+	for rows.Next() {
+		var value interface{}
+		record, err := q.scan(rows, &value)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, record)
+		values = append(values, value)
+	}
+	return records, values, rows.Err()
+}
+
+// queryNearAssetsPage reads every candidate passing the index prefilter, keeps
+// those within the haversine radius and paginates in Go, so counts and
+// distance_km always agree with radiusKm.
+func (a *App) queryNearAssetsPage(ctx context.Context, q assetQuery, opts AssetListOptions) (assetPage, error) {
+	rows, err := a.db.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM %s WHERE %s ORDER BY %s`, q.columns, q.from, q.where, q.order), q.args...)
+	if err != nil {
+		return assetPage{}, err
+	}
+	defer rows.Close()
+
+	var matches []AssetRecord
+	for rows.Next() {
+		record, err := q.scan(rows)
+		if err != nil {
+			return assetPage{}, err
+		}
+		if *record.DistanceKm <= q.near.RadiusKm {
+			matches = append(matches, record)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return assetPage{}, err
+	}
+
+	if opts.Sort != nil && opts.Sort.Key == distanceSortKey {
+		desc := opts.Sort.Direction == sortDirectionDesc
+		sort.SliceStable(matches, func(i, j int) bool {
+			if desc {
+				return *matches[i].DistanceKm > *matches[j].DistanceKm
+			}
+			return *matches[i].DistanceKm < *matches[j].DistanceKm
+		})
+	}
+
+	total := int64(len(matches))
+	page := clampPage(opts.Page, opts.PageSize, total)
+	start := (page - 1) * opts.PageSize
+	end := start + opts.PageSize
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return assetPage{records: matches[start:end], total: total, page: page}, nil
+}
+
 func (a *App) getAsset(ctx context.Context, orgID, assetID int64) (AssetRecord, error) {
+	return a.readAsset(ctx, a.db, orgID, assetID)
+}
+
+// readAsset is getAsset through q, so that a transaction sees its own writes.
+func (a *App) readAsset(ctx context.Context, q sqlRowQueryer, orgID, assetID int64) (AssetRecord, error) {
 	var record AssetRecord
 	var service sqlNullString
 	var staffRaw sqlNullString
-	err := a.db.QueryRowContext(ctx, `SELECT id, title, entry_date, commissioning_date, station_name, technician, start_date, end_date, service, staff, latitude, longitude, pitch, roll, created_at, updated_at FROM assets WHERE org_id = ? AND id = ?`, orgID, assetID).Scan(
+	var customRaw string
+	var stationID, parentID sql.NullInt64
+	err := q.QueryRowContext(ctx, `SELECT `+assetSelectColumns+` FROM assets WHERE org_id = ? AND id = ? AND deleted_at IS NULL`, orgID, assetID).Scan(
 		&record.ID,
 		&record.Title,
 		&record.EntryDate,
@@ -400,6 +852,10 @@ func (a *App) getAsset(ctx context.Context, orgID, assetID int64) (AssetRecord,
 		&record.Roll,
 		&record.CreatedAt,
 		&record.UpdatedAt,
+		&record.Version,
+		&customRaw,
+		&stationID,
+		&parentID,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return AssetRecord{}, errAssetNotFound
@@ -415,8 +871,12 @@ func (a *App) getAsset(ctx context.Context, orgID, assetID int64) (AssetRecord,
 	} else {
 		record.Staff = []string{}
 	}
+	record.CustomFields = decodeCustomFields(customRaw)
+	record.StationID = stationID.Int64
+	record.ParentID = parentID.Int64
+	record.localizeDates(a.location())
 
-	files, err := a.loadAssetFiles(ctx, orgID, []int64{record.ID})
+	files, err := a.loadAssetFiles(ctx, q, orgID, []int64{record.ID})
 	if err != nil {
 		return AssetRecord{}, err
 	}
@@ -427,32 +887,120 @@ func (a *App) getAsset(ctx context.Context, orgID, assetID int64) (AssetRecord,
 		record.Attachments = []AssetFile{}
 		record.ImageURLs = []string{}
 	}
+	tags, err := loadAssetTags(ctx, q, []int64{record.ID})
+	if err != nil {
+		return AssetRecord{}, err
+	}
+	record.Tags = tags[record.ID]
+	if record.Tags == nil {
+		record.Tags = []string{}
+	}
 	return record, nil
 }
 
+// sqlExecer is satisfied by both *sql.DB and *sql.Tx.
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// sqlQueryer is satisfied by both *sql.DB and *sql.Tx.
+type sqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type sqlExecQueryer interface {
+	sqlExecer
+	sqlQueryer
+}
+
+// sqlRowQueryer is satisfied by both *sql.DB and *sql.Tx.
+type sqlRowQueryer interface {
+	sqlQueryer
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in a transaction that is committed when fn returns nil.
+func (a *App) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (a *App) createAsset(ctx context.Context, orgID int64, payload AssetPayload) (AssetRecord, error) {
-	payload.normalize()
-	if err := payload.validate(); err != nil {
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
+	if err != nil {
+		return AssetRecord{}, err
+	}
+	payload.normalize(a.location())
+	if err := payload.validate(fields, a.validator(attachmentsNotChecked)); err != nil {
+		return AssetRecord{}, err
+	}
+
+	var assetID int64
+	err = a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		var err error
+		if assetID, err = insertAsset(ctx, tx, orgID, payload); err != nil {
+			return err
+		}
+		if err := recordAssetRevision(ctx, tx, orgID, assetID, revisionActionCreate); err != nil {
+			return err
+		}
+		return a.addAssetEvent(ctx, tx, events, webhookEventAssetCreated, assetID)
+	})
+	if err != nil {
 		return AssetRecord{}, err
 	}
 
+	return a.getAsset(ctx, orgID, assetID)
+}
+
+// insertAsset writes an already normalized and validated payload and returns
+// the new asset id.
+func insertAsset(ctx context.Context, exec sqlExecQueryer, orgID int64, payload AssetPayload) (int64, error) {
+	return insertAssetWithID(ctx, exec, orgID, 0, payload)
+}
+
+// insertAssetWithID is insertAsset with an explicit id, used to bring back a
+// deleted asset under its original id. An id of 0 lets SQLite assign one.
+func insertAssetWithID(ctx context.Context, exec sqlExecQueryer, orgID, assetID int64, payload AssetPayload) (int64, error) {
+	var idValue interface{}
+	if assetID > 0 {
+		idValue = assetID
+	}
+	if err := resolveAssetStation(ctx, exec, orgID, &payload); err != nil {
+		return 0, err
+	}
+
 	staffJSON, err := json.Marshal(payload.Staff)
 	if err != nil {
-		return AssetRecord{}, fmt.Errorf("marshal staff: %w", err)
+		return 0, fmt.Errorf("marshal staff: %w", err)
 	}
 
 	var serviceValue interface{}
 	if payload.Service != "" {
 		serviceValue = payload.Service
 	}
+	customJSON, err := encodeCustomFields(payload.CustomFields)
+	if err != nil {
+		return 0, err
+	}
 
 	now := time.Now().UTC().Format(time.RFC3339Nano)
-	res, err := a.db.ExecContext(ctx, `INSERT INTO assets (org_id, title, entry_date, commissioning_date, station_name, technician, start_date, end_date, service, staff, latitude, longitude, pitch, roll, images, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	res, err := exec.ExecContext(ctx, `INSERT INTO assets (id, org_id, title, entry_date, commissioning_date, station_name, station_id, technician, start_date, end_date, service, staff, latitude, longitude, pitch, roll, custom_fields, images, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		idValue,
 		orgID,
 		payload.Title,
 		payload.EntryDate,
 		payload.CommissioningDate,
 		payload.StationName,
+		payload.StationID,
 		payload.Technician,
 		payload.StartDate,
 		payload.EndDate,
@@ -462,15 +1010,60 @@ func (a *App) createAsset(ctx context.Context, orgID int64, payload AssetPayload
 		payload.Longitude,
 		payload.Pitch,
 		payload.Roll,
+		customJSON,
 		"[]",
 		now,
 		now,
 	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := syncAssetTags(ctx, exec, orgID, id, payload.Tags); err != nil {
+		return 0, err
+	}
+	if payload.ParentID != nil && *payload.ParentID != 0 {
+		if err := setAssetParent(ctx, exec, orgID, id, *payload.ParentID); err != nil {
+			return 0, err
+		}
+	}
+	return id, syncAssetPeople(ctx, exec, orgID, id, payload)
+}
+
+// updateAsset overwrites an asset. A non-zero ifVersion makes the update
+// conditional on the asset still being at that version.
+func (a *App) updateAsset(ctx context.Context, orgID, assetID int64, payload AssetPayload, ifVersion int64) (AssetRecord, error) {
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
 	if err != nil {
 		return AssetRecord{}, err
 	}
+	payload.normalize(a.location())
 
-	assetID, err := res.LastInsertId()
+	err = a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		if err := checkAssetVersion(ctx, tx, orgID, assetID, ifVersion); err != nil {
+			return err
+		}
+		if err := carryCustomFields(ctx, tx, orgID, assetID, &payload); err != nil {
+			return err
+		}
+		validator, err := a.assetValidatorFor(ctx, tx, orgID, assetID)
+		if err != nil {
+			return err
+		}
+		if err := payload.validate(fields, validator); err != nil {
+			return err
+		}
+		if err := updateAssetRow(ctx, tx, orgID, assetID, payload); err != nil {
+			return err
+		}
+		if err := recordAssetRevision(ctx, tx, orgID, assetID, revisionActionUpdate); err != nil {
+			return err
+		}
+		return a.addAssetEvent(ctx, tx, events, webhookEventAssetUpdated, assetID)
+	})
 	if err != nil {
 		return AssetRecord{}, err
 	}
@@ -478,27 +1071,32 @@ func (a *App) createAsset(ctx context.Context, orgID int64, payload AssetPayload
 	return a.getAsset(ctx, orgID, assetID)
 }
 
-func (a *App) updateAsset(ctx context.Context, orgID, assetID int64, payload AssetPayload) (AssetRecord, error) {
-	payload.normalize()
-	if err := payload.validate(); err != nil {
-		return AssetRecord{}, err
+// updateAssetRow overwrites an asset with an already normalized and validated
+// payload.
+func updateAssetRow(ctx context.Context, exec sqlExecQueryer, orgID, assetID int64, payload AssetPayload) error {
+	if err := resolveAssetStation(ctx, exec, orgID, &payload); err != nil {
+		return err
 	}
-
 	staffJSON, err := json.Marshal(payload.Staff)
 	if err != nil {
-		return AssetRecord{}, fmt.Errorf("marshal staff: %w", err)
+		return fmt.Errorf("marshal staff: %w", err)
 	}
 
 	var serviceValue interface{}
 	if payload.Service != "" {
 		serviceValue = payload.Service
 	}
+	customJSON, err := encodeCustomFields(payload.CustomFields)
+	if err != nil {
+		return err
+	}
 
-	res, err := a.db.ExecContext(ctx, `UPDATE assets SET title = ?, entry_date = ?, commissioning_date = ?, station_name = ?, technician = ?, start_date = ?, end_date = ?, service = ?, staff = ?, latitude = ?, longitude = ?, pitch = ?, roll = ?, images = '[]', updated_at = CURRENT_TIMESTAMP WHERE org_id = ? AND id = ?`,
+	res, err := exec.ExecContext(ctx, `UPDATE assets SET title = ?, entry_date = ?, commissioning_date = ?, station_name = ?, station_id = ?, technician = ?, start_date = ?, end_date = ?, service = ?, staff = ?, latitude = ?, longitude = ?, pitch = ?, roll = ?, custom_fields = ?, images = '[]', updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE org_id = ? AND id = ? AND deleted_at IS NULL`,
 		payload.Title,
 		payload.EntryDate,
 		payload.CommissioningDate,
 		payload.StationName,
+		payload.StationID,
 		payload.Technician,
 		payload.StartDate,
 		payload.EndDate,
@@ -508,39 +1106,99 @@ func (a *App) updateAsset(ctx context.Context, orgID, assetID int64, payload Ass
 		payload.Longitude,
 		payload.Pitch,
 		payload.Roll,
+		customJSON,
 		orgID,
 		assetID,
 	)
 	if err != nil {
-		return AssetRecord{}, err
+		return err
 	}
 	affected, err := res.RowsAffected()
 	if err != nil {
-		return AssetRecord{}, err
+		return err
 	}
 	if affected == 0 {
-		return AssetRecord{}, errAssetNotFound
+		return errAssetNotFound
 	}
-
-	return a.getAsset(ctx, orgID, assetID)
+	if err := syncAssetTags(ctx, exec, orgID, assetID, payload.Tags); err != nil {
+		return err
+	}
+	if payload.ParentID != nil {
+		if err := setAssetParent(ctx, exec, orgID, assetID, *payload.ParentID); err != nil {
+			return err
+		}
+	}
+	return syncAssetPeople(ctx, exec, orgID, assetID, payload)
 }
 
-func (a *App) deleteAsset(ctx context.Context, orgID, assetID int64) error {
-	if a.storageConfigured() {
-		attachments, err := a.loadAssetFiles(ctx, orgID, []int64{assetID})
-		if err != nil {
+// deleteAsset moves an asset to the trash. Its row, attachments and stored
+// objects are kept until the trash sweeper purges them.
+func (a *App) deleteAsset(ctx context.Context, orgID, assetID int64, ifVersion int64) error {
+	return a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		if err := checkAssetVersion(ctx, tx, orgID, assetID, ifVersion); err != nil {
 			return err
 		}
-		if files, ok := attachments[assetID]; ok {
-			for _, file := range files {
-				if err := a.storage.Delete(ctx, file.storageKey); err != nil {
-					return err
-				}
-			}
+		if err := trashAssetRow(ctx, tx, orgID, assetID); err != nil {
+			return err
+		}
+		if err := recordAssetRevision(ctx, tx, orgID, assetID, revisionActionDelete); err != nil {
+			return err
+		}
+		return events.add(ctx, tx, webhookEventAssetDeleted, map[string]int64{"id": assetID})
+	})
+}
+
+// checkAssetVersion fails with errAssetVersionMismatch unless the live asset
+// is at the expected version. An expected version of 0 skips the check.
+func checkAssetVersion(ctx context.Context, q sqlQueryer, orgID, assetID, expected int64) error {
+	if expected == 0 {
+		return nil
+	}
+	rows, err := q.QueryContext(ctx, `SELECT version FROM assets WHERE org_id = ? AND id = ? AND deleted_at IS NULL`, orgID, assetID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
 		}
+		return errAssetNotFound
+	}
+	var current int64
+	if err := rows.Scan(&current); err != nil {
+		return err
+	}
+	if current != expected {
+		return errAssetVersionMismatch{current: current}
+	}
+	return nil
+}
+
+// trashAssetRow marks a live asset as deleted.
+func trashAssetRow(ctx context.Context, exec sqlExecer, orgID, assetID int64) error {
+	res, err := exec.ExecContext(ctx, `UPDATE assets SET deleted_at = ?, version = version + 1 WHERE org_id = ? AND id = ? AND deleted_at IS NULL`,
+		time.Now().UTC().Format(sqliteTimestampLayout),
+		orgID,
+		assetID,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
 	}
+	if affected == 0 {
+		return errAssetNotFound
+	}
+	return nil
+}
 
-	res, err := a.db.ExecContext(ctx, `DELETE FROM assets WHERE org_id = ? AND id = ?`, orgID, assetID)
+// deleteAssetRow removes an asset row for good; attachment rows cascade.
+// Stored objects are left to the caller.
+func deleteAssetRow(ctx context.Context, exec sqlExecer, orgID, assetID int64) error {
+	res, err := exec.ExecContext(ctx, `DELETE FROM assets WHERE org_id = ? AND id = ?`, orgID, assetID)
 	if err != nil {
 		return err
 	}
@@ -559,27 +1217,34 @@ func (a *App) insertAssetFile(ctx context.Context, orgID, assetID int64, fileNam
 	if strings.TrimSpace(contentType) != "" {
 		contentValue = contentType
 	}
-	res, err := a.db.ExecContext(ctx, `INSERT INTO asset_files (asset_id, org_id, file_name, content_type, object_name) VALUES (?, ?, ?, ?, ?)`,
-		assetID,
-		orgID,
-		fileName,
-		contentValue,
-		storageKey,
-	)
-	if err != nil {
-		return AssetFile{}, err
-	}
-	fileID, err := res.LastInsertId()
-	if err != nil {
-		return AssetFile{}, err
-	}
-	return a.getAssetFile(ctx, orgID, assetID, fileID)
+	var file AssetFile
+	err := a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		res, err := tx.ExecContext(ctx, `INSERT INTO asset_files (asset_id, org_id, file_name, content_type, object_name) VALUES (?, ?, ?, ?, ?)`,
+			assetID,
+			orgID,
+			fileName,
+			contentValue,
+			storageKey,
+		)
+		if err != nil {
+			return err
+		}
+		fileID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if file, err = a.getAssetFile(ctx, tx, orgID, assetID, fileID); err != nil {
+			return err
+		}
+		return events.add(ctx, tx, webhookEventFileUploaded, file)
+	})
+	return file, err
 }
 
-func (a *App) getAssetFile(ctx context.Context, orgID, assetID, fileID int64) (AssetFile, error) {
+func (a *App) getAssetFile(ctx context.Context, q sqlRowQueryer, orgID, assetID, fileID int64) (AssetFile, error) {
 	var file AssetFile
 	var contentType sqlNullString
-	err := a.db.QueryRowContext(ctx, `SELECT id, asset_id, file_name, content_type, object_name, created_at, updated_at FROM asset_files WHERE org_id = ? AND asset_id = ? AND id = ?`,
+	err := q.QueryRowContext(ctx, `SELECT id, asset_id, file_name, content_type, object_name, created_at, updated_at FROM asset_files WHERE org_id = ? AND asset_id = ? AND id = ?`,
 		orgID,
 		assetID,
 		fileID,
@@ -598,7 +1263,7 @@ func (a *App) getAssetFile(ctx context.Context, orgID, assetID, fileID int64) (A
 }
 
 func (a *App) deleteAssetFile(ctx context.Context, orgID, assetID, fileID int64) error {
-	file, err := a.getAssetFile(ctx, orgID, assetID, fileID)
+	file, err := a.getAssetFile(ctx, a.db, orgID, assetID, fileID)
 	if err != nil {
 		return err
 	}
@@ -607,18 +1272,20 @@ func (a *App) deleteAssetFile(ctx context.Context, orgID, assetID, fileID int64)
 			return err
 		}
 	}
-	res, err := a.db.ExecContext(ctx, `DELETE FROM asset_files WHERE org_id = ? AND asset_id = ? AND id = ?`, orgID, assetID, fileID)
-	if err != nil {
-		return err
-	}
-	affected, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if affected == 0 {
-		return errAssetFileNotFound
-	}
-	return nil
+	return a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM asset_files WHERE org_id = ? AND asset_id = ? AND id = ?`, orgID, assetID, fileID)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return errAssetFileNotFound
+		}
+		return events.add(ctx, tx, webhookEventFileDeleted, map[string]int64{"id": fileID, "asset_id": assetID})
+	})
 }
 
 func (a *App) generateStorageKey(orgID, assetID int64, fileName string) string {
@@ -639,14 +1306,14 @@ func (a *App) generateStorageKey(orgID, assetID int64, fileName string) string {
 
 func (a *App) ensureAssetExists(ctx context.Context, orgID, assetID int64) error {
 	var id int64
-	err := a.db.QueryRowContext(ctx, `SELECT id FROM assets WHERE org_id = ? AND id = ?`, orgID, assetID).Scan(&id)
+	err := a.db.QueryRowContext(ctx, `SELECT id FROM assets WHERE org_id = ? AND id = ? AND deleted_at IS NULL`, orgID, assetID).Scan(&id)
 	if errors.Is(err, sql.ErrNoRows) {
 		return errAssetNotFound
 	}
 	return err
 }
 
-func (a *App) loadAssetFiles(ctx context.Context, orgID int64, assetIDs []int64) (map[int64][]AssetFile, error) {
+func (a *App) loadAssetFiles(ctx context.Context, q sqlQueryer, orgID int64, assetIDs []int64) (map[int64][]AssetFile, error) {
 	result := make(map[int64][]AssetFile)
 	if len(assetIDs) == 0 {
 		return result, nil
@@ -661,7 +1328,7 @@ func (a *App) loadAssetFiles(ctx context.Context, orgID int64, assetIDs []int64)
 	}
 
 	query := fmt.Sprintf(`SELECT id, asset_id, file_name, content_type, object_name, created_at, updated_at FROM asset_files WHERE org_id = ? AND asset_id IN (%s) ORDER BY id`, strings.Join(placeholders, ","))
-	rows, err := a.db.QueryContext(ctx, query, args...)
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
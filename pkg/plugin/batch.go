@@ -0,0 +1,295 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	maxBatchOperations  = 500
+	maxBatchPayloadSize = 8 << 20
+
+	batchOpCreate = "create"
+	batchOpUpdate = "update"
+	batchOpDelete = "delete"
+)
+
+type assetBatchRequest struct {
+	Operations []assetBatchOperation `json:"operations"`
+}
+
+type assetBatchOperation struct {
+	Op string `json:"op"`
+	ID int64  `json:"id,omitempty"`
+	// Version, when set, makes an update or delete conditional like If-Match.
+	Version int64         `json:"version,omitempty"`
+	Asset   *AssetPayload `json:"asset,omitempty"`
+}
+
+// assetBatchResult reports one operation. When the batch is rolled back, the
+// failing operation carries its error and every other one has status 424.
+type assetBatchResult struct {
+	Index  int          `json:"index"`
+	Op     string       `json:"op"`
+	ID     int64        `json:"id,omitempty"`
+	Status int          `json:"status"`
+	Asset  *AssetRecord `json:"asset,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	// Errors lists each violation when the operation failed validation.
+	Errors []AssetFieldError `json:"errors,omitempty"`
+}
+
+type assetBatchResponse struct {
+	Committed bool               `json:"committed"`
+	Results   []assetBatchResult `json:"results"`
+}
+
+// errBatchOperation marks the operation that aborted a batch.
+type errBatchOperation struct {
+	index int
+	err   error
+}
+
+func (e errBatchOperation) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.index, e.err)
+}
+
+func (e errBatchOperation) Unwrap() error {
+	return e.err
+}
+
+func (a *App) handleAssetBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	ops, err := decodeAssetBatch(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	fields, err := listAssetFieldDefinitions(r.Context(), a.db, orgID)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	results := make([]assetBatchResult, len(ops))
+	failed := false
+	for i := range ops {
+		err := prepareBatchOperation(&ops[i], fields, a.validator(attachmentsNotChecked))
+		results[i] = assetBatchResult{Index: i, Op: ops[i].Op, ID: ops[i].ID}
+		if err != nil {
+			results[i].Status = http.StatusBadRequest
+			results[i].setError(err)
+			failed = true
+		}
+	}
+	if failed {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"data": rejectedBatch(results)})
+		return
+	}
+
+	if err := a.applyAssetBatch(r.Context(), orgID, ops, fields, results); err != nil {
+		var opErr errBatchOperation
+		if !errors.As(err, &opErr) || batchErrorStatus(opErr.err) == http.StatusInternalServerError {
+			writeHTTPError(w, err)
+			return
+		}
+		results[opErr.index].Status = batchErrorStatus(opErr.err)
+		results[opErr.index].setError(opErr.err)
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"data": rejectedBatch(results)})
+		return
+	}
+
+	for i := range results {
+		if results[i].Op == batchOpDelete {
+			continue
+		}
+		record, err := a.getAsset(r.Context(), orgID, results[i].ID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		results[i].Asset = &record
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": assetBatchResponse{Committed: true, Results: results}})
+}
+
+func decodeAssetBatch(r *http.Request) ([]assetBatchOperation, error) {
+	defer func() {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	var req assetBatchRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxBatchPayloadSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return nil, validationError{message: "invalid JSON payload: " + err.Error()}
+	}
+	switch {
+	case len(req.Operations) == 0:
+		return nil, validationError{message: "operations must not be empty"}
+	case len(req.Operations) > maxBatchOperations:
+		return nil, validationError{message: fmt.Sprintf("at most %d operations are allowed per batch", maxBatchOperations)}
+	}
+	return req.Operations, nil
+}
+
+// prepareBatchOperation checks an operation's shape and normalizes and
+// validates its payload before anything is written. Updates are validated
+// again when applied, against the stored asset.
+func prepareBatchOperation(op *assetBatchOperation, fields assetFieldDefinitions, v *assetValidator) error {
+	op.Op = strings.ToLower(strings.TrimSpace(op.Op))
+	switch op.Op {
+	case batchOpCreate:
+		if op.ID != 0 || op.Version != 0 {
+			return validationError{message: "create must not set id or version"}
+		}
+	case batchOpUpdate, batchOpDelete:
+		if op.ID <= 0 {
+			return validationError{message: op.Op + " requires a positive id"}
+		}
+	default:
+		return validationError{message: fmt.Sprintf("unknown op %q", op.Op)}
+	}
+
+	if op.Op == batchOpDelete {
+		if op.Asset != nil {
+			return validationError{message: "delete must not include an asset"}
+		}
+		return nil
+	}
+	if op.Asset == nil {
+		return validationError{message: op.Op + " requires an asset"}
+	}
+	op.Asset.normalize(v.loc)
+	if op.Op == batchOpUpdate && op.Asset.CustomFields == nil {
+		// The stored custom fields are carried over and checked when the
+		// operation is applied.
+		return op.Asset.validate(nil, v)
+	}
+	return op.Asset.validate(fields, v)
+}
+
+// applyAssetBatch runs prepared operations in a single transaction and fills
+// in the ids and statuses of results. Deletes move assets to the trash like
+// DELETE /assets/{id}.
+func (a *App) applyAssetBatch(ctx context.Context, orgID int64, ops []assetBatchOperation, fields assetFieldDefinitions, results []assetBatchResult) error {
+	return a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		return a.applyAssetBatchOps(ctx, tx, events, orgID, ops, fields, results)
+	})
+}
+
+// applyAssetBatchOps runs the operations of a batch in tx and records their
+// events.
+func (a *App) applyAssetBatchOps(ctx context.Context, tx *sql.Tx, events *assetEvents, orgID int64, ops []assetBatchOperation, fields assetFieldDefinitions, results []assetBatchResult) error {
+	for i, op := range ops {
+		switch op.Op {
+		case batchOpCreate:
+			id, err := insertAsset(ctx, tx, orgID, *op.Asset)
+			if err == nil {
+				err = recordAssetRevision(ctx, tx, orgID, id, revisionActionCreate)
+			}
+			if err == nil {
+				err = a.addAssetEvent(ctx, tx, events, webhookEventAssetCreated, id)
+			}
+			if err != nil {
+				return errBatchOperation{index: i, err: err}
+			}
+			results[i].ID = id
+			results[i].Status = http.StatusCreated
+		case batchOpUpdate:
+			err := checkAssetVersion(ctx, tx, orgID, op.ID, op.Version)
+			if err == nil && op.Asset.CustomFields == nil {
+				err = carryCustomFields(ctx, tx, orgID, op.ID, op.Asset)
+			}
+			var validator *assetValidator
+			if err == nil {
+				validator, err = a.assetValidatorFor(ctx, tx, orgID, op.ID)
+			}
+			if err == nil {
+				err = op.Asset.validate(fields, validator)
+			}
+			if err == nil {
+				err = updateAssetRow(ctx, tx, orgID, op.ID, *op.Asset)
+			}
+			if err == nil {
+				err = recordAssetRevision(ctx, tx, orgID, op.ID, revisionActionUpdate)
+			}
+			if err == nil {
+				err = a.addAssetEvent(ctx, tx, events, webhookEventAssetUpdated, op.ID)
+			}
+			if err != nil {
+				return errBatchOperation{index: i, err: err}
+			}
+			results[i].Status = http.StatusOK
+		case batchOpDelete:
+			err := checkAssetVersion(ctx, tx, orgID, op.ID, op.Version)
+			if err == nil {
+				err = trashAssetRow(ctx, tx, orgID, op.ID)
+			}
+			if err == nil {
+				err = recordAssetRevision(ctx, tx, orgID, op.ID, revisionActionDelete)
+			}
+			if err == nil {
+				err = events.add(ctx, tx, webhookEventAssetDeleted, map[string]int64{"id": op.ID})
+			}
+			if err != nil {
+				return errBatchOperation{index: i, err: err}
+			}
+			results[i].Status = http.StatusNoContent
+		}
+	}
+	return nil
+}
+
+// rejectedBatch marks every operation without an error as not applied.
+func rejectedBatch(results []assetBatchResult) assetBatchResponse {
+	for i := range results {
+		if results[i].Error == "" {
+			results[i].Status = http.StatusFailedDependency
+		}
+		if results[i].Op == batchOpCreate {
+			results[i].ID = 0
+		}
+	}
+	return assetBatchResponse{Committed: false, Results: results}
+}
+
+// setError records why the operation failed.
+func (r *assetBatchResult) setError(err error) {
+	r.Error = err.Error()
+	var valErr validationError
+	if errors.As(err, &valErr) {
+		r.Errors = valErr.fields
+	}
+}
+
+func batchErrorStatus(err error) int {
+	var valErr validationError
+	switch {
+	case errors.As(err, &valErr):
+		return http.StatusBadRequest
+	case errors.Is(err, errAssetNotFound):
+		return http.StatusNotFound
+	case errors.As(err, new(errAssetVersionMismatch)):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
+	}
+}
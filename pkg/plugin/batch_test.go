@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const batchTestAsset = `{"title":"Batch tower","entry_date":"2025-01-02","commissioning_date":"2025-01-03","station_name":"BT-1","technician":"J. Doe","start_date":"2025-01-04","end_date":"2025-01-05"}`
+
+func callAssetBatch(t *testing.T, app *App, body string) (int, assetBatchResponse) {
+	t.Helper()
+	var r mockCallResourceResponseSender
+	err := app.CallResource(context.Background(), &backend.CallResourceRequest{
+		Method:        http.MethodPost,
+		Path:          "assets/batch",
+		Body:          []byte(body),
+		PluginContext: backend.PluginContext{OrgID: 1},
+	}, &r)
+	if err != nil {
+		t.Fatalf("CallResource error: %v", err)
+	}
+	var out struct {
+		Data assetBatchResponse `json:"data"`
+	}
+	if r.response.Status == http.StatusOK || r.response.Status == http.StatusUnprocessableEntity {
+		if err := json.Unmarshal(r.response.Body, &out); err != nil {
+			t.Fatalf("decode batch response: %v (%s)", err, r.response.Body)
+		}
+	}
+	return r.response.Status, out.Data
+}
+
+func TestAssetBatchAppliesAllOperations(t *testing.T) {
+	app := newTestApp(t)
+	before := countOrgAssets(t, app, 1)
+
+	status, resp := callAssetBatch(t, app, `{"operations":[
+		{"op":"create","asset":`+batchTestAsset+`},
+		{"op":"update","id":1,"asset":`+batchTestAsset+`},
+		{"op":"delete","id":2}
+	]}`)
+	if status != http.StatusOK || !resp.Committed || len(resp.Results) != 3 {
+		t.Fatalf("expected committed batch, got %d %+v", status, resp)
+	}
+	for i, exp := range []int{http.StatusCreated, http.StatusOK, http.StatusNoContent} {
+		if resp.Results[i].Status != exp {
+			t.Fatalf("result %d: expected status %d, got %+v", i, exp, resp.Results[i])
+		}
+	}
+	if resp.Results[0].ID == 0 || resp.Results[0].Asset == nil || resp.Results[1].Asset.StationName != "BT-1" {
+		t.Fatalf("expected created and updated assets in results, got %+v", resp.Results)
+	}
+	if got := countOrgAssets(t, app, 1); got != before {
+		t.Fatalf("expected %d assets after create+delete, got %d", before, got)
+	}
+	if _, err := app.getAsset(context.Background(), 1, 2); err != errAssetNotFound {
+		t.Fatalf("expected asset 2 to be deleted, got %v", err)
+	}
+}
+
+func TestAssetBatchIsAllOrNothing(t *testing.T) {
+	app := newTestApp(t)
+	before := countOrgAssets(t, app, 1)
+	original, err := app.getAsset(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("get asset: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		body      string
+		failIndex int
+		failCode  int
+	}{
+		{
+			name:      "missing asset rolls back earlier writes",
+			body:      `{"operations":[{"op":"update","id":1,"asset":` + batchTestAsset + `},{"op":"create","asset":` + batchTestAsset + `},{"op":"delete","id":9999}]}`,
+			failIndex: 2,
+			failCode:  http.StatusNotFound,
+		},
+		{
+			name:      "invalid payload rejects batch before writing",
+			body:      `{"operations":[{"op":"create","asset":` + batchTestAsset + `},{"op":"update","id":1,"asset":{"title":""}}]}`,
+			failIndex: 1,
+			failCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "unknown op",
+			body:      `{"operations":[{"op":"upsert","id":1}]}`,
+			failIndex: 0,
+			failCode:  http.StatusBadRequest,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, resp := callAssetBatch(t, app, tc.body)
+			if status != http.StatusUnprocessableEntity || resp.Committed {
+				t.Fatalf("expected rejected batch, got %d %+v", status, resp)
+			}
+			for i, result := range resp.Results {
+				switch {
+				case i == tc.failIndex && (result.Status != tc.failCode || result.Error == ""):
+					t.Fatalf("expected operation %d to fail with %d, got %+v", i, tc.failCode, result)
+				case i != tc.failIndex && result.Status != http.StatusFailedDependency:
+					t.Fatalf("expected operation %d to be marked not applied, got %+v", i, result)
+				}
+			}
+			if got := countOrgAssets(t, app, 1); got != before {
+				t.Fatalf("rejected batch changed asset count: %d -> %d", before, got)
+			}
+			current, err := app.getAsset(context.Background(), 1, 1)
+			if err != nil || current.StationName != original.StationName {
+				t.Fatalf("rejected batch changed asset 1: %+v (%v)", current, err)
+			}
+		})
+	}
+
+	for _, body := range []string{`{"operations":[]}`, `{"ops":[]}`} {
+		if status, _ := callAssetBatch(t, app, body); status != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, status)
+		}
+	}
+}
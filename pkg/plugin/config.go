@@ -3,7 +3,12 @@ package plugin
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"net"
 	"strings"
+	"time"
+	// Org time zones must load on hosts without a system tzdata.
+	_ "time/tzdata"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 )
@@ -12,6 +17,9 @@ const (
 	defaultMaxUploadSizeMB = int64(25)
 	maxAllowedUploadSizeMB = int64(5120)
 	bytesInMegabyte        = int64(1024 * 1024)
+
+	defaultTrashRetentionDays = 30
+	maxTrashRetentionDays     = 3650
 )
 
 type StorageConfig struct {
@@ -26,6 +34,17 @@ type Config struct {
 	APIURL  string
 	APIKey  string
 	Storage StorageConfig
+	// TrashRetention is how long deleted assets stay restorable before the
+	// sweeper purges them.
+	TrashRetention time.Duration
+	// TimeZone reads asset dates sent without an offset and renders stored
+	// ones. It defaults to UTC.
+	TimeZone *time.Location
+	// ValidationRules are checked on every asset write.
+	ValidationRules AssetValidationRules
+	// WebhookAllowedNetworks lets webhooks reach loopback, link-local and
+	// private addresses inside these networks. Those are refused otherwise.
+	WebhookAllowedNetworks []*net.IPNet
 }
 
 func parseConfig(settings backend.AppInstanceSettings) (Config, error) {
@@ -34,6 +53,9 @@ func parseConfig(settings backend.AppInstanceSettings) (Config, error) {
 			MaxUploadSizeMB:    defaultMaxUploadSizeMB,
 			MaxUploadSizeBytes: defaultMaxUploadSizeMB * bytesInMegabyte,
 		},
+		TrashRetention:  defaultTrashRetentionDays * 24 * time.Hour,
+		TimeZone:        time.UTC,
+		ValidationRules: defaultAssetValidationRules(),
 	}
 
 	if len(settings.JSONData) > 0 {
@@ -42,6 +64,13 @@ func parseConfig(settings backend.AppInstanceSettings) (Config, error) {
 			BucketName     string `json:"bucketName"`
 			ObjectPrefix   string `json:"objectPrefix"`
 			MaxUploadSizeM int64  `json:"maxUploadSizeMb"`
+			TrashRetention int64  `json:"trashRetentionDays"`
+			TimeZone       string `json:"timeZone"`
+			// WebhookAllowedNetworks holds CIDRs or single addresses.
+			WebhookAllowedNetworks []string `json:"webhookAllowedNetworks"`
+			// Rules are decoded separately so a bad rule set falls back
+			// to the defaults instead of failing the whole config.
+			ValidationRules json.RawMessage `json:"validationRules"`
 		}
 		if err := json.Unmarshal(settings.JSONData, &raw); err != nil {
 			return cfg, fmt.Errorf("decode jsonData: %w", err)
@@ -59,6 +88,26 @@ func parseConfig(settings backend.AppInstanceSettings) (Config, error) {
 			cfg.Storage.MaxUploadSizeMB = sizeMB
 			cfg.Storage.MaxUploadSizeBytes = sizeMB * bytesInMegabyte
 		}
+
+		if raw.TrashRetention > 0 {
+			days := raw.TrashRetention
+			if days > maxTrashRetentionDays {
+				days = maxTrashRetentionDays
+			}
+			cfg.TrashRetention = time.Duration(days) * 24 * time.Hour
+		}
+
+		if name := strings.TrimSpace(raw.TimeZone); name != "" {
+			loc, err := time.LoadLocation(name)
+			if err != nil {
+				log.Printf("unknown timeZone %q, using UTC: %v", name, err)
+			} else {
+				cfg.TimeZone = loc
+			}
+		}
+
+		cfg.ValidationRules = parseAssetValidationRules(raw.ValidationRules)
+		cfg.WebhookAllowedNetworks = parseNetworks(raw.WebhookAllowedNetworks)
 	}
 
 	if settings.DecryptedSecureJSONData != nil {
@@ -79,3 +128,35 @@ func (s StorageConfig) IsFullyConfigured() bool {
 	}
 	return s.Bucket != "" && len(s.ServiceAccountJSON) > 0
 }
+
+// parseNetworks reads CIDRs and single addresses, skipping the ones that do
+// not parse.
+func parseNetworks(values []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				log.Printf("invalid webhookAllowedNetworks entry %q, skipping", value)
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			log.Printf("invalid webhookAllowedNetworks entry %q, skipping: %v", value, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
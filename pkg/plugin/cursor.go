@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+var errInvalidCursor = validationError{message: "invalid cursor, request the list again without it"}
+
+// assetCursor marks a row of the asset list for keyset pagination by the value
+// the row sorts by and its id. Cursors are opaque to clients: base64url JSON.
+type assetCursor struct {
+	// Sort is the assetQuery.sortKey the cursor was issued for.
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	ID    int64       `json:"id"`
+	// Before asks for the rows preceding the marked row instead of those
+	// following it.
+	Before bool `json:"b,omitempty"`
+}
+
+// cursor returns the cursor of the row id sorting by value.
+func (q assetQuery) cursor(id int64, value interface{}, before bool) string {
+	if raw, ok := value.([]byte); ok {
+		value = string(raw)
+	}
+	encoded, err := json.Marshal(assetCursor{Sort: q.sortKey, Value: value, ID: id, Before: before})
+	if err != nil {
+		// Sort values are NULL, numbers or text, which always encode.
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor reads a cursor issued for q's sort.
+func (q assetQuery) decodeCursor(raw string) (assetCursor, error) {
+	encoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return assetCursor{}, errInvalidCursor
+	}
+	var c assetCursor
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || c.ID <= 0 {
+		return assetCursor{}, errInvalidCursor
+	}
+	if c.Sort != q.sortKey {
+		return assetCursor{}, validationError{message: "cursor belongs to another sort, request the list again without it"}
+	}
+	// Numbers compare as numbers, so keep integers exact.
+	if number, ok := c.Value.(json.Number); ok {
+		if n, err := number.Int64(); err == nil {
+			c.Value = n
+		} else if f, err := number.Float64(); err == nil {
+			c.Value = f
+		} else {
+			return assetCursor{}, errInvalidCursor
+		}
+	}
+	return c, nil
+}
+
+// keysetCondition selects the rows after c in q's order, or before it when
+// c.Before is set. Rows are ordered by the sort expression, where NULL comes
+// first as in SQLite, then by id DESC.
+func (q assetQuery) keysetCondition(c assetCursor) (string, []interface{}) {
+	// up is whether the rows wanted sort higher than the cursor's value.
+	up := q.sortDesc == c.Before
+	idCompare := "<"
+	if c.Before {
+		idCompare = ">"
+	}
+	expr := q.sortExpr
+	switch {
+	case c.Value == nil && up:
+		return fmt.Sprintf("(%[1]s IS NOT NULL OR id %[2]s ?)", expr, idCompare), []interface{}{c.ID}
+	case c.Value == nil:
+		return fmt.Sprintf("(%[1]s IS NULL AND id %[2]s ?)", expr, idCompare), []interface{}{c.ID}
+	case up:
+		return fmt.Sprintf("(%[1]s > ? OR (%[1]s = ? AND id %[2]s ?))", expr, idCompare), []interface{}{c.Value, c.Value, c.ID}
+	default:
+		return fmt.Sprintf("(%[1]s < ? OR %[1]s IS NULL OR (%[1]s = ? AND id %[2]s ?))", expr, idCompare), []interface{}{c.Value, c.Value, c.ID}
+	}
+}
+
+// queryAssetsAtCursor reads the page following, or preceding, the row marked
+// by opts.Cursor. Rows inserted or removed elsewhere in the list do not shift
+// the page, unlike offsets.
+func (a *App) queryAssetsAtCursor(ctx context.Context, q assetQuery, opts AssetListOptions) (assetPage, error) {
+	c, err := q.decodeCursor(opts.Cursor)
+	if err != nil {
+		return assetPage{}, err
+	}
+	var result assetPage
+	if !opts.SkipCount {
+		if result.total, err = a.countAssets(ctx, q); err != nil {
+			return assetPage{}, err
+		}
+	}
+
+	condition, args := q.keysetCondition(c)
+	records, values, err := a.queryAssetRows(ctx, q, condition, args, c.Before, opts.PageSize+1, 0)
+	if err != nil {
+		return assetPage{}, err
+	}
+	more := len(records) > opts.PageSize
+	if more {
+		records, values = records[:opts.PageSize], values[:opts.PageSize]
+	}
+	if c.Before {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+	if len(records) > 0 {
+		// The cursor's own row lies beyond the end the page was read from.
+		if more || !c.Before {
+			result.prev = q.cursor(records[0].ID, values[0], true)
+		}
+		if more || c.Before {
+			result.next = q.cursor(records[len(records)-1].ID, values[len(values)-1], false)
+		}
+	}
+	result.records = records
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func assetIDs(records []AssetRecord) []int64 {
+	ids := make([]int64, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+// walkAssetCursors follows the next cursors from the first page, then the
+// prev cursors back from the last one, and returns the ids read each way.
+func walkAssetCursors(t *testing.T, app *App, opts AssetListOptions) (forward, backward []int64) {
+	t.Helper()
+	ctx := context.Background()
+	opts.SkipCount = true
+	var pages [][]int64
+	result, err := app.listAssets(ctx, 1, opts)
+	for {
+		if err != nil {
+			t.Fatalf("list assets: %v", err)
+		}
+		pages = append(pages, assetIDs(result.Records))
+		forward = append(forward, assetIDs(result.Records)...)
+		if result.NextCursor == "" {
+			break
+		}
+		opts.Cursor = result.NextCursor
+		result, err = app.listAssets(ctx, 1, opts)
+	}
+	backward = pages[len(pages)-1]
+	for result.PrevCursor != "" {
+		opts.Cursor = result.PrevCursor
+		if result, err = app.listAssets(ctx, 1, opts); err != nil {
+			t.Fatalf("list assets: %v", err)
+		}
+		backward = append(assetIDs(result.Records), backward...)
+	}
+	return forward, backward
+}
+
+func TestAssetCursorPagination(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	if resp := createTestField(t, app, `{"name":"capacity","type":"number"}`); resp.Status != http.StatusCreated {
+		t.Fatalf("create field: %d %s", resp.Status, resp.Body)
+	}
+	for i, capacity := range []interface{}{10.0, nil, 10.0, 2.5, nil} {
+		fields := map[string]interface{}{}
+		if capacity != nil {
+			fields["capacity"] = capacity
+		}
+		// Two entries share each date, so ties are broken by id.
+		createTestAsset(t, app, serviceEntry("PG-1", "Inspection", []string{"2025-01-01", "2025-02-01"}[i%2], fields))
+	}
+
+	for _, sort := range []*AssetListSort{
+		nil,
+		{Key: "title", Direction: sortDirectionAsc},
+		{Key: "custom.capacity", Direction: sortDirectionAsc},
+		{Key: "custom.capacity", Direction: sortDirectionDesc},
+	} {
+		all, err := app.listAssets(ctx, 1, AssetListOptions{PageSize: 100, Sort: sort})
+		if err != nil || all.TotalCount != 7 {
+			t.Fatalf("list all: %d (%v)", all.TotalCount, err)
+		}
+		forward, backward := walkAssetCursors(t, app, AssetListOptions{PageSize: 2, Sort: sort})
+		if expected := assetIDs(all.Records); !reflect.DeepEqual(forward, expected) || !reflect.DeepEqual(backward, expected) {
+			t.Fatalf("sort %+v: expected %v, got %v forward and %v backward", sort, expected, forward, backward)
+		}
+	}
+
+	// Entries added before the cursor neither repeat nor hide rows.
+	first, err := app.listAssets(ctx, 1, AssetListOptions{PageSize: 3, SkipCount: true})
+	if err != nil || first.TotalCount != 0 || first.TagCounts != nil || first.PrevCursor != "" || first.NextCursor == "" {
+		t.Fatalf("unexpected first page: %+v (%v)", first, err)
+	}
+	createTestAsset(t, app, serviceEntry("PG-2", "Inspection", "2030-01-01", nil))
+	second, err := app.listAssets(ctx, 1, AssetListOptions{PageSize: 3, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("second page: %v", err)
+	}
+	offset, err := app.listAssets(ctx, 1, AssetListOptions{PageSize: 100})
+	if err != nil || !reflect.DeepEqual(assetIDs(second.Records), assetIDs(offset.Records)[4:7]) || second.TotalCount != 8 {
+		t.Fatalf("expected the cursor to continue after the first page, got %v of %v (%v)", assetIDs(second.Records), assetIDs(offset.Records), err)
+	}
+
+	titleSorted, _ := app.listAssets(ctx, 1, AssetListOptions{PageSize: 2, Sort: &AssetListSort{Key: "title", Direction: sortDirectionAsc}})
+	for _, opts := range []AssetListOptions{
+		{Cursor: "not a cursor"},
+		{Cursor: titleSorted.NextCursor},
+		{Cursor: first.NextCursor, Near: &AssetNearFilter{Latitude: 1, Longitude: 1, RadiusKm: 10}},
+	} {
+		if _, err := app.listAssets(ctx, 1, opts); err == nil {
+			t.Fatalf("expected cursor %q to be rejected", opts.Cursor)
+		}
+	}
+
+	var page assetListResponse
+	resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets", URL: "assets?pageSize=2&count=false"})
+	if err := json.Unmarshal(resp.Body, &page); err != nil || page.Meta.TotalCount != nil || page.Meta.Page == nil || page.Meta.NextCursor == "" {
+		t.Fatalf("expected an uncounted page with a cursor, got %s (%v)", resp.Body, err)
+	}
+	var next assetListResponse
+	resp = callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets", URL: "assets?pageSize=2&count=true&cursor=" + url.QueryEscape(page.Meta.NextCursor)})
+	if err := json.Unmarshal(resp.Body, &next); err != nil || next.Meta.TotalCount == nil || *next.Meta.TotalCount != 8 || next.Meta.Page != nil || next.Meta.PrevCursor == "" {
+		t.Fatalf("expected a counted page at the cursor, got %s (%v)", resp.Body, err)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets", URL: "assets?cursor=x"}); resp.Status != http.StatusBadRequest {
+		t.Fatalf("expected an invalid cursor to 400, got %d", resp.Status)
+	}
+}
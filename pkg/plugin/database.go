@@ -59,6 +59,7 @@ func (a *App) initDatabase(ctx context.Context) error {
 		}
 
 		a.db = db
+		databases.track(db, candidate)
 		log.Printf("database initialized at: %s", candidate)
 		return nil
 	}
@@ -149,6 +150,12 @@ func runMigrations(db *sql.DB) error {
 			tx.Rollback()
 			return fmt.Errorf("apply migration %d (%s): %w", m.version, migrationName(m.version), err)
 		}
+		if m.apply != nil {
+			if err := m.apply(tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("apply migration %d (%s): %w", m.version, migrationName(m.version), err)
+			}
+		}
 
 		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, m.version); err != nil {
 			tx.Rollback()
@@ -0,0 +1,287 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// assetDateOnlyLayout is RFC 3339's full-date. Such values name a calendar
+// day and are stored as given.
+const assetDateOnlyLayout = "2006-01-02"
+
+// assetZonedDateLayouts carry their own offset.
+var assetZonedDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+}
+
+// assetLocalDateLayouts have no offset and are read in the org's time zone.
+var assetLocalDateLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+}
+
+// assetDateColumns lists the AssetPayload date fields.
+var assetDateColumns = []string{"entry_date", "commissioning_date", "start_date", "end_date"}
+
+// normalizeAssetDate converts an accepted date to its stored form: a
+// full-date, or a date-time in UTC. Values without an offset are read in loc.
+func normalizeAssetDate(value string, loc *time.Location) (string, bool) {
+	if parsed, err := time.Parse(assetDateOnlyLayout, value); err == nil {
+		return parsed.Format(assetDateOnlyLayout), true
+	}
+	for _, layout := range assetZonedDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC().Format(time.RFC3339), true
+		}
+	}
+	for _, layout := range assetLocalDateLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return parsed.UTC().Format(time.RFC3339), true
+		}
+	}
+	return value, false
+}
+
+// isStoredAssetDate reports whether value is in the form normalizeAssetDate
+// produces.
+func isStoredAssetDate(value string) bool {
+	if len(value) == len(assetDateOnlyLayout) {
+		_, err := time.Parse(assetDateOnlyLayout, value)
+		return err == nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	return err == nil && parsed.UTC().Format(time.RFC3339) == value
+}
+
+// storedAssetTime parses a stored date. A full-date is the start of that day
+// in loc.
+func storedAssetTime(value string, loc *time.Location) (time.Time, bool) {
+	if !isStoredAssetDate(value) {
+		return time.Time{}, false
+	}
+	if len(value) == len(assetDateOnlyLayout) {
+		parsed, err := time.ParseInLocation(assetDateOnlyLayout, value, loc)
+		return parsed, err == nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	return parsed, err == nil
+}
+
+// renderAssetDate shows a stored date-time in loc. Full-dates and values that
+// could not be converted are returned unchanged.
+func renderAssetDate(value string, loc *time.Location) string {
+	if len(value) == len(assetDateOnlyLayout) {
+		return value
+	}
+	parsed := parseAssetTimestamp(value, loc)
+	if parsed == nil {
+		return value
+	}
+	return parsed.In(loc).Format(time.RFC3339)
+}
+
+func (p *AssetPayload) dateFields() map[string]*string {
+	return map[string]*string{
+		"entry_date":         &p.EntryDate,
+		"commissioning_date": &p.CommissioningDate,
+		"start_date":         &p.StartDate,
+		"end_date":           &p.EndDate,
+	}
+}
+
+// localizeDates renders the payload's dates in loc.
+func (p *AssetPayload) localizeDates(loc *time.Location) {
+	for _, value := range p.dateFields() {
+		*value = renderAssetDate(*value, loc)
+	}
+}
+
+// localizeDates renders the record's dates in loc.
+func (r *AssetRecord) localizeDates(loc *time.Location) {
+	for _, value := range []*string{&r.EntryDate, &r.CommissioningDate, &r.StartDate, &r.EndDate, &r.CreatedAt, &r.UpdatedAt} {
+		*value = renderAssetDate(*value, loc)
+	}
+}
+
+// location is the org's time zone, used to read dates without an offset and
+// to render stored ones.
+func (a *App) location() *time.Location {
+	if a.config.TimeZone == nil {
+		return time.UTC
+	}
+	return a.config.TimeZone
+}
+
+// AssetDateIssue is a stored date the 0013 migration could not convert. It is
+// reported until the asset is saved with a valid date.
+type AssetDateIssue struct {
+	AssetID int64  `json:"asset_id"`
+	Title   string `json:"title"`
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+}
+
+// convertAssetDates rewrites the dates of every asset and revision snapshot to
+// their stored form. The org time zones are not known yet, so values without
+// an offset are read as UTC. Asset dates that cannot be parsed are kept and
+// recorded in asset_date_issues.
+func convertAssetDates(tx *sql.Tx) error {
+	if err := convertRevisionDates(tx); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id, entry_date, commissioning_date, start_date, end_date FROM assets`)
+	if err != nil {
+		return err
+	}
+	type assetDates struct {
+		id     int64
+		values AssetPayload
+	}
+	var assets []assetDates
+	for rows.Next() {
+		var row assetDates
+		if err := rows.Scan(&row.id, &row.values.EntryDate, &row.values.CommissioningDate, &row.values.StartDate, &row.values.EndDate); err != nil {
+			rows.Close()
+			return err
+		}
+		assets = append(assets, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var converted, failed int
+	for _, asset := range assets {
+		fields := asset.values.dateFields()
+		changed := false
+		for _, column := range assetDateColumns {
+			value := strings.TrimSpace(*fields[column])
+			normalized, ok := normalizeAssetDate(value, time.UTC)
+			if !ok {
+				failed++
+				log.Printf("asset %d: cannot convert %s %q to RFC 3339; left unchanged", asset.id, column, *fields[column])
+				if _, err := tx.Exec(`INSERT OR REPLACE INTO asset_date_issues (asset_id, column_name, value) VALUES (?, ?, ?)`, asset.id, column, *fields[column]); err != nil {
+					return err
+				}
+				continue
+			}
+			if normalized != *fields[column] {
+				*fields[column] = normalized
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		converted++
+		if _, err := tx.Exec(`UPDATE assets SET entry_date = ?, commissioning_date = ?, start_date = ?, end_date = ? WHERE id = ?`,
+			asset.values.EntryDate, asset.values.CommissioningDate, asset.values.StartDate, asset.values.EndDate, asset.id); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		log.Printf("converted dates of %d assets; %d dates could not be parsed, see /assets/date-issues", converted, failed)
+	}
+	return nil
+}
+
+func convertRevisionDates(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, payload FROM asset_revisions`)
+	if err != nil {
+		return err
+	}
+	snapshots := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var payload string
+		if err := rows.Scan(&id, &payload); err != nil {
+			rows.Close()
+			return err
+		}
+		snapshots[id] = payload
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, raw := range snapshots {
+		var snapshot map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &snapshot); err != nil {
+			continue
+		}
+		for _, column := range assetDateColumns {
+			value, ok := snapshot[column].(string)
+			if !ok {
+				continue
+			}
+			normalized, ok := normalizeAssetDate(strings.TrimSpace(value), time.UTC)
+			if !ok || normalized == value {
+				continue
+			}
+			if _, err := tx.Exec(`UPDATE asset_revisions SET payload = json_set(payload, ?, ?) WHERE id = ?`, "$."+column, normalized, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listAssetDateIssues returns the unconvertible dates of live assets that
+// still hold the reported value.
+func (a *App) listAssetDateIssues(ctx context.Context, orgID int64) ([]AssetDateIssue, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT i.asset_id, a.title, i.column_name, i.value
+FROM asset_date_issues AS i
+JOIN assets AS a ON a.id = i.asset_id
+WHERE a.org_id = ? AND a.deleted_at IS NULL
+  AND i.value = CASE i.column_name
+      WHEN 'entry_date' THEN a.entry_date
+      WHEN 'commissioning_date' THEN a.commissioning_date
+      WHEN 'start_date' THEN a.start_date
+      WHEN 'end_date' THEN a.end_date
+  END
+ORDER BY i.asset_id, i.column_name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := []AssetDateIssue{}
+	for rows.Next() {
+		var issue AssetDateIssue
+		if err := rows.Scan(&issue.AssetID, &issue.Title, &issue.Field, &issue.Value); err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+// handleAssetDateIssues serves GET /assets/date-issues.
+func (a *App) handleAssetDateIssues(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	issues, err := a.listAssetDateIssues(r.Context(), orgID)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": issues})
+}
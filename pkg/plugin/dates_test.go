@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestNormalizeAssetDate(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	tests := []struct {
+		value, expected string
+		ok              bool
+	}{
+		{value: "2025-02-24", expected: "2025-02-24", ok: true},
+		{value: "2025-02-24T13:44", expected: "2025-02-24T12:44:00Z", ok: true},
+		{value: "2025-07-24 13:44:30", expected: "2025-07-24T11:44:30Z", ok: true},
+		{value: "2025-02-24T13:44:00.5+05:30", expected: "2025-02-24T08:14:00Z", ok: true},
+		{value: "2025-02-24T13:44:00Z", expected: "2025-02-24T13:44:00Z", ok: true},
+		{value: "24.02.2025"},
+		{value: "2025-02-30"},
+		{value: "2025-2-4"},
+		{value: "2025-02-24T25:00"},
+		{value: "tbd"},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeAssetDate(tt.value, berlin)
+		if ok != tt.ok || (ok && got != tt.expected) {
+			t.Fatalf("%q: expected %q (%v), got %q (%v)", tt.value, tt.expected, tt.ok, got, ok)
+		}
+	}
+
+	if got := renderAssetDate("2025-02-24T12:44:00Z", berlin); got != "2025-02-24T13:44:00+01:00" {
+		t.Fatalf("unexpected rendered date %q", got)
+	}
+}
+
+func TestParseConfigTimeZone(t *testing.T) {
+	cfg, err := parseConfig(backend.AppInstanceSettings{JSONData: []byte(`{"timeZone":"America/New_York"}`)})
+	if err != nil || cfg.TimeZone.String() != "America/New_York" {
+		t.Fatalf("expected the configured zone, got %v (%v)", cfg.TimeZone, err)
+	}
+	cfg, err = parseConfig(backend.AppInstanceSettings{JSONData: []byte(`{"timeZone":"Mars/Olympus"}`)})
+	if err != nil || cfg.TimeZone != time.UTC {
+		t.Fatalf("expected an unknown zone to fall back to UTC, got %v (%v)", cfg.TimeZone, err)
+	}
+}
+
+func TestAssetDatesUseOrgTimeZone(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.config.TimeZone = time.FixedZone("UTC+1", 3600)
+
+	seed, err := app.getAsset(ctx, 1, 1)
+	if err != nil || seed.EntryDate != "2025-02-24T14:44:00+01:00" || seed.StartDate != "2024-02-20" {
+		t.Fatalf("expected seed dates rendered in the org zone, got %+v (%v)", seed, err)
+	}
+
+	record, err := app.createAsset(ctx, 1, AssetPayload{
+		Title:             "Zoned",
+		EntryDate:         "2025-06-01T08:00",
+		CommissioningDate: "2025-06-01T08:00:00-04:00",
+		StationName:       "TZ-1",
+		Technician:        "J. Doe",
+		StartDate:         "2025-06-01",
+		EndDate:           "2025-06-02",
+	})
+	if err != nil {
+		t.Fatalf("create asset: %v", err)
+	}
+	if record.EntryDate != "2025-06-01T08:00:00+01:00" || record.CommissioningDate != "2025-06-01T13:00:00+01:00" {
+		t.Fatalf("unexpected rendered dates: %+v", record)
+	}
+	var stored string
+	if err := app.db.QueryRow(`SELECT entry_date FROM assets WHERE id = ?`, record.ID).Scan(&stored); err != nil || stored != "2025-06-01T07:00:00Z" {
+		t.Fatalf("expected entry_date stored in UTC, got %q (%v)", stored, err)
+	}
+
+	body := `{"title":"Zoned","entry_date":"01/06/2025","commissioning_date":"2025-06-01","station_name":"TZ-1","technician":"J. Doe","start_date":"2025-06-01","end_date":"2025-06-02"}`
+	resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: "assets/1", Body: []byte(body)})
+	if resp.Status != http.StatusBadRequest {
+		t.Fatalf("expected an invalid date to 400, got %d: %s", resp.Status, resp.Body)
+	}
+
+	result, err := app.listAssets(ctx, 1, AssetListOptions{Sort: &AssetListSort{Key: "entry_date", Direction: sortDirectionAsc}})
+	if err != nil {
+		t.Fatalf("list assets: %v", err)
+	}
+	if result.Records[0].ID != 1 || result.Records[len(result.Records)-1].ID != record.ID {
+		t.Fatalf("expected entries sorted chronologically, got %+v", result.Records)
+	}
+}
+
+func TestAssetDateMigrationReportsUnparseableRows(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "assets.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// Bring the schema to the version before the date migration, then add
+	// rows in the formats found in older databases.
+	if _, err := db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatalf("create schema_migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if m.version >= 13 {
+			break
+		}
+		if _, err := db.Exec(m.script); err != nil {
+			t.Fatalf("migration %d: %v", m.version, err)
+		}
+		if _, err := db.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, m.version); err != nil {
+			t.Fatalf("record migration %d: %v", m.version, err)
+		}
+	}
+	res, err := db.Exec(`INSERT INTO assets (org_id, title, entry_date, commissioning_date, station_name, technician, start_date, end_date, staff, images)
+VALUES (1, 'Legacy', '24.02.2025', '2025-02-24T13:44:00+01:00', 'LG-1', 'J. Doe', '2025-02-20', 'tbd', '[]', '[]')`)
+	if err != nil {
+		t.Fatalf("insert legacy asset: %v", err)
+	}
+	legacyID, _ := res.LastInsertId()
+
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+
+	var entry, commissioning, start string
+	if err := db.QueryRow(`SELECT entry_date, commissioning_date, start_date FROM assets WHERE id = 1`).Scan(&entry, &commissioning, &start); err != nil {
+		t.Fatalf("read seed asset: %v", err)
+	}
+	if entry != "2025-02-24T13:44:00Z" || start != "2024-02-20" {
+		t.Fatalf("expected seed dates converted, got %q %q", entry, start)
+	}
+	var snapshot string
+	if err := db.QueryRow(`SELECT json_extract(payload, '$.entry_date') FROM asset_revisions WHERE asset_id = 1 AND revision = 1`).Scan(&snapshot); err != nil || snapshot != entry {
+		t.Fatalf("expected revision snapshots converted, got %q (%v)", snapshot, err)
+	}
+
+	app := &App{db: db}
+	issues, err := app.listAssetDateIssues(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("list issues: %v", err)
+	}
+	if len(issues) != 2 || issues[0].AssetID != legacyID || issues[0].Field != "end_date" || issues[1].Field != "entry_date" || issues[1].Value != "24.02.2025" {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+	if err := db.QueryRow(`SELECT commissioning_date FROM assets WHERE id = ?`, legacyID).Scan(&commissioning); err != nil || commissioning != "2025-02-24T12:44:00Z" {
+		t.Fatalf("expected parseable dates of the row converted, got %q (%v)", commissioning, err)
+	}
+
+	// Saving a valid date resolves the issue.
+	if _, err := db.Exec(`UPDATE assets SET entry_date = '2025-02-24' WHERE id = ?`, legacyID); err != nil {
+		t.Fatalf("fix entry date: %v", err)
+	}
+	if issues, err := app.listAssetDateIssues(context.Background(), 1); err != nil || len(issues) != 1 {
+		t.Fatalf("expected one remaining issue, got %+v (%v)", issues, err)
+	}
+}
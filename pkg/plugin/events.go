@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// assetEvent is the body sent to webhooks and Grafana Live subscribers when an
+// asset or attachment changes. Event is one of the webhookEvent* types.
+type assetEvent struct {
+	Event      string      `json:"event"`
+	OrgID      int64       `json:"org_id"`
+	OccurredAt string      `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// assetEvents collects the events of one transaction. Their webhook deliveries
+// are queued in the transaction, so that they commit or roll back with the
+// change, and the events reach the org's Live stream once it has committed.
+type assetEvents struct {
+	orgID  int64
+	bodies [][]byte
+	queued int64
+}
+
+// add records event with data and queues its webhook deliveries through tx.
+func (e *assetEvents) add(ctx context.Context, tx sqlExecer, event string, data interface{}) error {
+	now := time.Now()
+	body, err := json.Marshal(assetEvent{
+		Event:      event,
+		OrgID:      e.orgID,
+		OccurredAt: now.UTC().Format(time.RFC3339Nano),
+		Data:       data,
+	})
+	if err != nil {
+		return err
+	}
+	queued, err := enqueueWebhookEvent(ctx, tx, e.orgID, event, body, now)
+	if err != nil {
+		return err
+	}
+	e.bodies = append(e.bodies, body)
+	e.queued += queued
+	return nil
+}
+
+// addAssetEvent records event with the asset as tx sees it.
+func (a *App) addAssetEvent(ctx context.Context, tx *sql.Tx, e *assetEvents, event string, assetID int64) error {
+	record, err := a.readAsset(ctx, tx, e.orgID, assetID)
+	if err != nil {
+		return err
+	}
+	return e.add(ctx, tx, event, record)
+}
+
+// inEventTx is inTx for changes that raise events: fn records them in events,
+// and they are published once the transaction has committed.
+func (a *App) inEventTx(ctx context.Context, orgID int64, fn func(tx *sql.Tx, events *assetEvents) error) error {
+	events := &assetEvents{orgID: orgID}
+	if err := a.inTx(ctx, func(tx *sql.Tx) error { return fn(tx, events) }); err != nil {
+		return err
+	}
+	a.publishEvents(events)
+	return nil
+}
+
+// publishEvents pushes committed events to the org's Live stream and wakes the
+// webhook dispatcher for their deliveries.
+func (a *App) publishEvents(events *assetEvents) {
+	for _, body := range events.bodies {
+		assetStreams.publish(events.orgID, body)
+	}
+	if events.queued > 0 {
+		a.wakeWebhookDispatcher()
+	}
+}
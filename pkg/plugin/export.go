@@ -0,0 +1,505 @@
+package plugin
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	geoJSONContentType = "application/geo+json"
+	csvContentType     = "text/csv"
+
+	csvIncludeAttachmentNames = "attachment_names"
+	csvIncludeAttachmentURLs  = "attachment_urls"
+	csvMultiValueSeparator    = "; "
+
+	// exportFlushRows is how many rows an export buffers before flushing them
+	// to Grafana as a response chunk.
+	exportFlushRows = 200
+)
+
+var assetCSVHeader = []string{
+	"id",
+	"title",
+	"entry_date",
+	"commissioning_date",
+	"station_name",
+	"technician",
+	"start_date",
+	"end_date",
+	"service",
+	"staff",
+	"latitude",
+	"longitude",
+	"pitch",
+	"roll",
+	"attachment_count",
+	"created_at",
+	"updated_at",
+	"tags",
+}
+
+// assetRow is an asset read by streamAssets, with its tags, together with its
+// attachment count and, when requested, its attachment references.
+type assetRow struct {
+	AssetRecord
+	attachmentCount int
+	files           []assetFileRef
+}
+
+// assetFileRef identifies an attachment without signing a download URL.
+type assetFileRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// assetFileRefsColumn aggregates the attachments of each row into a JSON array
+// so they are read by the same query as the asset itself.
+const assetFileRefsColumn = `(SELECT json_group_array(json_object('name', file_name, 'key', object_name)) FROM (SELECT file_name, object_name FROM asset_files WHERE asset_files.asset_id = assets.id ORDER BY id))`
+
+// assetTagsColumn aggregates the tags of each row in the order of the JSON API.
+const assetTagsColumn = `(SELECT json_group_array(name) FROM (SELECT t.name FROM asset_tags AS at JOIN tags AS t ON t.id = at.tag_id WHERE at.asset_id = assets.id ORDER BY at.position, t.name_key))`
+
+// streamAssets runs the list query without pagination and hands each row to fn
+// as it is read, so exports never hold the full result set in memory. The tags
+// and attachment count are selected alongside every row, and the attachment
+// references too when withFiles is set. A positive limit caps the number of
+// rows. With near, rows outside the haversine radius are skipped and a
+// distance sort follows the SQL approximation.
+func (a *App) streamAssets(ctx context.Context, orgID int64, opts AssetListOptions, limit int, withFiles bool, fn func(row assetRow) error) error {
+	opts.normalize()
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
+	if err != nil {
+		return err
+	}
+	opts.fields = fields
+	opts.location = a.location()
+	q, err := buildAssetQuery(orgID, opts)
+	if err != nil {
+		return err
+	}
+
+	columns := q.columns + ", (SELECT COUNT(*) FROM asset_files WHERE asset_files.asset_id = assets.id), " + assetTagsColumn
+	if withFiles {
+		columns += ", " + assetFileRefsColumn
+	}
+	rows, err := a.db.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM %s WHERE %s ORDER BY %s`, columns, q.from, q.where, q.order), q.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	emitted := 0
+	for rows.Next() {
+		var row assetRow
+		var tagsRaw string
+		var filesRaw sqlNullString
+		extra := []interface{}{&row.attachmentCount, &tagsRaw}
+		if withFiles {
+			extra = append(extra, &filesRaw)
+		}
+		row.AssetRecord, err = q.scan(rows, extra...)
+		if err != nil {
+			return err
+		}
+		if q.near != nil && *row.DistanceKm > q.near.RadiusKm {
+			continue
+		}
+		if err := json.Unmarshal([]byte(tagsRaw), &row.Tags); err != nil {
+			return fmt.Errorf("decode tags of asset %d: %w", row.ID, err)
+		}
+		if filesRaw.Valid {
+			if err := json.Unmarshal([]byte(filesRaw.String), &row.files); err != nil {
+				return fmt.Errorf("decode attachments of asset %d: %w", row.ID, err)
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+		if emitted++; limit > 0 && emitted >= limit {
+			return nil
+		}
+	}
+	return rows.Err()
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type assetGeoJSONProperties struct {
+	ID                int64    `json:"id"`
+	Title             string   `json:"title"`
+	EntryDate         string   `json:"entry_date"`
+	CommissioningDate string   `json:"commissioning_date"`
+	StationName       string   `json:"station_name"`
+	Technician        string   `json:"technician"`
+	StartDate         string   `json:"start_date"`
+	EndDate           string   `json:"end_date"`
+	Service           string   `json:"service,omitempty"`
+	Staff             []string `json:"staff"`
+	Latitude          float64  `json:"latitude"`
+	Longitude         float64  `json:"longitude"`
+	Pitch             float64  `json:"pitch"`
+	Roll              float64  `json:"roll"`
+	AttachmentCount   int      `json:"attachment_count"`
+	DistanceKm        *float64 `json:"distance_km,omitempty"`
+	CreatedAt         string   `json:"created_at"`
+	UpdatedAt         string   `json:"updated_at"`
+	Tags              []string `json:"tags"`
+	// CustomFields holds the values of the org's custom fields by name.
+	CustomFields map[string]interface{} `json:"custom_fields"`
+}
+
+type assetGeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         int64                  `json:"id"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties assetGeoJSONProperties `json:"properties"`
+}
+
+func newAssetGeoJSONFeature(record AssetRecord, attachmentCount int) assetGeoJSONFeature {
+	return assetGeoJSONFeature{
+		Type:     "Feature",
+		ID:       record.ID,
+		Geometry: geoJSONPoint{Type: "Point", Coordinates: [2]float64{record.Longitude, record.Latitude}},
+		Properties: assetGeoJSONProperties{
+			ID:                record.ID,
+			Title:             record.Title,
+			EntryDate:         record.EntryDate,
+			CommissioningDate: record.CommissioningDate,
+			StationName:       record.StationName,
+			Technician:        record.Technician,
+			StartDate:         record.StartDate,
+			EndDate:           record.EndDate,
+			Service:           record.Service,
+			Staff:             record.Staff,
+			Latitude:          record.Latitude,
+			Longitude:         record.Longitude,
+			Pitch:             record.Pitch,
+			Roll:              record.Roll,
+			AttachmentCount:   attachmentCount,
+			DistanceKm:        record.DistanceKm,
+			CreatedAt:         record.CreatedAt,
+			UpdatedAt:         record.UpdatedAt,
+			Tags:              record.Tags,
+			CustomFields:      record.CustomFields,
+		},
+	}
+}
+
+// acceptsMediaType reports whether the Accept header explicitly lists mediaType.
+func acceptsMediaType(r *http.Request, mediaType string) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			candidate, _, _ := strings.Cut(part, ";")
+			if strings.EqualFold(strings.TrimSpace(candidate), mediaType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *App) handleAssetsGeoJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	opts, err := parseAssetListOptions(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	a.writeAssetsGeoJSON(w, r, orgID, opts)
+}
+
+// flushResponse sends what has been written so far. The resource adapter
+// buffers the whole body until the handler returns unless it is flushed.
+func flushResponse(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeAssetsGeoJSON streams a FeatureCollection of every matching asset,
+// flushing every exportFlushRows features. Errors raised before the first
+// feature is written still produce a proper status code; once the 200 status
+// is committed a failure leaves the collection unterminated, so clients see
+// invalid JSON rather than a silently shortened export.
+func (a *App) writeAssetsGeoJSON(w http.ResponseWriter, r *http.Request, orgID int64, opts AssetListOptions) {
+	started := false
+	written := 0
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", geoJSONContentType)
+		w.WriteHeader(http.StatusOK)
+		_, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`)
+		return err
+	}
+
+	err := a.streamAssets(r.Context(), orgID, opts, 0, false, func(row assetRow) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		} else if _, err := io.WriteString(w, ","); err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(newAssetGeoJSONFeature(row.AssetRecord, row.attachmentCount))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(encoded); err != nil {
+			return err
+		}
+		if written++; written%exportFlushRows == 0 {
+			flushResponse(w)
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			writeHTTPError(w, err)
+			return
+		}
+		log.Printf("stream geojson failed: %v", err)
+		return
+	}
+	if !started {
+		if err := start(); err != nil {
+			log.Printf("stream geojson failed: %v", err)
+			return
+		}
+	}
+	if _, err := io.WriteString(w, "]}\n"); err != nil {
+		log.Printf("stream geojson failed: %v", err)
+	}
+}
+
+type assetCSVOptions struct {
+	attachmentNames bool
+	attachmentURLs  bool
+}
+
+func parseAssetCSVOptions(r *http.Request) (assetCSVOptions, error) {
+	var opts assetCSVOptions
+	for _, raw := range r.URL.Query()["include"] {
+		for _, column := range strings.Split(raw, ",") {
+			switch strings.TrimSpace(column) {
+			case "":
+			case csvIncludeAttachmentNames:
+				opts.attachmentNames = true
+			case csvIncludeAttachmentURLs:
+				opts.attachmentURLs = true
+			default:
+				return assetCSVOptions{}, validationError{message: fmt.Sprintf("unsupported include column %q", strings.TrimSpace(column))}
+			}
+		}
+	}
+	return opts, nil
+}
+
+// header lists the fixed columns, a custom.<name> column per custom field of
+// the org and the requested attachment columns.
+func (o assetCSVOptions) header(fields assetFieldDefinitions) []string {
+	header := append([]string{}, assetCSVHeader...)
+	for _, field := range fields {
+		header = append(header, customFieldPrefix+field.Name)
+	}
+	if o.attachmentNames {
+		header = append(header, csvIncludeAttachmentNames)
+	}
+	if o.attachmentURLs {
+		header = append(header, csvIncludeAttachmentURLs)
+	}
+	return header
+}
+
+func assetCSVFilename(orgID int64, now time.Time) string {
+	return fmt.Sprintf("assetlog-org-%d-%s.csv", orgID, now.UTC().Format("2006-01-02"))
+}
+
+func (a *App) handleAssetsCSV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	opts, err := parseAssetListOptions(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	a.writeAssetsCSV(w, r, orgID, opts)
+}
+
+// writeAssetsCSV streams every matching asset as a CSV download, flushing every
+// exportFlushRows rows. Custom fields get a column each, named like the import
+// expects them. Attachment columns are only selected when requested through
+// include=. A failure after the first row truncates the file and is
+// only logged, as the status has already been sent.
+func (a *App) writeAssetsCSV(w http.ResponseWriter, r *http.Request, orgID int64, opts AssetListOptions) {
+	csvOpts, err := parseAssetCSVOptions(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	fields, err := listAssetFieldDefinitions(r.Context(), a.db, orgID)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	var writer *csv.Writer
+	start := func() error {
+		w.Header().Set("Content-Type", csvContentType+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, assetCSVFilename(orgID, time.Now())))
+		w.WriteHeader(http.StatusOK)
+		writer = csv.NewWriter(w)
+		return writer.Write(csvOpts.header(fields))
+	}
+
+	written := 0
+	withFiles := csvOpts.attachmentNames || csvOpts.attachmentURLs
+	err = a.streamAssets(r.Context(), orgID, opts, 0, withFiles, func(row assetRow) error {
+		if writer == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		record := []string{
+			strconv.FormatInt(row.ID, 10),
+			csvSafeText(row.Title),
+			csvSafeText(row.EntryDate),
+			csvSafeText(row.CommissioningDate),
+			csvSafeText(row.StationName),
+			csvSafeText(row.Technician),
+			csvSafeText(row.StartDate),
+			csvSafeText(row.EndDate),
+			csvSafeText(row.Service),
+			csvSafeText(strings.Join(row.Staff, csvMultiValueSeparator)),
+			strconv.FormatFloat(row.Latitude, 'f', -1, 64),
+			strconv.FormatFloat(row.Longitude, 'f', -1, 64),
+			strconv.FormatFloat(row.Pitch, 'f', -1, 64),
+			strconv.FormatFloat(row.Roll, 'f', -1, 64),
+			strconv.Itoa(row.attachmentCount),
+			row.CreatedAt,
+			row.UpdatedAt,
+			csvSafeText(strings.Join(row.Tags, csvMultiValueSeparator)),
+		}
+		for _, field := range fields {
+			record = append(record, customFieldCSVText(row.CustomFields[field.Name]))
+		}
+		record = append(record, a.assetCSVAttachmentColumns(row.files, csvOpts)...)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		if written++; written%exportFlushRows == 0 {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return err
+			}
+			flushResponse(w)
+		}
+		return nil
+	})
+	if err != nil {
+		if writer == nil {
+			writeHTTPError(w, err)
+			return
+		}
+		log.Printf("stream csv failed: %v", err)
+		return
+	}
+	if writer == nil {
+		if err := start(); err != nil {
+			log.Printf("stream csv failed: %v", err)
+			return
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("stream csv failed: %v", err)
+	}
+}
+
+// assetCSVAttachmentColumns renders the requested attachment columns. URLs are
+// gs:// object URIs rather than signed links, which expire long before an
+// exported spreadsheet is read.
+func (a *App) assetCSVAttachmentColumns(files []assetFileRef, opts assetCSVOptions) []string {
+	columns := make([]string, 0, 2)
+	if opts.attachmentNames {
+		names := make([]string, 0, len(files))
+		for _, file := range files {
+			names = append(names, file.Name)
+		}
+		columns = append(columns, csvSafeText(strings.Join(names, csvMultiValueSeparator)))
+	}
+	if opts.attachmentURLs {
+		urls := make([]string, 0, len(files))
+		for _, file := range files {
+			urls = append(urls, a.attachmentObjectURI(file.Key))
+		}
+		columns = append(columns, strings.Join(urls, csvMultiValueSeparator))
+	}
+	return columns
+}
+
+// customFieldCSVText renders a custom field value the way the import parses
+// it back. Unset fields are empty.
+func customFieldCSVText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return csvSafeText(v)
+	}
+	return csvSafeText(fmt.Sprint(value))
+}
+
+// attachmentObjectURI returns a stable gs:// reference to a stored attachment,
+// or the bare object key when no bucket is configured.
+func (a *App) attachmentObjectURI(key string) string {
+	if a.config.Storage.Bucket == "" {
+		return key
+	}
+	object := strings.TrimLeft(path.Join(strings.Trim(a.config.Storage.Prefix, "/"), key), "/")
+	return fmt.Sprintf("gs://%s/%s", a.config.Storage.Bucket, object)
+}
+
+// csvSafeText prefixes values that spreadsheet applications would otherwise
+// evaluate as formulas.
+func csvSafeText(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,290 @@
+package plugin
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestAssetsGeoJSONExport(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, tc := range []struct {
+		name        string
+		path        string
+		url         string
+		headers     map[string][]string
+		expFeatures int
+	}{
+		{name: "geojson path", path: "assets.geojson", expFeatures: 2},
+		{name: "filtered", path: "assets.geojson", url: "assets.geojson?filter[station_name]=MT-202", expFeatures: 1},
+		{name: "accept header", path: "assets", headers: map[string][]string{"Accept": {"application/geo+json"}}, expFeatures: 2},
+		{name: "empty collection", path: "assets.geojson", url: "assets.geojson?q=nothingmatches", expFeatures: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var r mockCallResourceResponseSender
+			err := app.CallResource(context.Background(), &backend.CallResourceRequest{
+				Method:        http.MethodGet,
+				Path:          tc.path,
+				URL:           tc.url,
+				Headers:       tc.headers,
+				PluginContext: backend.PluginContext{OrgID: 1},
+			}, &r)
+			if err != nil {
+				t.Fatalf("CallResource error: %v", err)
+			}
+			if r.response.Status != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", r.response.Status, r.response.Body)
+			}
+			var collection struct {
+				Type     string `json:"type"`
+				Features []struct {
+					Geometry struct {
+						Coordinates [2]float64 `json:"coordinates"`
+					} `json:"geometry"`
+					Properties map[string]interface{} `json:"properties"`
+				} `json:"features"`
+			}
+			if err := json.Unmarshal(r.response.Body, &collection); err != nil {
+				t.Fatalf("decode geojson: %v (%s)", err, r.response.Body)
+			}
+			if collection.Type != "FeatureCollection" || len(collection.Features) != tc.expFeatures {
+				t.Fatalf("expected %d features, got %d", tc.expFeatures, len(collection.Features))
+			}
+			for _, feature := range collection.Features {
+				if feature.Geometry.Coordinates[0] != feature.Properties["longitude"] {
+					t.Fatalf("expected [lon, lat] coordinates, got %v", feature.Geometry.Coordinates)
+				}
+				if _, ok := feature.Properties["attachment_count"]; !ok {
+					t.Fatalf("expected attachment_count property")
+				}
+			}
+		})
+	}
+}
+
+func TestAssetsCSVExport(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, tc := range []struct {
+		name        string
+		path        string
+		url         string
+		headers     map[string][]string
+		expStatus   int
+		expStations []string
+		expHeader   []string
+	}{
+		{name: "csv path", path: "assets.csv", expStatus: http.StatusOK, expStations: []string{"MT-202", "WLS7-1273"}},
+		{name: "sorted", path: "assets.csv", url: "assets.csv?sort=title:asc", expStatus: http.StatusOK, expStations: []string{"WLS7-1273", "MT-202"}},
+		{name: "filtered", path: "assets.csv", url: "assets.csv?filter[technician]=M.%20Paxl", expStatus: http.StatusOK, expStations: []string{"WLS7-1273"}},
+		{name: "accept header", path: "assets", headers: map[string][]string{"Accept": {"text/csv"}}, expStatus: http.StatusOK, expStations: []string{"MT-202", "WLS7-1273"}},
+		{
+			name:        "attachment columns",
+			path:        "assets.csv",
+			url:         "assets.csv?include=attachment_names,attachment_urls",
+			expStatus:   http.StatusOK,
+			expStations: []string{"MT-202", "WLS7-1273"},
+			expHeader:   append(append([]string{}, assetCSVHeader...), csvIncludeAttachmentNames, csvIncludeAttachmentURLs),
+		},
+		{name: "unknown include", path: "assets.csv", url: "assets.csv?include=secrets", expStatus: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var r mockCallResourceResponseSender
+			err := app.CallResource(context.Background(), &backend.CallResourceRequest{
+				Method:        http.MethodGet,
+				Path:          tc.path,
+				URL:           tc.url,
+				Headers:       tc.headers,
+				PluginContext: backend.PluginContext{OrgID: 1},
+			}, &r)
+			if err != nil {
+				t.Fatalf("CallResource error: %v", err)
+			}
+			if r.response.Status != tc.expStatus {
+				t.Fatalf("expected %d, got %d: %s", tc.expStatus, r.response.Status, r.response.Body)
+			}
+			if tc.expStatus != http.StatusOK {
+				return
+			}
+			if disposition := r.response.Headers["Content-Disposition"]; len(disposition) != 1 || !strings.Contains(disposition[0], `filename="assetlog-org-1-`) {
+				t.Fatalf("unexpected content disposition %v", disposition)
+			}
+			records, err := csv.NewReader(strings.NewReader(string(r.response.Body))).ReadAll()
+			if err != nil {
+				t.Fatalf("decode csv: %v", err)
+			}
+			expHeader := tc.expHeader
+			if expHeader == nil {
+				expHeader = assetCSVHeader
+			}
+			if strings.Join(records[0], ",") != strings.Join(expHeader, ",") {
+				t.Fatalf("unexpected header %v", records[0])
+			}
+			var stations []string
+			for _, record := range records[1:] {
+				if len(record) != len(expHeader) {
+					t.Fatalf("expected %d columns, got %d", len(expHeader), len(record))
+				}
+				stations = append(stations, record[4])
+			}
+			if strings.Join(stations, ",") != strings.Join(tc.expStations, ",") {
+				t.Fatalf("expected stations %v, got %v", tc.expStations, stations)
+			}
+		})
+	}
+}
+
+func TestCSVSafeText(t *testing.T) {
+	for input, exp := range map[string]string{
+		"":             "",
+		"MT-202":       "MT-202",
+		"=HYPERLINK()": "'=HYPERLINK()",
+		"@SUM(A1)":     "'@SUM(A1)",
+		"-1+2":         "'-1+2",
+	} {
+		if got := csvSafeText(input); got != exp {
+			t.Fatalf("csvSafeText(%q) = %q, want %q", input, got, exp)
+		}
+	}
+}
+
+func TestAssetsGeoJSONExportFlushesInChunks(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	for i := 0; i < exportFlushRows; i++ {
+		if _, err := app.createAsset(ctx, 1, AssetPayload{
+			Title: "Bulk", EntryDate: "2025-05-01", CommissioningDate: "2025-05-01", StationName: "BLK",
+			Technician: "A. Schmidt", StartDate: "2025-05-01", EndDate: "2025-05-02",
+		}); err != nil {
+			t.Fatalf("create asset: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	app.writeAssetsGeoJSON(rec, httptest.NewRequest(http.MethodGet, "/assets.geojson", nil), 1, AssetListOptions{})
+	if !rec.Flushed {
+		t.Fatalf("expected the export to flush while streaming")
+	}
+	var collection struct {
+		Features []json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("decode geojson: %v", err)
+	}
+	if len(collection.Features) != exportFlushRows+2 {
+		t.Fatalf("expected %d features, got %d", exportFlushRows+2, len(collection.Features))
+	}
+}
+
+func TestAssetsCSVExportAttachmentColumns(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.config.Storage.Bucket = "assetlog-audit"
+	app.config.Storage.Prefix = "uploads/"
+
+	if _, err := app.insertAssetFile(ctx, 1, 2, "=report.pdf", "application/pdf", "org-1/asset-2/report.pdf"); err != nil {
+		t.Fatalf("insert asset file: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/assets.csv?include=attachment_names,attachment_urls&filter[station_name]=MT-202", nil)
+	opts, err := parseAssetListOptions(req)
+	if err != nil {
+		t.Fatalf("parse options: %v", err)
+	}
+	app.writeAssetsCSV(rec, req, 1, opts)
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("decode csv: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header and one row, got %d rows", len(records))
+	}
+	row := records[1]
+	names := strings.Split(row[len(row)-2], csvMultiValueSeparator)
+	urls := strings.Split(row[len(row)-1], csvMultiValueSeparator)
+	if len(names) != len(urls) || names[len(names)-1] != "=report.pdf" {
+		t.Fatalf("unexpected attachment columns %q / %q", names, urls)
+	}
+	if got := urls[len(urls)-1]; got != "gs://assetlog-audit/uploads/org-1/asset-2/report.pdf" {
+		t.Fatalf("unexpected attachment url %q", got)
+	}
+}
+
+func TestAssetExportsIncludeTagsAndCustomFields(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	for _, body := range []string{`{"name":"firmware","type":"text"}`, `{"name":"capacity","type":"number"}`} {
+		if resp := createTestField(t, app, body); resp.Status != http.StatusCreated {
+			t.Fatalf("create field: %d %s", resp.Status, resp.Body)
+		}
+	}
+	createTestAsset(t, app, func(p *AssetPayload) {
+		p.StationName = "EXP-1"
+		p.Tags = []string{"lidar", "storm-damage"}
+		p.CustomFields = map[string]interface{}{"firmware": "=v2", "capacity": 12.5}
+	})
+
+	export := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path+"?filter[station_name]=EXP-1", nil)
+		opts, err := parseAssetListOptions(req)
+		if err != nil {
+			t.Fatalf("parse options: %v", err)
+		}
+		if path == "/assets.csv" {
+			app.writeAssetsCSV(rec, req, 1, opts)
+		} else {
+			app.writeAssetsGeoJSON(rec, req, 1, opts)
+		}
+		return rec
+	}
+
+	var collection struct {
+		Features []struct {
+			Properties assetGeoJSONProperties `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(export("/assets.geojson").Body.Bytes(), &collection); err != nil || len(collection.Features) != 1 {
+		t.Fatalf("decode geojson: %v (%d features)", err, len(collection.Features))
+	}
+	properties := collection.Features[0].Properties
+	if strings.Join(properties.Tags, ",") != "lidar,storm-damage" || properties.CustomFields["firmware"] != "=v2" || properties.CustomFields["capacity"] != 12.5 {
+		t.Fatalf("unexpected properties: %+v", properties)
+	}
+
+	body := export("/assets.csv").Body.String()
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("decode csv: %v (%d rows)", err, len(records))
+	}
+	header := strings.Join(records[0], ",")
+	if !strings.HasSuffix(header, ",tags,custom.capacity,custom.firmware") {
+		t.Fatalf("unexpected header %s", header)
+	}
+	row := records[1][len(assetCSVHeader)-1:]
+	if strings.Join(row, "|") != "lidar; storm-damage|12.5|'=v2" {
+		t.Fatalf("unexpected columns %q", row)
+	}
+
+	// The export imports back with its tags and custom fields.
+	status, report := callAssetImport(t, app, nil, body)
+	if status != http.StatusCreated || len(report.Created) != 1 {
+		t.Fatalf("import: %d %+v", status, report)
+	}
+	imported, err := app.getAsset(ctx, 1, report.Created[0])
+	if err != nil {
+		t.Fatalf("get asset: %v", err)
+	}
+	if strings.Join(imported.Tags, ",") != "lidar,storm-damage" || imported.CustomFields["firmware"] != "=v2" || imported.CustomFields["capacity"] != 12.5 {
+		t.Fatalf("unexpected imported asset: %+v", imported)
+	}
+}
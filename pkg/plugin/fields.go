@@ -0,0 +1,507 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fieldTypeText   = "text"
+	fieldTypeNumber = "number"
+	fieldTypeDate   = "date"
+	fieldTypeEnum   = "enum"
+	fieldTypeBool   = "bool"
+)
+
+// customFieldPrefix marks custom field keys in filter and sort parameters,
+// e.g. filter[custom.serial_number]=SN-1 or sort=custom.firmware:desc.
+const customFieldPrefix = "custom."
+
+// customFieldNamePattern keeps names safe to inline in JSON paths.
+var customFieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+var errAssetFieldNotFound = errors.New("custom field not found")
+
+// AssetFieldDefinition describes a custom field an org adds to its assets.
+// AllowedValues is only used by enum fields.
+type AssetFieldDefinition struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Required      bool     `json:"required"`
+	AllowedValues []string `json:"allowed_values"`
+	CreatedAt     string   `json:"created_at,omitempty"`
+	UpdatedAt     string   `json:"updated_at,omitempty"`
+}
+
+func (d *AssetFieldDefinition) normalize() {
+	d.Name = strings.TrimSpace(d.Name)
+	d.Type = strings.ToLower(strings.TrimSpace(d.Type))
+	values := make([]string, 0, len(d.AllowedValues))
+	for _, value := range d.AllowedValues {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	d.AllowedValues = values
+}
+
+func (d AssetFieldDefinition) validate() error {
+	if !customFieldNamePattern.MatchString(d.Name) {
+		return validationError{message: "name must start with a lowercase letter and contain only lowercase letters, digits and underscores (max 64)"}
+	}
+	switch d.Type {
+	case fieldTypeText, fieldTypeNumber, fieldTypeDate, fieldTypeBool:
+		if len(d.AllowedValues) > 0 {
+			return validationError{message: "allowed_values is only supported for enum fields"}
+		}
+	case fieldTypeEnum:
+		if len(d.AllowedValues) == 0 {
+			return validationError{message: "enum fields require allowed_values"}
+		}
+		seen := make(map[string]struct{}, len(d.AllowedValues))
+		for _, value := range d.AllowedValues {
+			if _, dup := seen[value]; dup {
+				return validationError{message: fmt.Sprintf("allowed value %q is listed twice", value)}
+			}
+			seen[value] = struct{}{}
+		}
+	default:
+		return validationError{message: "type must be one of text, number, date, enum, bool"}
+	}
+	return nil
+}
+
+// expression returns the SQL expression reading the field from assets.
+func (d AssetFieldDefinition) expression() string {
+	return fmt.Sprintf("json_extract(custom_fields, '$.%s')", d.Name)
+}
+
+// sortExpression orders dates chronologically whatever their format.
+func (d AssetFieldDefinition) sortExpression() string {
+	if d.Type == fieldTypeDate {
+		return fmt.Sprintf("julianday(%s)", d.expression())
+	}
+	return d.expression()
+}
+
+// checkValue reports whether value is valid for the field.
+func (d AssetFieldDefinition) checkValue(value interface{}) error {
+	switch d.Type {
+	case fieldTypeNumber:
+		if _, ok := value.(float64); ok {
+			return nil
+		}
+		return validationError{message: fmt.Sprintf("custom field %s must be a number", d.Name)}
+	case fieldTypeBool:
+		if _, ok := value.(bool); ok {
+			return nil
+		}
+		return validationError{message: fmt.Sprintf("custom field %s must be true or false", d.Name)}
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return validationError{message: fmt.Sprintf("custom field %s must be a string", d.Name)}
+	}
+	switch d.Type {
+	case fieldTypeDate:
+		if parseAssetTimestamp(text, time.UTC) == nil {
+			return validationError{message: fmt.Sprintf("custom field %s must be a date, got %q", d.Name, text)}
+		}
+	case fieldTypeEnum:
+		for _, allowed := range d.AllowedValues {
+			if text == allowed {
+				return nil
+			}
+		}
+		return validationError{message: fmt.Sprintf("custom field %s must be one of %s", d.Name, strings.Join(d.AllowedValues, ", "))}
+	}
+	return nil
+}
+
+// parseText converts a CSV cell or filter value to the field's JSON type.
+func (d AssetFieldDefinition) parseText(value string) (interface{}, error) {
+	switch d.Type {
+	case fieldTypeNumber:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, validationError{message: fmt.Sprintf("custom field %s expects a number, got %q", d.Name, value)}
+		}
+		return parsed, nil
+	case fieldTypeBool:
+		parsed, err := parseOptionalBool(value)
+		if err != nil {
+			return nil, validationError{message: fmt.Sprintf("custom field %s expects true or false, got %q", d.Name, value)}
+		}
+		return parsed, nil
+	}
+	return value, nil
+}
+
+// assetFieldDefinitions are the custom fields of an org, sorted by name.
+type assetFieldDefinitions []AssetFieldDefinition
+
+func (defs assetFieldDefinitions) lookup(name string) (AssetFieldDefinition, bool) {
+	for _, def := range defs {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return AssetFieldDefinition{}, false
+}
+
+// resolve looks up a custom.<name> filter or sort key.
+func (defs assetFieldDefinitions) resolve(key string) (AssetFieldDefinition, error) {
+	def, ok := defs.lookup(strings.TrimPrefix(key, customFieldPrefix))
+	if !ok {
+		return AssetFieldDefinition{}, validationError{message: fmt.Sprintf("unknown custom field %q", strings.TrimPrefix(key, customFieldPrefix))}
+	}
+	return def, nil
+}
+
+// valueErrors checks custom field values against the definitions. Violations
+// name the field as custom_fields.<name>.
+func (defs assetFieldDefinitions) valueErrors(values map[string]interface{}) []AssetFieldError {
+	var violations []AssetFieldError
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		def, ok := defs.lookup(name)
+		if !ok {
+			violations = append(violations, AssetFieldError{Field: "custom_fields." + name, Rule: "unknown", Message: fmt.Sprintf("unknown custom field %q", name)})
+			continue
+		}
+		if err := def.checkValue(values[name]); err != nil {
+			violations = append(violations, AssetFieldError{Field: "custom_fields." + name, Rule: "type", Message: err.Error()})
+		}
+	}
+	for _, def := range defs {
+		if !def.Required {
+			continue
+		}
+		if value, ok := values[def.Name]; !ok || value == "" {
+			violations = append(violations, AssetFieldError{Field: "custom_fields." + def.Name, Rule: "required", Message: fmt.Sprintf("custom field %s is required", def.Name)})
+		}
+	}
+	return violations
+}
+
+// retain drops values of fields that are no longer defined.
+func (defs assetFieldDefinitions) retain(values map[string]interface{}) map[string]interface{} {
+	kept := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		if _, ok := defs.lookup(name); ok {
+			kept[name] = value
+		}
+	}
+	return kept
+}
+
+// buildFilter translates filter[custom.<name>] and filter[custom.<name>][op]
+// into SQL. Text, enum and date fields behave like the text and date columns;
+// bool fields only support equality.
+func (defs assetFieldDefinitions) buildFilter(name, op string, values []string, loc *time.Location) (string, []interface{}, []string, error) {
+	def, err := defs.resolve(name)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if op == "" {
+		return buildEqualityFilter(def.expression(), values, def.parseText)
+	}
+
+	col := assetOperatorColumn{column: def.expression(), kind: assetColumnText}
+	switch def.Type {
+	case fieldTypeNumber:
+		col.kind = assetColumnNumber
+	case fieldTypeDate:
+		col.kind = assetColumnDate
+	case fieldTypeBool:
+		return "", nil, nil, validationError{message: fmt.Sprintf("operator %s is not supported for %s", op, name)}
+	}
+	return buildColumnOperatorFilter(col, name, op, values, loc)
+}
+
+func listAssetFieldDefinitions(ctx context.Context, q sqlQueryer, orgID int64) (assetFieldDefinitions, error) {
+	rows, err := q.QueryContext(ctx, `SELECT name, type, required, allowed_values, created_at, updated_at FROM asset_field_definitions WHERE org_id = ? ORDER BY name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := assetFieldDefinitions{}
+	for rows.Next() {
+		var def AssetFieldDefinition
+		var allowedRaw string
+		if err := rows.Scan(&def.Name, &def.Type, &def.Required, &allowedRaw, &def.CreatedAt, &def.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(allowedRaw), &def.AllowedValues); err != nil {
+			return nil, fmt.Errorf("decode allowed values of %s: %w", def.Name, err)
+		}
+		if def.AllowedValues == nil {
+			def.AllowedValues = []string{}
+		}
+		defs = append(defs, def)
+	}
+	return defs, rows.Err()
+}
+
+func getAssetFieldDefinition(ctx context.Context, q sqlQueryer, orgID int64, name string) (AssetFieldDefinition, error) {
+	defs, err := listAssetFieldDefinitions(ctx, q, orgID)
+	if err != nil {
+		return AssetFieldDefinition{}, err
+	}
+	def, ok := defs.lookup(name)
+	if !ok {
+		return AssetFieldDefinition{}, errAssetFieldNotFound
+	}
+	return def, nil
+}
+
+// createAssetFieldDefinition adds a field. Like updates, a required field is
+// refused while live assets have no value for it, so on an org with assets a
+// field starts optional and is made required once it has been filled in.
+func (a *App) createAssetFieldDefinition(ctx context.Context, orgID int64, def AssetFieldDefinition) (AssetFieldDefinition, error) {
+	def.normalize()
+	if err := def.validate(); err != nil {
+		return AssetFieldDefinition{}, err
+	}
+	allowedJSON, err := json.Marshal(def.AllowedValues)
+	if err != nil {
+		return AssetFieldDefinition{}, err
+	}
+
+	err = a.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getAssetFieldDefinition(ctx, tx, orgID, def.Name); err == nil {
+			return httpError{status: http.StatusConflict, message: fmt.Sprintf("custom field %s already exists", def.Name)}
+		} else if !errors.Is(err, errAssetFieldNotFound) {
+			return err
+		}
+		if err := checkFieldSatisfied(ctx, tx, orgID, def); err != nil {
+			return err
+		}
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		_, err := tx.ExecContext(ctx, `INSERT INTO asset_field_definitions (org_id, name, type, required, allowed_values, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			orgID, def.Name, def.Type, def.Required, string(allowedJSON), now, now)
+		return err
+	})
+	if err != nil {
+		return AssetFieldDefinition{}, err
+	}
+	return getAssetFieldDefinition(ctx, a.db, orgID, def.Name)
+}
+
+// updateAssetFieldDefinition changes the required flag or allowed values of a
+// field. The type is fixed, and the change is refused while live assets hold
+// values it would make invalid.
+func (a *App) updateAssetFieldDefinition(ctx context.Context, orgID int64, name string, def AssetFieldDefinition) (AssetFieldDefinition, error) {
+	def.normalize()
+	if def.Name == "" {
+		def.Name = name
+	}
+	if def.Name != name {
+		return AssetFieldDefinition{}, validationError{message: "custom fields cannot be renamed"}
+	}
+	if err := def.validate(); err != nil {
+		return AssetFieldDefinition{}, err
+	}
+	allowedJSON, err := json.Marshal(def.AllowedValues)
+	if err != nil {
+		return AssetFieldDefinition{}, err
+	}
+
+	err = a.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := getAssetFieldDefinition(ctx, tx, orgID, name)
+		if err != nil {
+			return err
+		}
+		if current.Type != def.Type {
+			return validationError{message: fmt.Sprintf("custom field %s is of type %s and cannot be changed to %s", name, current.Type, def.Type)}
+		}
+		if err := checkFieldSatisfied(ctx, tx, orgID, def); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE asset_field_definitions SET required = ?, allowed_values = ?, updated_at = ? WHERE org_id = ? AND name = ?`,
+			def.Required, string(allowedJSON), time.Now().UTC().Format(time.RFC3339Nano), orgID, name)
+		return err
+	})
+	if err != nil {
+		return AssetFieldDefinition{}, err
+	}
+	return getAssetFieldDefinition(ctx, a.db, orgID, name)
+}
+
+// checkFieldSatisfied fails with 409 when live assets lack a value for a
+// required field or hold an enum value that is not allowed.
+func checkFieldSatisfied(ctx context.Context, q sqlQueryer, orgID int64, def AssetFieldDefinition) error {
+	conditions := []string{}
+	args := []interface{}{orgID}
+	if def.Required {
+		conditions = append(conditions, fmt.Sprintf("COALESCE(%s, '') = ''", def.expression()))
+	}
+	if def.Type == fieldTypeEnum {
+		placeholders := strings.TrimRight(strings.Repeat("?,", len(def.AllowedValues)), ",")
+		conditions = append(conditions, fmt.Sprintf("%s NOT IN (%s)", def.expression(), placeholders))
+		for _, value := range def.AllowedValues {
+			args = append(args, value)
+		}
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM assets WHERE org_id = ? AND deleted_at IS NULL AND (%s)`, strings.Join(conditions, " OR ")), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var count int
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if count > 0 {
+		return httpError{status: http.StatusConflict, message: fmt.Sprintf("%d assets have no valid value for custom field %s", count, def.Name)}
+	}
+	return nil
+}
+
+// deleteAssetFieldDefinition removes a field and its values from every asset
+// of the org, including those in the trash.
+func (a *App) deleteAssetFieldDefinition(ctx context.Context, orgID int64, name string) error {
+	return a.inTx(ctx, func(tx *sql.Tx) error {
+		def, err := getAssetFieldDefinition(ctx, tx, orgID, name)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM asset_field_definitions WHERE org_id = ? AND name = ?`, orgID, name); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`UPDATE assets SET custom_fields = json_remove(custom_fields, '$.%s') WHERE org_id = ? AND %s IS NOT NULL`, def.Name, def.expression()), orgID)
+		return err
+	})
+}
+
+// carryCustomFields keeps the stored custom field values when an update
+// payload leaves custom_fields out, so clients unaware of custom fields do not
+// erase them.
+func carryCustomFields(ctx context.Context, q sqlQueryer, orgID, assetID int64, payload *AssetPayload) error {
+	if payload.CustomFields != nil {
+		return nil
+	}
+	current, err := selectAssetPayload(ctx, q, orgID, assetID)
+	if err != nil {
+		return err
+	}
+	payload.CustomFields = current.CustomFields
+	return nil
+}
+
+func decodeAssetFieldDefinition(r *http.Request) (AssetFieldDefinition, error) {
+	defer func() {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	var def AssetFieldDefinition
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxAssetPayloadSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		return AssetFieldDefinition{}, validationError{message: "invalid JSON payload: " + err.Error()}
+	}
+	return def, nil
+}
+
+// handleAssetFields serves /custom-fields.
+func (a *App) handleAssetFields(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		defs, err := listAssetFieldDefinitions(r.Context(), a.db, orgID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": defs})
+	case http.MethodPost:
+		def, err := decodeAssetFieldDefinition(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		created, err := a.createAssetFieldDefinition(r.Context(), orgID, def)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"data": created})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAssetField serves /custom-fields/{name}.
+func (a *App) handleAssetField(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/custom-fields/"), "/")
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		def, err := getAssetFieldDefinition(r.Context(), a.db, orgID, name)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": def})
+	case http.MethodPut:
+		def, err := decodeAssetFieldDefinition(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		updated, err := a.updateAssetFieldDefinition(r.Context(), orgID, name, def)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": updated})
+	case http.MethodDelete:
+		if err := a.deleteAssetFieldDefinition(r.Context(), orgID, name); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,226 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func createTestField(t *testing.T, app *App, body string) *backend.CallResourceResponse {
+	t.Helper()
+	return callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "custom-fields", Body: []byte(body)})
+}
+
+// withCustomFields makes a CF-1 asset with the given custom fields.
+func withCustomFields(fields map[string]interface{}) func(*AssetPayload) {
+	return func(p *AssetPayload) {
+		p.Title = "Custom"
+		p.StationName = "CF-1"
+		p.CustomFields = fields
+	}
+}
+
+func TestAssetFieldDefinitions(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, body := range []string{
+		`{"name":"Serial","type":"text"}`,
+		`{"name":"firmware","type":"enum"}`,
+		`{"name":"serial","type":"text","allowed_values":["a"]}`,
+		`{"name":"serial","type":"json"}`,
+	} {
+		if resp := createTestField(t, app, body); resp.Status != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, resp.Status)
+		}
+	}
+
+	resp := createTestField(t, app, `{"name":"firmware","type":"enum","allowed_values":["v1"," v2 "]}`)
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", resp.Status, resp.Body)
+	}
+	var def AssetFieldDefinition
+	decodeData(t, resp, &def)
+	if def.Name != "firmware" || len(def.AllowedValues) != 2 || def.AllowedValues[1] != "v2" {
+		t.Fatalf("unexpected definition: %+v", def)
+	}
+	if resp := createTestField(t, app, `{"name":"firmware","type":"text"}`); resp.Status != http.StatusConflict {
+		t.Fatalf("expected duplicate to 409, got %d", resp.Status)
+	}
+	if resp := createTestField(t, app, `{"name":"serial","type":"text","required":true}`); resp.Status != http.StatusConflict {
+		t.Fatalf("expected required field on an org with assets to 409, got %d", resp.Status)
+	}
+
+	put := func(name, body string) *backend.CallResourceResponse {
+		return callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: "custom-fields/" + name, Body: []byte(body)})
+	}
+	if resp := put("firmware", `{"type":"text"}`); resp.Status != http.StatusBadRequest {
+		t.Fatalf("expected type change to 400, got %d", resp.Status)
+	}
+	if resp := put("missing", `{"type":"text"}`); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected unknown field to 404, got %d", resp.Status)
+	}
+
+	createTestAsset(t, app, withCustomFields(map[string]interface{}{"firmware": "v2"}))
+	if resp := put("firmware", `{"type":"enum","allowed_values":["v1"]}`); resp.Status != http.StatusConflict {
+		t.Fatalf("expected removing a used value to 409, got %d", resp.Status)
+	}
+	if resp := put("firmware", `{"type":"enum","allowed_values":["v1","v2","v3"]}`); resp.Status != http.StatusOK {
+		t.Fatalf("expected adding a value to succeed, got %d: %s", resp.Status, resp.Body)
+	}
+
+	var defs []AssetFieldDefinition
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "custom-fields"}), &defs)
+	if len(defs) != 1 || len(defs[0].AllowedValues) != 3 {
+		t.Fatalf("unexpected definitions: %+v", defs)
+	}
+	var other []AssetFieldDefinition
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "custom-fields", PluginContext: backend.PluginContext{OrgID: 2}}), &other)
+	if len(other) != 0 {
+		t.Fatalf("expected definitions to be scoped to the org, got %+v", other)
+	}
+}
+
+func TestAssetCustomFieldValues(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	for _, body := range []string{
+		`{"name":"serial","type":"text"}`,
+		`{"name":"capacity","type":"number"}`,
+		`{"name":"warranty","type":"date"}`,
+		`{"name":"indoor","type":"bool"}`,
+		`{"name":"firmware","type":"enum","allowed_values":["v1","v2"]}`,
+	} {
+		if resp := createTestField(t, app, body); resp.Status != http.StatusCreated {
+			t.Fatalf("%s: expected 201, got %d: %s", body, resp.Status, resp.Body)
+		}
+	}
+
+	record := createTestAsset(t, app, withCustomFields(map[string]interface{}{"serial": " SN-1 ", "capacity": 12.5, "warranty": "2027-01-01", "indoor": true, "firmware": "v1"}))
+	if record.CustomFields["serial"] != "SN-1" || record.CustomFields["capacity"] != 12.5 || record.CustomFields["indoor"] != true {
+		t.Fatalf("unexpected custom fields: %+v", record.CustomFields)
+	}
+
+	for _, fields := range []map[string]interface{}{
+		{"colour": "red"},
+		{"capacity": "12"},
+		{"warranty": "soon"},
+		{"indoor": "yes"},
+		{"firmware": "v9"},
+	} {
+		payload := AssetPayload{Title: "x", EntryDate: "2025-01-01", CommissioningDate: "2025-01-01", StationName: "x", Technician: "x", StartDate: "2025-01-01", EndDate: "2025-01-01", CustomFields: fields}
+		if _, err := app.createAsset(ctx, 1, payload); err == nil {
+			t.Fatalf("expected %v to be rejected", fields)
+		}
+	}
+
+	// A client that does not send custom_fields keeps the stored values.
+	payload := AssetPayload{Title: "Renamed", EntryDate: record.EntryDate, CommissioningDate: record.CommissioningDate, StationName: record.StationName, Technician: record.Technician, StartDate: record.StartDate, EndDate: record.EndDate}
+	updated, err := app.updateAsset(ctx, 1, record.ID, payload, 0)
+	if err != nil || updated.CustomFields["serial"] != "SN-1" {
+		t.Fatalf("expected update to keep custom fields, got %+v (%v)", updated.CustomFields, err)
+	}
+
+	resp := callAssetPatch(t, app, "assets/"+strconv.FormatInt(record.ID, 10), "", `{"custom_fields":{"serial":"SN-2","indoor":null}}`)
+	var patched AssetRecord
+	decodeData(t, resp, &patched)
+	if patched.CustomFields["serial"] != "SN-2" || patched.CustomFields["firmware"] != "v1" {
+		t.Fatalf("expected patch to merge custom fields, got %d %+v", resp.Status, patched.CustomFields)
+	}
+	if _, ok := patched.CustomFields["indoor"]; ok {
+		t.Fatalf("expected null to remove indoor, got %+v", patched.CustomFields)
+	}
+
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: "custom-fields/serial", Body: []byte(`{"type":"text","required":true}`)}); resp.Status != http.StatusConflict {
+		t.Fatalf("expected required serial to 409 while seed assets lack it, got %d", resp.Status)
+	}
+
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: "custom-fields/serial"}); resp.Status != http.StatusNoContent {
+		t.Fatalf("expected delete to 204, got %d", resp.Status)
+	}
+	after, err := app.getAsset(ctx, 1, record.ID)
+	if err != nil {
+		t.Fatalf("get asset: %v", err)
+	}
+	if _, ok := after.CustomFields["serial"]; ok || after.CustomFields["firmware"] != "v1" {
+		t.Fatalf("expected deleting the field to remove only its values, got %+v", after.CustomFields)
+	}
+}
+
+func TestListAssetsByCustomFields(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	createTestField(t, app, `{"name":"capacity","type":"number"}`)
+	createTestField(t, app, `{"name":"firmware","type":"enum","allowed_values":["v1","v2"]}`)
+	small := createTestAsset(t, app, withCustomFields(map[string]interface{}{"capacity": 5.0, "firmware": "v1"}))
+	large := createTestAsset(t, app, withCustomFields(map[string]interface{}{"capacity": 40.0, "firmware": "v2"}))
+	medium := createTestAsset(t, app, withCustomFields(map[string]interface{}{"capacity": 12.0, "firmware": "v2"}))
+
+	result, err := app.listAssets(ctx, 1, AssetListOptions{
+		Filters: map[string][]string{"custom.firmware": {"v2"}},
+		Sort:    &AssetListSort{Key: "custom.capacity", Direction: sortDirectionDesc},
+	})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(result.Records) != 2 || result.Records[0].ID != large.ID || result.Records[1].ID != medium.ID {
+		t.Fatalf("unexpected filtered records: %+v", result.Records)
+	}
+
+	result, err = app.listAssets(ctx, 1, AssetListOptions{Filters: map[string][]string{"custom.capacity[lt]": {"20"}}})
+	if err != nil || result.TotalCount != 2 || result.AppliedFilters["custom.capacity[lt]"][0] != "20" {
+		t.Fatalf("expected 2 assets under capacity 20, got %+v (%v)", result, err)
+	}
+
+	var r mockCallResourceResponseSender
+	query := url.Values{"filter[custom.capacity][between]": {"1,10"}, "sort": {"custom.capacity:asc"}}
+	err = app.CallResource(ctx, &backend.CallResourceRequest{
+		Method:        http.MethodGet,
+		Path:          "assets",
+		URL:           "assets?" + query.Encode(),
+		PluginContext: backend.PluginContext{OrgID: 1},
+	}, &r)
+	if err != nil || r.response.Status != http.StatusOK {
+		t.Fatalf("list request failed: %v %d", err, r.response.Status)
+	}
+	var listed []AssetRecord
+	decodeData(t, r.response, &listed)
+	if len(listed) != 1 || listed[0].ID != small.ID {
+		t.Fatalf("unexpected listed records: %+v", listed)
+	}
+
+	for _, opts := range []AssetListOptions{
+		{Filters: map[string][]string{"custom.unknown": {"x"}}},
+		{Filters: map[string][]string{"custom.capacity": {"many"}}},
+		{Sort: &AssetListSort{Key: "custom.unknown", Direction: sortDirectionAsc}},
+	} {
+		if _, err := app.listAssets(ctx, 1, opts); err == nil {
+			t.Fatalf("expected %+v to be rejected", opts)
+		}
+	}
+}
+
+func TestImportCustomFields(t *testing.T) {
+	app := newTestApp(t)
+	createTestField(t, app, `{"name":"capacity","type":"number"}`)
+
+	header := "Title,Entry Date,Commissioning Date,Station Name,Technician,Start Date,End Date,custom.capacity\n"
+	valid := "Tower A,2025-01-02,2025-01-03,TA-1,J. Doe,2025-01-04,2025-01-05,7.5\n"
+	invalid := "Tower B,2025-01-02,2025-01-03,TB-1,J. Doe,2025-01-04,2025-01-05,lots\n"
+	status, report := callAssetImport(t, app, url.Values{"dryRun": {"true"}}, header+valid+invalid)
+	if status != http.StatusOK || report.Valid != 1 || report.Errors[0].Column != "custom.capacity" {
+		t.Fatalf("unexpected report: %d %+v", status, report)
+	}
+
+	status, report = callAssetImport(t, app, nil, header+valid)
+	if status != http.StatusCreated || len(report.Created) != 1 {
+		t.Fatalf("expected import to succeed, got %d %+v", status, report)
+	}
+	record, err := app.getAsset(context.Background(), 1, report.Created[0])
+	if err != nil || record.CustomFields["capacity"] != 7.5 {
+		t.Fatalf("expected imported capacity, got %+v (%v)", record.CustomFields, err)
+	}
+}
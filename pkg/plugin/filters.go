@@ -0,0 +1,322 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type assetColumnType int
+
+const (
+	assetColumnText assetColumnType = iota
+	assetColumnDate
+	assetColumnNumber
+)
+
+const (
+	filterOpGT      = "gt"
+	filterOpGTE     = "gte"
+	filterOpLT      = "lt"
+	filterOpLTE     = "lte"
+	filterOpBetween = "between"
+	filterOpContain = "contains"
+	filterOpPrefix  = "prefix"
+	filterOpNotIn   = "not_in"
+)
+
+type assetOperatorColumn struct {
+	column string
+	kind   assetColumnType
+}
+
+// assetOperatorFilterColumns lists the columns accepted by filter[key][op]=value.
+var assetOperatorFilterColumns = map[string]assetOperatorColumn{
+	"title":              {column: "title", kind: assetColumnText},
+	"station_name":       {column: "station_name", kind: assetColumnText},
+	"technician":         {column: "technician", kind: assetColumnText},
+	"service":            {column: "service", kind: assetColumnText},
+	"entry_date":         {column: "entry_date", kind: assetColumnDate},
+	"commissioning_date": {column: "commissioning_date", kind: assetColumnDate},
+	"start_date":         {column: "start_date", kind: assetColumnDate},
+	"end_date":           {column: "end_date", kind: assetColumnDate},
+	"created_at":         {column: "created_at", kind: assetColumnDate},
+	"updated_at":         {column: "updated_at", kind: assetColumnDate},
+	"latitude":           {column: "latitude", kind: assetColumnNumber},
+	"longitude":          {column: "longitude", kind: assetColumnNumber},
+	"pitch":              {column: "pitch", kind: assetColumnNumber},
+	"roll":               {column: "roll", kind: assetColumnNumber},
+}
+
+var filterOperatorsByType = map[assetColumnType][]string{
+	assetColumnText:   {filterOpContain, filterOpPrefix, filterOpNotIn},
+	assetColumnDate:   {filterOpGT, filterOpGTE, filterOpLT, filterOpLTE, filterOpBetween, filterOpPrefix, filterOpNotIn},
+	assetColumnNumber: {filterOpGT, filterOpGTE, filterOpLT, filterOpLTE, filterOpBetween, filterOpNotIn},
+}
+
+// splitFilterKey separates "entry_date[gte]" into its column key and operator.
+// Keys without an operator are returned unchanged with an empty operator.
+func splitFilterKey(key string) (string, string) {
+	open := strings.Index(key, "[")
+	if open <= 0 || !strings.HasSuffix(key, "]") {
+		return key, ""
+	}
+	return strings.TrimSpace(key[:open]), strings.ToLower(strings.TrimSpace(key[open+1 : len(key)-1]))
+}
+
+func operatorFilterKey(name, op string) string {
+	return fmt.Sprintf("%s[%s]", name, op)
+}
+
+// buildOperatorFilter validates an operator filter and returns its SQL
+// condition, bind arguments and the normalized values to reflect in the
+// response metadata. Dates without an offset are read in loc.
+func buildOperatorFilter(name, op string, values []string, loc *time.Location) (string, []interface{}, []string, error) {
+	col, ok := assetOperatorFilterColumns[name]
+	if !ok {
+		return "", nil, nil, validationError{message: fmt.Sprintf("filter %s does not support operators", name)}
+	}
+	return buildColumnOperatorFilter(col, name, op, values, loc)
+}
+
+func buildColumnOperatorFilter(col assetOperatorColumn, name, op string, values []string, loc *time.Location) (string, []interface{}, []string, error) {
+	if !operatorAllowed(col.kind, op) {
+		return "", nil, nil, validationError{message: fmt.Sprintf("operator %s is not supported for %s", op, name)}
+	}
+
+	cleaned := make([]string, 0, len(values))
+	for _, raw := range values {
+		if op == filterOpBetween || op == filterOpNotIn {
+			for _, part := range strings.Split(raw, ",") {
+				if trimmed := strings.TrimSpace(part); trimmed != "" {
+					cleaned = append(cleaned, trimmed)
+				}
+			}
+			continue
+		}
+		if trimmed := strings.TrimSpace(raw); trimmed != "" {
+			cleaned = append(cleaned, trimmed)
+		}
+	}
+	if len(cleaned) == 0 {
+		return "", nil, nil, validationError{message: fmt.Sprintf("filter %s[%s] requires a value", name, op)}
+	}
+
+	switch op {
+	case filterOpGT, filterOpGTE, filterOpLT, filterOpLTE:
+		if len(cleaned) != 1 {
+			return "", nil, nil, validationError{message: fmt.Sprintf("filter %s[%s] expects a single value", name, op)}
+		}
+		clause, args, err := comparisonCondition(col, name, op, cleaned[0], loc)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		return clause, args, cleaned, nil
+	case filterOpBetween:
+		if len(cleaned) != 2 {
+			return "", nil, nil, validationError{message: fmt.Sprintf("filter %s[between] expects two values", name)}
+		}
+		lower, lowerArgs, err := comparisonCondition(col, name, filterOpGTE, cleaned[0], loc)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		upper, upperArgs, err := comparisonCondition(col, name, filterOpLTE, cleaned[1], loc)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		return fmt.Sprintf("(%s AND %s)", lower, upper), append(lowerArgs, upperArgs...), cleaned, nil
+	case filterOpContain, filterOpPrefix:
+		conditions := make([]string, 0, len(cleaned))
+		args := make([]interface{}, 0, len(cleaned))
+		for _, value := range cleaned {
+			pattern := escapeLikePattern(value) + "%"
+			if op == filterOpContain {
+				pattern = "%" + pattern
+			}
+			conditions = append(conditions, fmt.Sprintf(`%s LIKE ? ESCAPE '\'`, col.column))
+			args = append(args, pattern)
+		}
+		sort.Strings(cleaned)
+		if len(conditions) == 1 {
+			return conditions[0], args, cleaned, nil
+		}
+		return fmt.Sprintf("(%s)", strings.Join(conditions, " OR ")), args, cleaned, nil
+	case filterOpNotIn:
+		if col.kind == assetColumnDate {
+			clause, args, err := dateNotInCondition(col, name, cleaned, loc)
+			if err != nil {
+				return "", nil, nil, err
+			}
+			sort.Strings(cleaned)
+			return clause, args, cleaned, nil
+		}
+		args := make([]interface{}, 0, len(cleaned))
+		for _, value := range cleaned {
+			arg, err := filterValue(col, name, value)
+			if err != nil {
+				return "", nil, nil, err
+			}
+			args = append(args, arg)
+		}
+		sort.Strings(cleaned)
+		placeholders := strings.TrimRight(strings.Repeat("?,", len(args)), ",")
+		return fmt.Sprintf("(%s IS NULL OR %s NOT IN (%s))", col.column, col.column, placeholders), args, cleaned, nil
+	}
+	return "", nil, nil, validationError{message: fmt.Sprintf("operator %s is not supported for %s", op, name)}
+}
+
+// buildEqualityFilter matches column against any of values; emptyFilterValue
+// also matches NULL and empty strings. convert, when set, turns each value
+// into its bind argument. An empty clause means no value applies.
+func buildEqualityFilter(column string, values []string, convert func(string) (interface{}, error)) (string, []interface{}, []string, error) {
+	includeEmpty := false
+	cleaned := make([]string, 0, len(values))
+	for _, raw := range values {
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" {
+			continue
+		}
+		if trimmed == emptyFilterValue {
+			includeEmpty = true
+			continue
+		}
+		cleaned = append(cleaned, trimmed)
+	}
+	if len(cleaned) == 0 && !includeEmpty {
+		return "", nil, nil, nil
+	}
+	sort.Strings(cleaned)
+
+	args := make([]interface{}, 0, len(cleaned))
+	for _, value := range cleaned {
+		var arg interface{} = value
+		if convert != nil {
+			var err error
+			if arg, err = convert(value); err != nil {
+				return "", nil, nil, err
+			}
+		}
+		args = append(args, arg)
+	}
+
+	conditions := make([]string, 0, 2)
+	switch len(cleaned) {
+	case 0:
+		// no explicit values
+	case 1:
+		conditions = append(conditions, fmt.Sprintf("%s = ?", column))
+	default:
+		placeholders := strings.TrimRight(strings.Repeat("?,", len(cleaned)), ",")
+		conditions = append(conditions, fmt.Sprintf("%s IN (%s)", column, placeholders))
+	}
+	if includeEmpty {
+		conditions = append(conditions, fmt.Sprintf("(%s IS NULL OR %s = '')", column, column))
+	}
+
+	applied := append([]string{}, cleaned...)
+	if includeEmpty {
+		applied = append(applied, emptyFilterValue)
+	}
+	if len(conditions) == 1 {
+		return conditions[0], args, applied, nil
+	}
+	return fmt.Sprintf("(%s)", strings.Join(conditions, " OR ")), args, applied, nil
+}
+
+func operatorAllowed(kind assetColumnType, op string) bool {
+	for _, allowed := range filterOperatorsByType[kind] {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// comparisonCondition builds a range comparison. Dates are read in loc and
+// compared through assetDateComparison, and a date-only upper bound includes
+// the whole day.
+func comparisonCondition(col assetOperatorColumn, name, op, value string, loc *time.Location) (string, []interface{}, error) {
+	symbols := map[string]string{filterOpGT: ">", filterOpGTE: ">=", filterOpLT: "<", filterOpLTE: "<="}
+	symbol := symbols[op]
+
+	if col.kind == assetColumnNumber {
+		arg, err := filterValue(col, name, value)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s %s ?", col.column, symbol), []interface{}{arg}, nil
+	}
+
+	parsed := parseAssetTimestamp(value, loc)
+	if parsed == nil {
+		return "", nil, validationError{message: fmt.Sprintf("filter %s[%s] expects a date, got %q", name, op, value)}
+	}
+	bound := *parsed
+	if len(value) == len(assetDateOnlyLayout) {
+		switch op {
+		case filterOpLTE:
+			bound, symbol = nextAssetDay(bound, loc), "<"
+		case filterOpGT:
+			bound, symbol = nextAssetDay(bound, loc), ">="
+		}
+	}
+	clause, args := assetDateComparison(col.column, symbol, bound, loc)
+	return clause, args, nil
+}
+
+// dateNotInCondition excludes rows matching any of the given dates. Like the
+// range operators it reads them in loc, and a date-only value excludes the
+// whole day.
+func dateNotInCondition(col assetOperatorColumn, name string, values []string, loc *time.Location) (string, []interface{}, error) {
+	matches := make([]string, 0, len(values))
+	args := make([]interface{}, 0, 4*len(values))
+	for _, value := range values {
+		parsed := parseAssetTimestamp(value, loc)
+		if parsed == nil {
+			return "", nil, validationError{message: fmt.Sprintf("filter %s[%s] expects dates, got %q", name, filterOpNotIn, value)}
+		}
+		if len(value) == len(assetDateOnlyLayout) {
+			lower, lowerArgs := assetDateComparison(col.column, ">=", *parsed, loc)
+			upper, upperArgs := assetDateComparison(col.column, "<", nextAssetDay(*parsed, loc), loc)
+			matches = append(matches, fmt.Sprintf("(%s AND %s)", lower, upper))
+			args = append(append(args, lowerArgs...), upperArgs...)
+			continue
+		}
+		match, matchArgs := assetDateComparison(col.column, "=", *parsed, loc)
+		matches = append(matches, match)
+		args = append(args, matchArgs...)
+	}
+	return fmt.Sprintf("(julianday(%s) IS NULL OR NOT (%s))", col.column, strings.Join(matches, " OR ")), args, nil
+}
+
+// assetDateComparison compares the dates in expr with bound through
+// julianday(). A stored full-date is a day in loc, so it is compared with the
+// bound's wall time in loc; date-times are compared with the bound in UTC.
+func assetDateComparison(expr, symbol string, bound time.Time, loc *time.Location) (string, []interface{}) {
+	clause := fmt.Sprintf("julianday(%[1]s) %[2]s julianday(CASE WHEN length(%[1]s) = 10 THEN ? ELSE ? END)", expr, symbol)
+	return clause, []interface{}{bound.In(loc).Format(sqliteTimestampLayout), bound.UTC().Format(sqliteTimestampLayout)}
+}
+
+// nextAssetDay returns the start of the day in loc after the one starting at
+// day.
+func nextAssetDay(day time.Time, loc *time.Location) time.Time {
+	return day.In(loc).AddDate(0, 0, 1)
+}
+
+func filterValue(col assetOperatorColumn, name, value string) (interface{}, error) {
+	if col.kind != assetColumnNumber {
+		return value, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, validationError{message: fmt.Sprintf("filter %s expects a number, got %q", name, value)}
+	}
+	return parsed, nil
+}
+
+func escapeLikePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,154 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestListAssetsOperatorFilters(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, tc := range []struct {
+		name       string
+		orgID      int64
+		filters    map[string][]string
+		expStation []string
+	}{
+		{name: "pitch greater than", orgID: 2, filters: map[string][]string{"pitch[gt]": {"1"}}, expStation: []string{"WT-05"}},
+		{name: "entry date between", orgID: 1, filters: map[string][]string{"entry_date[between]": {"2025-01-01,2025-02-28"}}, expStation: []string{"WLS7-1273"}},
+		{name: "date-only upper bound includes the day", orgID: 1, filters: map[string][]string{"entry_date[lte]": {"2025-02-24"}}, expStation: []string{"WLS7-1273"}},
+		{name: "title contains", orgID: 1, filters: map[string][]string{"title[contains]": {"tower"}}, expStation: []string{"MT-202"}},
+		{name: "station prefix", orgID: 1, filters: map[string][]string{"station_name[prefix]": {"WLS"}}, expStation: []string{"WLS7-1273"}},
+		{name: "station not in", orgID: 2, filters: map[string][]string{"station_name[not_in]": {"WT-05"}}, expStation: []string{"SRS-11"}},
+		{name: "entry date not in whole day", orgID: 1, filters: map[string][]string{"entry_date[not_in]": {"2025-02-24"}}, expStation: []string{"MT-202"}},
+		{name: "entry date not in timestamp", orgID: 1, filters: map[string][]string{"entry_date[not_in]": {"2025-03-10T09:30:00Z"}}, expStation: []string{"WLS7-1273"}},
+		{name: "combined with equality", orgID: 1, filters: map[string][]string{"technician": {"A. Schmidt"}, "latitude[gte]": {"50"}}, expStation: []string{"MT-202"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := app.listAssets(context.Background(), tc.orgID, AssetListOptions{Filters: tc.filters})
+			if err != nil {
+				t.Fatalf("listAssets returned error: %v", err)
+			}
+			stations := make([]string, 0, len(result.Records))
+			for _, record := range result.Records {
+				stations = append(stations, record.StationName)
+			}
+			if !reflect.DeepEqual(stations, tc.expStation) {
+				t.Fatalf("expected %v, got %v", tc.expStation, stations)
+			}
+			for key := range tc.filters {
+				if _, ok := result.AppliedFilters[key]; !ok {
+					t.Fatalf("expected %s to be reflected in applied filters, got %v", key, result.AppliedFilters)
+				}
+			}
+		})
+	}
+}
+
+func TestDateFiltersUseOrgTimeZone(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.config.TimeZone = time.FixedZone("UTC+10", 10*3600)
+
+	// Late on 1 January in UTC is the morning of 2 January in the org zone,
+	// and the full-date 1 March starts at 14:00 UTC on 28 February.
+	for _, payload := range []AssetPayload{
+		{Title: "Timed", EntryDate: "2025-01-01T23:00:00Z", StartDate: "2025-01-01T23:00:00Z", EndDate: "2025-01-01T23:30:00Z"},
+		{Title: "Day", EntryDate: "2025-03-01", StartDate: "2025-03-01", EndDate: "2025-03-01"},
+	} {
+		payload.CommissioningDate, payload.StationName, payload.Technician = "2025-01-01", "TZ-1", "J. Doe"
+		if _, err := app.createAsset(ctx, 1, payload); err != nil {
+			t.Fatalf("create asset: %v", err)
+		}
+	}
+
+	day := func(hour int) time.Time { return time.Date(2025, 2, 28, hour, 0, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		name     string
+		opts     AssetListOptions
+		expected []string
+	}{
+		{name: "full-date lower bound", opts: AssetListOptions{Filters: map[string][]string{"entry_date[gte]": {"2025-01-02"}}}, expected: []string{"Day", "Timed"}},
+		{name: "full-date upper bound", opts: AssetListOptions{Filters: map[string][]string{"entry_date[lte]": {"2025-01-01"}}}, expected: []string{}},
+		{name: "local date-time bound", opts: AssetListOptions{Filters: map[string][]string{"entry_date[lt]": {"2025-01-02T10:00"}}}, expected: []string{"Timed"}},
+		{name: "date-time bound on a full-date", opts: AssetListOptions{Filters: map[string][]string{"start_date[gte]": {"2025-02-28T20:00:00Z"}}}, expected: []string{}},
+		{name: "full-date not in", opts: AssetListOptions{Filters: map[string][]string{"entry_date[not_in]": {"2025-01-02"}}}, expected: []string{"Day"}},
+		{name: "window inside the local day", opts: AssetListOptions{Window: &AssetTimeWindow{From: day(15), To: day(16)}}, expected: []string{"Day"}},
+		{name: "window after the local day", opts: AssetListOptions{Window: &AssetTimeWindow{From: day(24 + 15), To: day(24 + 16)}}, expected: []string{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Filters = withFilter(tc.opts.Filters, "station_name", "TZ-1")
+			result, err := app.listAssets(ctx, 1, tc.opts)
+			if err != nil {
+				t.Fatalf("listAssets returned error: %v", err)
+			}
+			titles := make([]string, 0, len(result.Records))
+			for _, record := range result.Records {
+				titles = append(titles, record.Title)
+			}
+			sort.Strings(titles)
+			if !reflect.DeepEqual(titles, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, titles)
+			}
+		})
+	}
+
+	rows, err := app.collectAssets(ctx, 1, AssetListOptions{Filters: map[string][]string{"title": {"Day"}}}, 0)
+	if err != nil {
+		t.Fatalf("collect assets: %v", err)
+	}
+	frame := newAssetAnnotationFrame(rows, app.location())
+	start, _ := frame.FieldByName("time")
+	end, _ := frame.FieldByName("timeEnd")
+	if !start.At(0).(time.Time).Equal(day(14)) || !end.At(0).(time.Time).Equal(day(24+14)) {
+		t.Fatalf("expected the annotation to span the local day, got %v to %v", start.At(0), end.At(0))
+	}
+}
+
+func withFilter(filters map[string][]string, key string, values ...string) map[string][]string {
+	merged := map[string][]string{key: values}
+	for k, v := range filters {
+		merged[k] = v
+	}
+	return merged
+}
+
+func TestListAssetsOperatorFilterValidation(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, filters := range []map[string][]string{
+		{"pitch[contains]": {"1"}},
+		{"entry_date[gte]": {"yesterday"}},
+		{"roll[lt]": {"abc"}},
+		{"latitude[between]": {"1"}},
+		{"images[gte]": {"1"}},
+		{"entry_date[not_in]": {"2025-02-24,garbage"}},
+	} {
+		_, err := app.listAssets(context.Background(), 1, AssetListOptions{Filters: filters})
+		var valErr validationError
+		if !errors.As(err, &valErr) {
+			t.Fatalf("expected validation error for %v, got %v", filters, err)
+		}
+	}
+}
+
+func TestParseAssetListOptionsOperatorFilters(t *testing.T) {
+	req := httptest.NewRequest("GET", "/assets?filter[entry_date][GTE]=2025-01-01&filter[station_name]=MT-202", nil)
+	opts, err := parseAssetListOptions(req)
+	if err != nil {
+		t.Fatalf("parseAssetListOptions returned error: %v", err)
+	}
+
+	expected := map[string][]string{
+		"entry_date[gte]": {"2025-01-01"},
+		"station_name":    {"MT-202"},
+	}
+	if !reflect.DeepEqual(opts.Filters, expected) {
+		t.Fatalf("expected filters %v, got %v", expected, opts.Filters)
+	}
+}
@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	distanceSortKey = "distance"
+	earthRadiusKm   = 6371.0
+)
+
+// AssetBoundingBox restricts results to coordinates inside the box. Boxes that
+// cross the antimeridian are not supported.
+type AssetBoundingBox struct {
+	MinLon float64 `json:"minLon"`
+	MinLat float64 `json:"minLat"`
+	MaxLon float64 `json:"maxLon"`
+	MaxLat float64 `json:"maxLat"`
+}
+
+// AssetNearFilter restricts results to coordinates within RadiusKm of a point.
+type AssetNearFilter struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+	RadiusKm  float64 `json:"radiusKm"`
+}
+
+func parseBoundingBox(raw string) (*AssetBoundingBox, error) {
+	values, err := parseCoordinateList(raw, 4)
+	if err != nil {
+		return nil, validationError{message: "bbox must be minLon,minLat,maxLon,maxLat"}
+	}
+	box := &AssetBoundingBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	switch {
+	case math.Abs(box.MinLat) > 90 || math.Abs(box.MaxLat) > 90:
+		return nil, validationError{message: "bbox latitudes must be between -90 and 90"}
+	case math.Abs(box.MinLon) > 180 || math.Abs(box.MaxLon) > 180:
+		return nil, validationError{message: "bbox longitudes must be between -180 and 180"}
+	case box.MinLat > box.MaxLat || box.MinLon > box.MaxLon:
+		return nil, validationError{message: "bbox minimum must not exceed maximum"}
+	}
+	return box, nil
+}
+
+func parseNearFilter(rawNear, rawRadius string) (*AssetNearFilter, error) {
+	values, err := parseCoordinateList(rawNear, 2)
+	if err != nil {
+		return nil, validationError{message: "near must be lat,lon"}
+	}
+	near := &AssetNearFilter{Latitude: values[0], Longitude: values[1]}
+	if math.Abs(near.Latitude) > 90 || math.Abs(near.Longitude) > 180 {
+		return nil, validationError{message: "near must be a valid lat,lon"}
+	}
+	radius, err := strconv.ParseFloat(strings.TrimSpace(rawRadius), 64)
+	if err != nil || radius <= 0 || math.IsInf(radius, 0) {
+		return nil, validationError{message: "radiusKm must be a positive number when near is set"}
+	}
+	near.RadiusKm = radius
+	return near, nil
+}
+
+func parseCoordinateList(raw string, expected int) ([]float64, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != expected {
+		return nil, fmt.Errorf("expected %d values, got %d", expected, len(parts))
+	}
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("invalid coordinate %q", part)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// boundingBox returns a box enclosing the search circle, used to narrow the
+// candidate rows through the R*Tree index before the exact distance check.
+// Circles that reach a pole or cross the antimeridian span all longitudes.
+func (n AssetNearFilter) boundingBox() AssetBoundingBox {
+	angular := n.RadiusKm / earthRadiusKm
+	latDelta := angular * 180 / math.Pi
+	box := AssetBoundingBox{
+		MinLat: math.Max(n.Latitude-latDelta, -90),
+		MaxLat: math.Min(n.Latitude+latDelta, 90),
+		MinLon: -180,
+		MaxLon: 180,
+	}
+	if box.MinLat == -90 || box.MaxLat == 90 {
+		return box
+	}
+	ratio := math.Sin(angular) / math.Cos(n.Latitude*math.Pi/180)
+	if ratio >= 1 {
+		return box
+	}
+	lonDelta := math.Asin(ratio) * 180 / math.Pi
+	if n.Longitude-lonDelta >= -180 && n.Longitude+lonDelta <= 180 {
+		box.MinLon = n.Longitude - lonDelta
+		box.MaxLon = n.Longitude + lonDelta
+	}
+	return box
+}
+
+// distanceExpression is an equirectangular approximation of the squared
+// distance in degrees. It only orders rows in SQL; the radius is checked against
+// the haversine distance_km of each row. The longitude difference is wrapped
+// into [-180, 180] so points across the antimeridian are measured the short
+// way. The inputs are parsed floats, so they are safe to format into the SQL
+// text.
+func (n AssetNearFilter) distanceExpression() string {
+	lat := strconv.FormatFloat(n.Latitude, 'f', -1, 64)
+	lon := strconv.FormatFloat(n.Longitude, 'f', -1, 64)
+	scale := strconv.FormatFloat(math.Cos(n.Latitude*math.Pi/180), 'f', -1, 64)
+	dLon := fmt.Sprintf("((longitude - %[1]s) - 360.0 * round((longitude - %[1]s) / 360.0))", lon)
+	return fmt.Sprintf("((%[2]s * %[3]s) * (%[2]s * %[3]s) + (latitude - %[1]s) * (latitude - %[1]s))", lat, dLon, scale)
+}
+
+// locationConditions returns the where clauses and arguments applying the bbox
+// filter of opts and the index prefilter of near. Rows passing the prefilter
+// still have to be checked against the radius.
+func (opts AssetListOptions) locationConditions() ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	addBox := func(box AssetBoundingBox) {
+		conditions = append(conditions,
+			"id IN (SELECT id FROM assets_rtree WHERE max_lon >= ? AND min_lon <= ? AND max_lat >= ? AND min_lat <= ?)",
+			"longitude BETWEEN ? AND ?",
+			"latitude BETWEEN ? AND ?",
+		)
+		args = append(args, box.MinLon, box.MaxLon, box.MinLat, box.MaxLat, box.MinLon, box.MaxLon, box.MinLat, box.MaxLat)
+	}
+	if opts.BBox != nil {
+		addBox(*opts.BBox)
+	}
+	if opts.Near != nil {
+		addBox(opts.Near.boundingBox())
+	}
+	return conditions, args
+}
+
+// haversineKm returns the great-circle distance between two points.
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListAssetsNearSortedByDistance(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	near := &AssetNearFilter{Latitude: 50.507647, Longitude: 10.524083, RadiusKm: 5}
+
+	result, err := app.listAssets(ctx, 1, AssetListOptions{
+		Near: near,
+		Sort: &AssetListSort{Key: distanceSortKey, Direction: sortDirectionAsc},
+	})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if len(result.Records) != 2 {
+		t.Fatalf("expected two assets within 5 km, got %d", len(result.Records))
+	}
+	first, second := result.Records[0], result.Records[1]
+	if first.StationName != "WLS7-1273" || second.StationName != "MT-202" {
+		t.Fatalf("unexpected order: %s, %s", first.StationName, second.StationName)
+	}
+	if first.DistanceKm == nil || *first.DistanceKm > 0.001 {
+		t.Fatalf("expected zero distance for the origin asset, got %v", first.DistanceKm)
+	}
+	if second.DistanceKm == nil || math.Abs(*second.DistanceKm-0.67) > 0.05 {
+		t.Fatalf("expected roughly 0.67 km to MT-202, got %v", second.DistanceKm)
+	}
+
+	near.RadiusKm = 0.5
+	result, err = app.listAssets(ctx, 1, AssetListOptions{Near: near})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if len(result.Records) != 1 || result.Records[0].StationName != "WLS7-1273" {
+		t.Fatalf("expected only the origin asset within 0.5 km, got %d", len(result.Records))
+	}
+}
+
+func TestListAssetsBoundingBoxFollowsUpdates(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	box := &AssetBoundingBox{MinLon: 10.5, MinLat: 50.5, MaxLon: 10.54, MaxLat: 50.52}
+
+	result, err := app.listAssets(ctx, 1, AssetListOptions{BBox: box})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if result.TotalCount != 2 {
+		t.Fatalf("expected both org 1 assets in the box, got %d", result.TotalCount)
+	}
+
+	asset, err := app.getAsset(ctx, 1, 2)
+	if err != nil {
+		t.Fatalf("getAsset returned error: %v", err)
+	}
+	payload := AssetPayload{
+		Title:             asset.Title,
+		EntryDate:         asset.EntryDate,
+		CommissioningDate: asset.CommissioningDate,
+		StationName:       asset.StationName,
+		Technician:        asset.Technician,
+		StartDate:         asset.StartDate,
+		EndDate:           asset.EndDate,
+		Latitude:          48.1,
+		Longitude:         11.5,
+	}
+	if _, err := app.updateAsset(ctx, 1, 2, payload, 0); err != nil {
+		t.Fatalf("updateAsset returned error: %v", err)
+	}
+
+	result, err = app.listAssets(ctx, 1, AssetListOptions{BBox: box})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if result.TotalCount != 1 || result.Records[0].ID == 2 {
+		t.Fatalf("expected moved asset to leave the box, got %+v", result.Records)
+	}
+}
+
+func TestListAssetsDistanceSortRequiresNear(t *testing.T) {
+	app := newTestApp(t)
+
+	_, err := app.listAssets(context.Background(), 1, AssetListOptions{
+		Sort: &AssetListSort{Key: distanceSortKey, Direction: sortDirectionAsc},
+	})
+	var valErr validationError
+	if !errors.As(err, &valErr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
+
+func TestParseAssetListOptionsLocation(t *testing.T) {
+	for _, tc := range []struct {
+		query  string
+		expErr bool
+	}{
+		{query: "bbox=10.5,50.5,10.6,50.6"},
+		{query: "near=50.5,10.5&radiusKm=5"},
+		{query: "bbox=10.5,50.5,10.6", expErr: true},
+		{query: "bbox=10.6,50.5,10.5,50.6", expErr: true},
+		{query: "near=50.5,10.5", expErr: true},
+		{query: "near=95,10.5&radiusKm=5", expErr: true},
+	} {
+		_, err := parseAssetListOptions(httptest.NewRequest("GET", "/assets?"+tc.query, nil))
+		if tc.expErr != (err != nil) {
+			t.Fatalf("%s: expected error=%v, got %v", tc.query, tc.expErr, err)
+		}
+	}
+}
+
+func TestListAssetsNearAcrossAntimeridian(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	created, err := app.createAsset(ctx, 1, AssetPayload{
+		Title:             "Fiji buoy",
+		EntryDate:         "2025-05-01",
+		CommissioningDate: "2025-05-01",
+		StationName:       "FJ-01",
+		Technician:        "A. Schmidt",
+		StartDate:         "2025-05-01",
+		EndDate:           "2025-05-02",
+		Latitude:          -17,
+		Longitude:         179.99,
+	})
+	if err != nil {
+		t.Fatalf("create asset: %v", err)
+	}
+
+	result, err := app.listAssets(ctx, 1, AssetListOptions{Near: &AssetNearFilter{Latitude: -17, Longitude: -179.99, RadiusKm: 5}})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if result.TotalCount != 1 || result.Records[0].ID != created.ID {
+		t.Fatalf("expected the asset across the antimeridian, got %+v", result.Records)
+	}
+	if d := *result.Records[0].DistanceKm; d > 5 {
+		t.Fatalf("expected distance within radius, got %v", d)
+	}
+}
+
+func TestListAssetsNearRadiusUsesGreatCircleDistance(t *testing.T) {
+	app := newTestApp(t)
+	near := &AssetNearFilter{Latitude: 70, Longitude: 10, RadiusKm: 1500}
+
+	for _, coords := range [][2]float64{{75, 40}, {60, 10}, {50.5, 10.5}} {
+		_, err := app.createAsset(context.Background(), 1, AssetPayload{
+			Title: "Arctic survey", EntryDate: "2025-05-01", CommissioningDate: "2025-05-01", StationName: "AR",
+			Technician: "A. Schmidt", StartDate: "2025-05-01", EndDate: "2025-05-02", Latitude: coords[0], Longitude: coords[1],
+		})
+		if err != nil {
+			t.Fatalf("create asset: %v", err)
+		}
+	}
+
+	result, err := app.listAssets(context.Background(), 1, AssetListOptions{Near: near, PageSize: 1})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if result.TotalCount != 2 || result.PageCount != 2 {
+		t.Fatalf("expected two matches over two pages, got %d over %d", result.TotalCount, result.PageCount)
+	}
+	for _, record := range result.Records {
+		if *record.DistanceKm > near.RadiusKm {
+			t.Fatalf("record %d reported %v km outside radius", record.ID, *record.DistanceKm)
+		}
+	}
+}
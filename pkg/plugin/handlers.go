@@ -26,16 +26,26 @@ func (e httpError) Error() string {
 	return e.message
 }
 
+// assetListMeta describes a page of the asset list. Page is nil when the list
+// was read at a cursor; PageCount, TotalCount and TagCounts are unset when the
+// matches were not counted.
 type assetListMeta struct {
 	StorageConfigured  bool                `json:"storageConfigured"`
 	MaxUploadSizeBytes int64               `json:"maxUploadSizeBytes"`
 	MaxUploadSizeMb    int64               `json:"maxUploadSizeMb"`
-	Page               int                 `json:"page"`
+	Page               *int                `json:"page,omitempty"`
 	PageSize           int                 `json:"pageSize"`
-	PageCount          int                 `json:"pageCount"`
-	TotalCount         int64               `json:"totalCount"`
+	PageCount          *int                `json:"pageCount,omitempty"`
+	TotalCount         *int64              `json:"totalCount,omitempty"`
+	NextCursor         string              `json:"nextCursor,omitempty"`
+	PrevCursor         string              `json:"prevCursor,omitempty"`
 	Filters            map[string][]string `json:"filters"`
 	Sort               *AssetListSort      `json:"sort,omitempty"`
+	Query              string              `json:"q,omitempty"`
+	BBox               *AssetBoundingBox   `json:"bbox,omitempty"`
+	Near               *AssetNearFilter    `json:"near,omitempty"`
+	IncludeDescendants bool                `json:"includeDescendants,omitempty"`
+	TagCounts          []AssetTagCount     `json:"tagCounts,omitempty"`
 	StorageError       string              `json:"storageError,omitempty"`
 }
 
@@ -53,23 +63,46 @@ func (a *App) handleAssetsCollection(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		opts := parseAssetListOptions(r)
+		opts, err := parseAssetListOptions(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		switch {
+		case acceptsMediaType(r, geoJSONContentType):
+			a.writeAssetsGeoJSON(w, r, orgID, opts)
+			return
+		case acceptsMediaType(r, csvContentType):
+			a.writeAssetsCSV(w, r, orgID, opts)
+			return
+		}
 		result, err := a.listAssets(r.Context(), orgID, opts)
 		if err != nil {
 			log.Printf("listAssets failed: %v", err)
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+			writeHTTPError(w, err)
 			return
 		}
 		meta := assetListMeta{
 			StorageConfigured:  a.storageConfigured(),
 			MaxUploadSizeBytes: a.config.Storage.MaxUploadSizeBytes,
 			MaxUploadSizeMb:    a.config.Storage.MaxUploadSizeMB,
-			Page:               result.Page,
 			PageSize:           result.PageSize,
-			PageCount:          result.PageCount,
-			TotalCount:         result.TotalCount,
+			NextCursor:         result.NextCursor,
+			PrevCursor:         result.PrevCursor,
 			Filters:            result.AppliedFilters,
 			Sort:               result.AppliedSort,
+			Query:              result.AppliedSearch,
+			BBox:               result.AppliedBBox,
+			Near:               result.AppliedNear,
+			IncludeDescendants: result.AppliedIncludeDescendants,
+			TagCounts:          result.TagCounts,
+		}
+		if opts.Cursor == "" {
+			meta.Page = &result.Page
+		}
+		if !opts.SkipCount {
+			meta.PageCount = &result.PageCount
+			meta.TotalCount = &result.TotalCount
 		}
 		if meta.Filters == nil {
 			meta.Filters = map[string][]string{}
@@ -89,6 +122,7 @@ func (a *App) handleAssetsCollection(w http.ResponseWriter, r *http.Request) {
 			writeHTTPError(w, err)
 			return
 		}
+		w.Header().Set("ETag", assetETag(asset.Version))
 		writeJSON(w, http.StatusCreated, map[string]interface{}{"data": asset})
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -127,24 +161,54 @@ func (a *App) handleAssetResource(w http.ResponseWriter, r *http.Request) {
 				writeHTTPError(w, err)
 				return
 			}
+			w.Header().Set("ETag", assetETag(asset.Version))
 			writeJSON(w, http.StatusOK, map[string]interface{}{"data": asset})
 		case http.MethodPut:
+			ifVersion, err := parseIfMatch(r)
+			if err != nil {
+				writeHTTPError(w, err)
+				return
+			}
 			payload, err := decodeAssetPayload(r)
 			if err != nil {
 				writeHTTPError(w, err)
 				return
 			}
-			asset, err := a.updateAsset(r.Context(), orgID, assetID, payload)
+			asset, err := a.updateAsset(r.Context(), orgID, assetID, payload, ifVersion)
+			if err != nil {
+				a.writeAssetMutationError(w, r, orgID, assetID, err)
+				return
+			}
+			w.Header().Set("ETag", assetETag(asset.Version))
+			writeJSON(w, http.StatusOK, map[string]interface{}{"data": asset})
+		case http.MethodPatch:
+			ifVersion, err := parseIfMatch(r)
+			if err != nil {
+				writeHTTPError(w, err)
+				return
+			}
+			patch, err := decodeMergePatch(r)
 			if err != nil {
 				writeHTTPError(w, err)
 				return
 			}
+			asset, err := a.patchAsset(r.Context(), orgID, assetID, patch, ifVersion)
+			if err != nil {
+				a.writeAssetMutationError(w, r, orgID, assetID, err)
+				return
+			}
+			w.Header().Set("ETag", assetETag(asset.Version))
 			writeJSON(w, http.StatusOK, map[string]interface{}{"data": asset})
 		case http.MethodDelete:
-			if err := a.deleteAsset(r.Context(), orgID, assetID); err != nil {
+			ifVersion, err := parseIfMatch(r)
+			if err != nil {
 				writeHTTPError(w, err)
 				return
 			}
+			if err := a.deleteAsset(r.Context(), orgID, assetID, ifVersion); err != nil {
+				a.writeAssetMutationError(w, r, orgID, assetID, err)
+				return
+			}
 			w.WriteHeader(http.StatusNoContent)
 		default:
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -152,6 +216,30 @@ func (a *App) handleAssetResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(segments) == 2 && segments[1] == "restore" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		asset, err := a.restoreTrashedAsset(r.Context(), orgID, assetID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": asset})
+		return
+	}
+
+	if len(segments) == 2 && (segments[1] == "subtree" || segments[1] == "ancestors" || segments[1] == "move") {
+		a.handleAssetHierarchy(w, r, orgID, assetID, segments[1])
+		return
+	}
+
+	if len(segments) >= 2 && segments[1] == "history" {
+		a.handleAssetHistory(w, r, orgID, assetID, segments[2:])
+		return
+	}
+
 	if len(segments) >= 2 && segments[1] == "files" {
 		switch {
 		case r.Method == http.MethodPost && len(segments) == 2:
@@ -293,6 +381,8 @@ func (a *App) handleAppSettings(w http.ResponseWriter, r *http.Request) {
 			"bucketName":      a.config.Storage.Bucket,
 			"objectPrefix":    a.config.Storage.Prefix,
 			"maxUploadSizeMb": a.config.Storage.MaxUploadSizeMB,
+			"timeZone":        a.location().String(),
+			"validationRules": a.config.ValidationRules,
 		},
 		"secureJsonFields": map[string]bool{
 			"apiKey":            a.config.APIKey != "",
@@ -304,7 +394,7 @@ func (a *App) handleAppSettings(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, payload)
 }
 
-func parseAssetListOptions(r *http.Request) AssetListOptions {
+func parseAssetListOptions(r *http.Request) (AssetListOptions, error) {
 	query := r.URL.Query()
 
 	page, _ := strconv.Atoi(strings.TrimSpace(query.Get("page")))
@@ -319,6 +409,14 @@ func parseAssetListOptions(r *http.Request) AssetListOptions {
 			continue
 		}
 		name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]"))
+		if column, op, ok := strings.Cut(name, "]["); ok {
+			// filter[column][op]=value
+			column, op = strings.TrimSpace(column), strings.ToLower(strings.TrimSpace(op))
+			if column == "" || op == "" {
+				continue
+			}
+			name = operatorFilterKey(column, op)
+		}
 		if name == "" {
 			continue
 		}
@@ -362,12 +460,93 @@ func parseAssetListOptions(r *http.Request) AssetListOptions {
 		}
 	}
 
-	return AssetListOptions{
+	opts := AssetListOptions{
 		Page:     page,
 		PageSize: pageSize,
 		Filters:  parsedFilters,
 		Sort:     sortOption,
+		Search:   strings.TrimSpace(query.Get("q")),
+	}
+
+	if raw := strings.TrimSpace(query.Get("bbox")); raw != "" {
+		box, err := parseBoundingBox(raw)
+		if err != nil {
+			return AssetListOptions{}, err
+		}
+		opts.BBox = box
+	}
+	if raw := strings.TrimSpace(query.Get("near")); raw != "" {
+		near, err := parseNearFilter(raw, query.Get("radiusKm"))
+		if err != nil {
+			return AssetListOptions{}, err
+		}
+		opts.Near = near
+	}
+	includeDescendants, err := parseOptionalBool(query.Get("includeDescendants"))
+	if err != nil {
+		return AssetListOptions{}, validationError{message: "includeDescendants must be true or false"}
+	}
+	opts.IncludeDescendants = includeDescendants
+
+	// A cursor replaces page, and the matches are only counted for it when
+	// asked with count=true.
+	opts.Cursor = strings.TrimSpace(query.Get("cursor"))
+	if raw := strings.TrimSpace(query.Get("count")); raw != "" {
+		count, err := strconv.ParseBool(raw)
+		if err != nil {
+			return AssetListOptions{}, validationError{message: "count must be true or false"}
+		}
+		opts.SkipCount = !count
+	} else {
+		opts.SkipCount = opts.Cursor != ""
+	}
+
+	return opts, nil
+}
+
+func assetETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parseIfMatch returns the asset version named by the If-Match header, or 0
+// when the header is absent or "*". Only a single strong entity tag is
+// accepted.
+func parseIfMatch(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+	invalid := validationError{message: "If-Match must be a single entity tag returned as ETag"}
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return 0, invalid
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 1 {
+		return 0, invalid
+	}
+	return version, nil
+}
+
+// writeAssetMutationError answers a failed If-Match precondition with 412 and
+// the current record, so clients can merge their changes; other errors go
+// through writeHTTPError.
+func (a *App) writeAssetMutationError(w http.ResponseWriter, r *http.Request, orgID, assetID int64, err error) {
+	var mismatch errAssetVersionMismatch
+	if !errors.As(err, &mismatch) {
+		writeHTTPError(w, err)
+		return
+	}
+	current, getErr := a.getAsset(r.Context(), orgID, assetID)
+	if getErr != nil {
+		writeHTTPError(w, getErr)
+		return
 	}
+	w.Header().Set("ETag", assetETag(current.Version))
+	writeJSON(w, http.StatusPreconditionFailed, map[string]interface{}{
+		"error": mismatch.Error(),
+		"data":  current,
+	})
 }
 
 func decodeAssetPayload(r *http.Request) (AssetPayload, error) {
@@ -426,12 +605,34 @@ func writeHTTPError(w http.ResponseWriter, err error) {
 	switch {
 	case errors.As(err, &httpErr):
 		http.Error(w, httpErr.message, httpErr.status)
+	case errors.As(err, &valErr) && len(valErr.fields) > 0:
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"message": valErr.message, "errors": valErr.fields})
 	case errors.As(err, &valErr):
 		http.Error(w, valErr.Error(), http.StatusBadRequest)
 	case errors.Is(err, errAssetNotFound):
 		http.Error(w, "not found", http.StatusNotFound)
 	case errors.Is(err, errAssetFileNotFound):
 		http.Error(w, "file not found", http.StatusNotFound)
+	case errors.Is(err, errAssetRevisionNotFound):
+		http.Error(w, "revision not found", http.StatusNotFound)
+	case errors.Is(err, errAssetFieldNotFound):
+		http.Error(w, "custom field not found", http.StatusNotFound)
+	case errors.Is(err, errStationNotFound):
+		http.Error(w, "station not found", http.StatusNotFound)
+	case errors.Is(err, errPersonNotFound):
+		http.Error(w, "person not found", http.StatusNotFound)
+	case errors.Is(err, errOrganizationNotFound):
+		http.Error(w, "organization not found", http.StatusNotFound)
+	case errors.Is(err, errTagNotFound):
+		http.Error(w, "tag not found", http.StatusNotFound)
+	case errors.Is(err, errScheduleNotFound):
+		http.Error(w, "schedule not found", http.StatusNotFound)
+	case errors.Is(err, errWebhookNotFound):
+		http.Error(w, "webhook not found", http.StatusNotFound)
+	case errors.Is(err, errWebhookDeliveryNotFound):
+		http.Error(w, "delivery not found", http.StatusNotFound)
+	case errors.As(err, new(errAssetVersionMismatch)):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
 	default:
 		log.Printf("handler error: %v", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
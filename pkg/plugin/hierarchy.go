@@ -0,0 +1,241 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultAssetTreeDepth = 10
+	maxAssetTreeDepth     = 50
+)
+
+// AssetTreeNode is an asset in a hierarchy listing. Depth counts from the
+// requested asset for subtrees and from the root for ancestors.
+type AssetTreeNode struct {
+	ID          int64           `json:"id"`
+	Title       string          `json:"title"`
+	StationName string          `json:"station_name"`
+	Service     string          `json:"service,omitempty"`
+	ParentID    int64           `json:"parent_id,omitempty"`
+	Depth       int             `json:"depth"`
+	Children    []AssetTreeNode `json:"children,omitempty"`
+}
+
+// setAssetParent nests an asset under parentID, or makes it a root for 0. The
+// parent must be a live asset of the same org that is not the asset itself
+// or one of its descendants.
+func setAssetParent(ctx context.Context, q sqlExecQueryer, orgID, assetID, parentID int64) error {
+	var parent interface{}
+	if parentID != 0 {
+		if parentID < 0 {
+			return validationError{message: "parent_id must be an asset id"}
+		}
+		if parentID == assetID {
+			return validationError{message: "an asset cannot be its own parent"}
+		}
+		found, err := queryDirectoryID(ctx, q, `SELECT id FROM assets WHERE org_id = ? AND id = ? AND deleted_at IS NULL`, orgID, parentID)
+		if err != nil {
+			return err
+		}
+		if found == 0 {
+			return validationError{message: fmt.Sprintf("parent_id %d does not exist", parentID)}
+		}
+		// UNION stops the walk should the stored links ever form a loop.
+		cycle, err := queryDirectoryID(ctx, q, `WITH RECURSIVE chain(id, parent_id) AS (
+    SELECT id, parent_id FROM assets WHERE id = ?
+    UNION
+    SELECT a.id, a.parent_id FROM assets AS a JOIN chain ON a.id = chain.parent_id
+)
+SELECT id FROM chain WHERE id = ?`, parentID, assetID)
+		if err != nil {
+			return err
+		}
+		if cycle != 0 {
+			return validationError{message: fmt.Sprintf("parent_id %d is a descendant of asset %d", parentID, assetID)}
+		}
+		parent = parentID
+	}
+	_, err := q.ExecContext(ctx, `UPDATE assets SET parent_id = ? WHERE org_id = ? AND id = ?`, parent, orgID, assetID)
+	return err
+}
+
+func scanAssetTreeNode(scan func(dest ...interface{}) error) (AssetTreeNode, error) {
+	var node AssetTreeNode
+	var service sqlNullString
+	var parentID sql.NullInt64
+	if err := scan(&node.ID, &node.Title, &node.StationName, &service, &parentID, &node.Depth); err != nil {
+		return AssetTreeNode{}, err
+	}
+	node.Service = service.String
+	node.ParentID = parentID.Int64
+	return node, nil
+}
+
+// getAssetSubtree returns an asset with its live descendants up to maxDepth
+// levels down. A trashed asset hides its subtree.
+func (a *App) getAssetSubtree(ctx context.Context, orgID, assetID int64, maxDepth int) (AssetTreeNode, error) {
+	rows, err := a.db.QueryContext(ctx, `WITH RECURSIVE tree(id, depth) AS (
+    SELECT id, 0 FROM assets WHERE org_id = ? AND id = ? AND deleted_at IS NULL
+    UNION
+    SELECT a.id, tree.depth + 1 FROM assets AS a JOIN tree ON a.parent_id = tree.id
+    WHERE a.deleted_at IS NULL AND tree.depth < ?
+)
+SELECT a.id, a.title, a.station_name, a.service, a.parent_id, tree.depth
+FROM tree JOIN assets AS a ON a.id = tree.id
+ORDER BY tree.depth DESC, a.title, a.id`, orgID, assetID, maxDepth)
+	if err != nil {
+		return AssetTreeNode{}, err
+	}
+	defer rows.Close()
+
+	// Rows come deepest first, so every node's children are complete before
+	// the node is attached to its own parent.
+	children := map[int64][]AssetTreeNode{}
+	var root *AssetTreeNode
+	for rows.Next() {
+		node, err := scanAssetTreeNode(rows.Scan)
+		if err != nil {
+			return AssetTreeNode{}, err
+		}
+		node.Children = children[node.ID]
+		delete(children, node.ID)
+		if node.Depth == 0 {
+			root = &node
+			continue
+		}
+		children[node.ParentID] = append(children[node.ParentID], node)
+	}
+	if err := rows.Err(); err != nil {
+		return AssetTreeNode{}, err
+	}
+	if root == nil {
+		return AssetTreeNode{}, errAssetNotFound
+	}
+	return *root, nil
+}
+
+// listAssetAncestors returns the live ancestors of an asset, root first. The
+// walk stops at a trashed ancestor.
+func (a *App) listAssetAncestors(ctx context.Context, orgID, assetID int64) ([]AssetTreeNode, error) {
+	rows, err := a.db.QueryContext(ctx, `WITH RECURSIVE chain(id, parent_id, distance) AS (
+    SELECT id, parent_id, 0 FROM assets WHERE org_id = ? AND id = ? AND deleted_at IS NULL
+    UNION
+    SELECT a.id, a.parent_id, chain.distance + 1 FROM assets AS a JOIN chain ON a.id = chain.parent_id
+    WHERE a.deleted_at IS NULL
+)
+SELECT a.id, a.title, a.station_name, a.service, a.parent_id, chain.distance
+FROM chain JOIN assets AS a ON a.id = chain.id
+ORDER BY chain.distance DESC`, orgID, assetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chain []AssetTreeNode
+	for rows.Next() {
+		node, err := scanAssetTreeNode(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, errAssetNotFound
+	}
+	ancestors := chain[:len(chain)-1]
+	for i := range ancestors {
+		ancestors[i].Depth = i
+	}
+	return ancestors, nil
+}
+
+// moveAsset nests an asset under parentID, or makes it a root for 0. It is a
+// merge patch of parent_id, so it is versioned and recorded like one.
+func (a *App) moveAsset(ctx context.Context, orgID, assetID, parentID, ifVersion int64) (AssetRecord, error) {
+	value := json.RawMessage("null")
+	if parentID != 0 {
+		value = json.RawMessage(strconv.FormatInt(parentID, 10))
+	}
+	return a.patchAsset(ctx, orgID, assetID, map[string]json.RawMessage{"parent_id": value}, ifVersion)
+}
+
+// includesDescendantsCondition wraps filter conditions so that they match the
+// assets they select and all live descendants of those.
+func includesDescendantsCondition(filters string) string {
+	return `id IN (WITH RECURSIVE matched(id) AS (
+    SELECT id FROM assets WHERE org_id = ? AND deleted_at IS NULL AND ` + filters + `
+    UNION
+    SELECT child.id FROM assets AS child JOIN matched ON child.parent_id = matched.id WHERE child.deleted_at IS NULL
+) SELECT id FROM matched)`
+}
+
+// handleAssetHierarchy serves /assets/{id}/subtree, /assets/{id}/ancestors and
+// POST /assets/{id}/move.
+func (a *App) handleAssetHierarchy(w http.ResponseWriter, r *http.Request, orgID, assetID int64, action string) {
+	switch {
+	case action == "subtree" && r.Method == http.MethodGet:
+		depth := defaultAssetTreeDepth
+		if raw := strings.TrimSpace(r.URL.Query().Get("depth")); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 0 {
+				writeHTTPError(w, validationError{message: "depth must be a non-negative number"})
+				return
+			}
+			depth = parsed
+		}
+		if depth > maxAssetTreeDepth {
+			depth = maxAssetTreeDepth
+		}
+		tree, err := a.getAssetSubtree(r.Context(), orgID, assetID, depth)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": tree})
+	case action == "ancestors" && r.Method == http.MethodGet:
+		ancestors, err := a.listAssetAncestors(r.Context(), orgID, assetID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": ancestors})
+	case action == "move" && r.Method == http.MethodPost:
+		ifVersion, err := parseIfMatch(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		var body struct {
+			ParentID *int64 `json:"parent_id"`
+		}
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxAssetPayloadSize))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&body); err != nil {
+			writeHTTPError(w, validationError{message: `move expects {"parent_id": <asset id or null>}`})
+			return
+		}
+		var parentID int64
+		if body.ParentID != nil {
+			parentID = *body.ParentID
+		}
+		asset, err := a.moveAsset(r.Context(), orgID, assetID, parentID, ifVersion)
+		if err != nil {
+			a.writeAssetMutationError(w, r, orgID, assetID, err)
+			return
+		}
+		w.Header().Set("ETag", assetETag(asset.Version))
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": asset})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
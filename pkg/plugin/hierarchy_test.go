@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestAssetHierarchy(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	tower := createTestAsset(t, app, func(p *AssetPayload) { p.Title, p.StationName = "Tower", "TWR-1" })
+	mast := createTestAsset(t, app, func(p *AssetPayload) { p.Title, p.StationName, p.ParentID = "Mast", "CMP-1", &tower.ID })
+	sensor := createTestAsset(t, app, func(p *AssetPayload) { p.Title, p.StationName, p.ParentID = "Sensor", "CMP-2", &mast.ID })
+	createTestAsset(t, app, func(p *AssetPayload) { p.Title, p.StationName, p.ParentID = "Beacon", "CMP-3", &tower.ID })
+	if mast.ParentID != tower.ID || tower.ParentID != 0 {
+		t.Fatalf("unexpected parents: tower %d, mast %d", tower.ParentID, mast.ParentID)
+	}
+
+	towerPath := "assets/" + strconv.FormatInt(tower.ID, 10)
+	for _, body := range []string{
+		`{"parent_id":` + strconv.FormatInt(sensor.ID, 10) + `}`,
+		`{"parent_id":` + strconv.FormatInt(tower.ID, 10) + `}`,
+		`{"parent_id":999}`,
+	} {
+		if resp := callAssetPatch(t, app, towerPath, "", body); resp.Status != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", body, resp.Status, resp.Body)
+		}
+	}
+	other, err := app.listAssets(ctx, 2, AssetListOptions{})
+	if err != nil || len(other.Records) == 0 {
+		t.Fatalf("list org 2 assets: %+v (%v)", other, err)
+	}
+	otherParent := other.Records[0].ID
+	if _, err := app.createAsset(ctx, 1, AssetPayload{
+		Title: "Stray", EntryDate: "2025-01-01", CommissioningDate: "2025-01-01", StationName: "CMP-4",
+		Technician: "J. Doe", StartDate: "2025-01-01", EndDate: "2025-01-02", ParentID: &otherParent,
+	}); err == nil {
+		t.Fatal("expected a parent from another org to be rejected")
+	}
+
+	var tree AssetTreeNode
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: towerPath + "/subtree"}), &tree)
+	if len(tree.Children) != 2 || tree.Children[0].Title != "Beacon" || tree.Children[1].Title != "Mast" {
+		t.Fatalf("unexpected subtree: %+v", tree)
+	}
+	if len(tree.Children[1].Children) != 1 || tree.Children[1].Children[0].ID != sensor.ID || tree.Children[1].Children[0].Depth != 2 {
+		t.Fatalf("expected the sensor under the mast, got %+v", tree.Children[1])
+	}
+	var shallow AssetTreeNode
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: towerPath + "/subtree", URL: towerPath + "/subtree?depth=1"}), &shallow)
+	if len(shallow.Children) != 2 || len(shallow.Children[1].Children) != 0 {
+		t.Fatalf("expected depth to limit the subtree, got %+v", shallow)
+	}
+
+	var ancestors []AssetTreeNode
+	sensorPath := "assets/" + strconv.FormatInt(sensor.ID, 10)
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: sensorPath + "/ancestors"}), &ancestors)
+	if len(ancestors) != 2 || ancestors[0].ID != tower.ID || ancestors[1].ID != mast.ID {
+		t.Fatalf("expected tower then mast, got %+v", ancestors)
+	}
+
+	// Filtering on the tower's station also finds its sub-components.
+	filters := map[string][]string{"station_name": {"TWR-1"}}
+	result, err := app.listAssets(ctx, 1, AssetListOptions{Filters: filters})
+	if err != nil || result.TotalCount != 1 {
+		t.Fatalf("expected only the tower, got %+v (%v)", result, err)
+	}
+	result, err = app.listAssets(ctx, 1, AssetListOptions{Filters: filters, IncludeDescendants: true})
+	if err != nil || result.TotalCount != 4 || !result.AppliedIncludeDescendants {
+		t.Fatalf("expected the tower and its descendants, got %+v (%v)", result, err)
+	}
+
+	if resp := callResource(t, app, &backend.CallResourceRequest{
+		Method: http.MethodPost, Path: sensorPath + "/move", Body: []byte(`{"parent_id":null}`),
+		Headers: map[string][]string{"If-Match": {assetETag(sensor.Version + 5)}},
+	}); resp.Status != http.StatusPreconditionFailed {
+		t.Fatalf("expected a stale move to 412, got %d", resp.Status)
+	}
+	var moved AssetRecord
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: sensorPath + "/move", Body: []byte(`{"parent_id":null}`)}), &moved)
+	if moved.ParentID != 0 || moved.Version != sensor.Version+1 {
+		t.Fatalf("expected the sensor to become a versioned root, got %+v", moved)
+	}
+
+	if err := app.deleteAsset(ctx, 1, mast.ID, 0); err != nil {
+		t.Fatalf("delete mast: %v", err)
+	}
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: towerPath + "/subtree"}), &tree)
+	if len(tree.Children) != 1 || tree.Children[0].Title != "Beacon" {
+		t.Fatalf("expected trashed assets to leave the subtree, got %+v", tree)
+	}
+}
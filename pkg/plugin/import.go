@@ -0,0 +1,424 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxImportSize      = 10 << 20
+	maxImportRows      = 10000
+	importFileField    = "file"
+	importMappingField = "mapping"
+)
+
+// assetImportFields maps the AssetPayload JSON names to setters used when
+// reading CSV cells.
+var assetImportFields = map[string]func(p *AssetPayload, value string) error{
+	"title":              func(p *AssetPayload, v string) error { p.Title = v; return nil },
+	"entry_date":         func(p *AssetPayload, v string) error { p.EntryDate = v; return nil },
+	"commissioning_date": func(p *AssetPayload, v string) error { p.CommissioningDate = v; return nil },
+	"station_name":       func(p *AssetPayload, v string) error { p.StationName = v; return nil },
+	"technician":         func(p *AssetPayload, v string) error { p.Technician = v; return nil },
+	"start_date":         func(p *AssetPayload, v string) error { p.StartDate = v; return nil },
+	"end_date":           func(p *AssetPayload, v string) error { p.EndDate = v; return nil },
+	"service":            func(p *AssetPayload, v string) error { p.Service = v; return nil },
+	"staff":              func(p *AssetPayload, v string) error { p.Staff = splitImportList(v); return nil },
+	"tags":               func(p *AssetPayload, v string) error { p.Tags = splitImportList(v); return nil },
+	"latitude":           func(p *AssetPayload, v string) error { return parseImportFloat(v, &p.Latitude) },
+	"longitude":          func(p *AssetPayload, v string) error { return parseImportFloat(v, &p.Longitude) },
+	"pitch":              func(p *AssetPayload, v string) error { return parseImportFloat(v, &p.Pitch) },
+	"roll":               func(p *AssetPayload, v string) error { return parseImportFloat(v, &p.Roll) },
+}
+
+// assetImportRequired lists the fields validate() rejects when empty, checked
+// against the header up front so a bad mapping fails once instead of per row.
+var assetImportRequired = []string{"title", "entry_date", "commissioning_date", "station_name", "technician", "start_date", "end_date"}
+
+type assetImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+type assetImportReport struct {
+	DryRun         bool                  `json:"dryRun"`
+	Rows           int                   `json:"rows"`
+	Valid          int                   `json:"valid"`
+	Invalid        int                   `json:"invalid"`
+	Created        []int64               `json:"created"`
+	Errors         []assetImportRowError `json:"errors"`
+	IgnoredColumns []string              `json:"ignoredColumns"`
+}
+
+type assetImportRow struct {
+	line    int
+	payload AssetPayload
+}
+
+func (a *App) handleAssetImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	dryRun, err := parseOptionalBool(r.URL.Query().Get("dryRun"))
+	if err != nil {
+		writeHTTPError(w, validationError{message: "dryRun must be true or false"})
+		return
+	}
+
+	body, mapping, err := readAssetImportRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	fields, err := listAssetFieldDefinitions(r.Context(), a.db, orgID)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	rows, report, err := parseAssetImport(body, mapping, fields, a.validator(attachmentsNotChecked))
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	report.DryRun = dryRun
+
+	switch {
+	case dryRun:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": report})
+		return
+	case report.Invalid > 0:
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"data": report})
+		return
+	}
+
+	created, err := a.importAssets(r.Context(), orgID, rows)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	report.Created = created
+	log.Printf("imported %d assets for org %d", len(created), orgID)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"data": report})
+}
+
+// readAssetImportRequest returns the CSV and the optional header mapping. The
+// CSV is either the raw request body or the "file" part of a multipart form,
+// and the mapping a JSON object of CSV header to asset field, given as the
+// "mapping" form field or query parameter.
+func readAssetImportRequest(r *http.Request) (io.Reader, map[string]string, error) {
+	rawMapping := r.URL.Query().Get(importMappingField)
+	var src io.Reader = r.Body
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxImportSize); err != nil {
+			return nil, nil, validationError{message: "invalid multipart form"}
+		}
+		file, _, err := r.FormFile(importFileField)
+		if err != nil {
+			return nil, nil, validationError{message: "missing file upload"}
+		}
+		defer file.Close()
+		src = file
+		if value := r.FormValue(importMappingField); value != "" {
+			rawMapping = value
+		}
+	}
+
+	// Read one byte past the limit so that a longer CSV is rejected rather
+	// than cut off mid-row.
+	data, err := io.ReadAll(io.LimitReader(src, maxImportSize+1))
+	if err != nil {
+		return nil, nil, validationError{message: "failed to read CSV"}
+	}
+	if len(data) > maxImportSize {
+		return nil, nil, httpError{status: http.StatusRequestEntityTooLarge, message: fmt.Sprintf("CSV exceeds maximum size of %d bytes", maxImportSize)}
+	}
+
+	var mapping map[string]string
+	if strings.TrimSpace(rawMapping) != "" {
+		if err := json.Unmarshal([]byte(rawMapping), &mapping); err != nil {
+			return nil, nil, validationError{message: "mapping must be a JSON object of CSV header to asset field"}
+		}
+	}
+	return bytes.NewReader(data), mapping, nil
+}
+
+// parseAssetImport reads every CSV row into a normalized payload. Rows that
+// fail to parse or validate are reported with their line number and left out
+// of the returned rows, with a report entry per violation. Dates without an
+// offset are read in the org's time zone.
+func parseAssetImport(body io.Reader, mapping map[string]string, customFields assetFieldDefinitions, v *assetValidator) ([]assetImportRow, assetImportReport, error) {
+	report := assetImportReport{Created: []int64{}, Errors: []assetImportRowError{}, IgnoredColumns: []string{}}
+
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, report, validationError{message: "CSV is empty"}
+	}
+	if err != nil {
+		return nil, report, validationError{message: fmt.Sprintf("invalid CSV header: %v", err)}
+	}
+
+	fields, err := resolveImportColumns(header, mapping, customFields)
+	if err != nil {
+		return nil, report, err
+	}
+	for i, field := range fields {
+		if field == "" {
+			report.IgnoredColumns = append(report.IgnoredColumns, strings.TrimSpace(header[i]))
+		}
+	}
+
+	var rows []assetImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, report, validationError{message: fmt.Sprintf("invalid CSV at line %d: %v", parseErr.StartLine, parseErr.Err)}
+			}
+			return nil, report, validationError{message: fmt.Sprintf("invalid CSV: %v", err)}
+		}
+		if isBlankCSVRecord(record) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		report.Rows++
+		if report.Rows > maxImportRows {
+			return nil, report, validationError{message: fmt.Sprintf("CSV exceeds %d rows", maxImportRows)}
+		}
+
+		payload, rowErr := buildImportPayload(record, header, fields, customFields)
+		if rowErr == nil {
+			payload.normalize(v.loc)
+			if err := payload.validate(customFields, v); err != nil {
+				var valErr validationError
+				if errors.As(err, &valErr) && len(valErr.fields) > 0 {
+					for _, violation := range valErr.fields {
+						report.Errors = append(report.Errors, assetImportRowError{Row: line, Column: violation.Field, Message: violation.Message})
+					}
+					report.Invalid++
+					continue
+				}
+				rowErr = &assetImportRowError{Message: err.Error()}
+			}
+		}
+		if rowErr != nil {
+			rowErr.Row = line
+			report.Errors = append(report.Errors, *rowErr)
+			report.Invalid++
+			continue
+		}
+		report.Valid++
+		rows = append(rows, assetImportRow{line: line, payload: payload})
+	}
+	return rows, report, nil
+}
+
+// resolveImportColumns returns the asset field of every header column, or an
+// empty string for ignored columns. Without a mapping, headers are matched to
+// field names case-insensitively, so files produced by the CSV export import
+// unchanged. Custom fields are addressed as custom.<name>.
+func resolveImportColumns(header []string, mapping map[string]string, customFields assetFieldDefinitions) ([]string, error) {
+	known := func(field string) bool {
+		if strings.HasPrefix(field, customFieldPrefix) {
+			_, err := customFields.resolve(field)
+			return err == nil
+		}
+		_, ok := assetImportFields[field]
+		return ok
+	}
+
+	normalizedMapping := make(map[string]string, len(mapping))
+	for column, field := range mapping {
+		field = strings.TrimSpace(field)
+		if !known(field) && field != "" {
+			return nil, validationError{message: fmt.Sprintf("mapping for %q targets unknown field %q", column, field)}
+		}
+		normalizedMapping[normalizeImportHeader(column)] = field
+	}
+
+	fields := make([]string, len(header))
+	seen := make(map[string]string)
+	for i, column := range header {
+		key := normalizeImportHeader(column)
+		field, ok := normalizedMapping[key]
+		if !ok && len(mapping) == 0 && known(key) {
+			field = key
+		}
+		if field == "" {
+			continue
+		}
+		if previous, dup := seen[field]; dup {
+			return nil, validationError{message: fmt.Sprintf("columns %q and %q both map to %s", previous, strings.TrimSpace(column), field)}
+		}
+		seen[field] = strings.TrimSpace(column)
+		fields[i] = field
+	}
+
+	required := append([]string{}, assetImportRequired...)
+	for _, def := range customFields {
+		if def.Required {
+			required = append(required, customFieldPrefix+def.Name)
+		}
+	}
+	var missing []string
+	for _, field := range required {
+		if _, ok := seen[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, validationError{message: "CSV has no column for " + strings.Join(missing, ", ")}
+	}
+	return fields, nil
+}
+
+func buildImportPayload(record, header, fields []string, customFields assetFieldDefinitions) (AssetPayload, *assetImportRowError) {
+	var payload AssetPayload
+	for i, field := range fields {
+		if field == "" || i >= len(record) {
+			continue
+		}
+		if strings.HasPrefix(field, customFieldPrefix) {
+			if err := setImportCustomField(&payload, customFields, field, csvUnescapeText(record[i])); err != nil {
+				return AssetPayload{}, &assetImportRowError{Column: strings.TrimSpace(header[i]), Message: err.Error()}
+			}
+			continue
+		}
+		if err := assetImportFields[field](&payload, csvUnescapeText(record[i])); err != nil {
+			return AssetPayload{}, &assetImportRowError{Column: strings.TrimSpace(header[i]), Message: err.Error()}
+		}
+	}
+	return payload, nil
+}
+
+// setImportCustomField converts a cell to the custom field's type; empty
+// cells leave the field unset.
+func setImportCustomField(p *AssetPayload, customFields assetFieldDefinitions, field, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	def, err := customFields.resolve(field)
+	if err != nil {
+		return err
+	}
+	parsed, err := def.parseText(value)
+	if err != nil {
+		return err
+	}
+	if p.CustomFields == nil {
+		p.CustomFields = map[string]interface{}{}
+	}
+	p.CustomFields[def.Name] = parsed
+	return nil
+}
+
+// importAssets inserts all rows in one transaction, so a failing insert leaves
+// nothing behind.
+func (a *App) importAssets(ctx context.Context, orgID int64, rows []assetImportRow) ([]int64, error) {
+	created := make([]int64, 0, len(rows))
+	err := a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		for _, row := range rows {
+			id, err := insertAsset(ctx, tx, orgID, row.payload)
+			if err == nil {
+				err = recordAssetRevision(ctx, tx, orgID, id, revisionActionCreate)
+			}
+			if err == nil {
+				err = a.addAssetEvent(ctx, tx, events, webhookEventAssetCreated, id)
+			}
+			if err != nil {
+				return fmt.Errorf("import row %d: %w", row.line, err)
+			}
+			created = append(created, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func normalizeImportHeader(column string) string {
+	column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(column)
+}
+
+func isBlankCSVRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitImportList splits a flattened list such as the exported staff column.
+func splitImportList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, strings.TrimSpace(csvMultiValueSeparator)) {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
+
+func parseImportFloat(value string, dest *float64) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		*dest = 0
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	*dest = parsed
+	return nil
+}
+
+// csvUnescapeText reverses csvSafeText for values written by the CSV export.
+func csvUnescapeText(value string) string {
+	if len(value) > 1 && value[0] == '\'' {
+		switch value[1] {
+		case '=', '+', '-', '@', '\t', '\r':
+			return value[1:]
+		}
+	}
+	return value
+}
+
+func parseOptionalBool(value string) (bool, error) {
+	if strings.TrimSpace(value) == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(strings.TrimSpace(value))
+}
@@ -0,0 +1,192 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const importTestHeader = "Title,Entry Date,Commissioning Date,Station Name,Technician,Start Date,End Date,Staff,Latitude,Longitude,Notes\n"
+
+func callAssetImport(t *testing.T, app *App, query url.Values, body string) (int, assetImportReport) {
+	t.Helper()
+	var r mockCallResourceResponseSender
+	err := app.CallResource(context.Background(), &backend.CallResourceRequest{
+		Method:        http.MethodPost,
+		Path:          "assets/import",
+		URL:           "assets/import?" + query.Encode(),
+		Headers:       map[string][]string{"Content-Type": {"text/csv"}},
+		Body:          []byte(body),
+		PluginContext: backend.PluginContext{OrgID: 1},
+	}, &r)
+	if err != nil {
+		t.Fatalf("CallResource error: %v", err)
+	}
+	var out struct {
+		Data assetImportReport `json:"data"`
+	}
+	if r.response.Status < 300 || r.response.Status == http.StatusUnprocessableEntity {
+		if err := json.Unmarshal(r.response.Body, &out); err != nil {
+			t.Fatalf("decode report: %v (%s)", err, r.response.Body)
+		}
+	}
+	return r.response.Status, out.Data
+}
+
+func countOrgAssets(t *testing.T, app *App, orgID int64) int {
+	t.Helper()
+	var count int
+	if err := app.db.QueryRow(`SELECT COUNT(*) FROM assets WHERE org_id = ? AND deleted_at IS NULL`, orgID).Scan(&count); err != nil {
+		t.Fatalf("count assets: %v", err)
+	}
+	return count
+}
+
+func TestAssetImport(t *testing.T) {
+	valid := importTestHeader +
+		"Tower A,2025-01-02,2025-01-03,TA-1,J. Doe,2025-01-04,2025-01-05,A. One; B. Two,50.1,10.2,ignored\n" +
+		"Tower B,2025-02-02,2025-02-03,TB-1,J. Doe,2025-02-04,2025-02-05,,,,\n"
+	invalid := importTestHeader +
+		"Tower A,2025-01-02,2025-01-03,TA-1,J. Doe,2025-01-04,2025-01-05,,50.1,10.2,\n" +
+		",2025-01-02,2025-01-03,TB-1,J. Doe,2025-01-04,2025-01-05,,,,\n" +
+		"Tower C,2025-01-02,2025-01-03,TC-1,J. Doe,2025-01-04,2025-01-05,,north,10.2,\n"
+
+	t.Run("dry run reports row errors without writing", func(t *testing.T) {
+		app := newTestApp(t)
+		before := countOrgAssets(t, app, 1)
+
+		status, report := callAssetImport(t, app, url.Values{"dryRun": {"true"}}, invalid)
+		if status != http.StatusOK {
+			t.Fatalf("expected 200, got %d", status)
+		}
+		if !report.DryRun || report.Rows != 3 || report.Valid != 1 || report.Invalid != 2 {
+			t.Fatalf("unexpected report: %+v", report)
+		}
+		if report.Errors[0].Row != 3 || report.Errors[0].Message != "title is required" {
+			t.Fatalf("unexpected first error: %+v", report.Errors[0])
+		}
+		if report.Errors[1].Row != 4 || report.Errors[1].Column != "Latitude" {
+			t.Fatalf("unexpected second error: %+v", report.Errors[1])
+		}
+		if got := countOrgAssets(t, app, 1); got != before {
+			t.Fatalf("dry run wrote assets: %d -> %d", before, got)
+		}
+	})
+
+	t.Run("invalid rows reject the whole file", func(t *testing.T) {
+		app := newTestApp(t)
+		before := countOrgAssets(t, app, 1)
+
+		status, report := callAssetImport(t, app, nil, invalid)
+		if status != http.StatusUnprocessableEntity || report.Invalid != 2 {
+			t.Fatalf("expected 422 with 2 invalid rows, got %d %+v", status, report)
+		}
+		if got := countOrgAssets(t, app, 1); got != before {
+			t.Fatalf("rejected import wrote assets: %d -> %d", before, got)
+		}
+	})
+
+	t.Run("valid file is imported", func(t *testing.T) {
+		app := newTestApp(t)
+		before := countOrgAssets(t, app, 1)
+
+		status, report := callAssetImport(t, app, nil, valid)
+		if status != http.StatusCreated || len(report.Created) != 2 {
+			t.Fatalf("expected 201 with 2 created, got %d %+v", status, report)
+		}
+		if len(report.IgnoredColumns) != 1 || report.IgnoredColumns[0] != "Notes" {
+			t.Fatalf("expected Notes to be ignored, got %v", report.IgnoredColumns)
+		}
+		if got := countOrgAssets(t, app, 1); got != before+2 {
+			t.Fatalf("expected %d assets, got %d", before+2, got)
+		}
+		record, err := app.getAsset(context.Background(), 1, report.Created[0])
+		if err != nil {
+			t.Fatalf("get imported asset: %v", err)
+		}
+		if record.StationName != "TA-1" || len(record.Staff) != 2 || record.Latitude != 50.1 {
+			t.Fatalf("unexpected imported record: %+v", record)
+		}
+	})
+
+	t.Run("custom mapping", func(t *testing.T) {
+		app := newTestApp(t)
+		mapping := `{"Name":"title","Logged":"entry_date","Commissioned":"commissioning_date","Station":"station_name","By":"technician","From":"start_date","To":"end_date"}`
+		body := "Name,Logged,Commissioned,Station,By,From,To\n" +
+			"Mast,2025-03-01,2025-03-02,M-1,K. Lee,2025-03-03,2025-03-04\n"
+
+		status, report := callAssetImport(t, app, url.Values{"mapping": {mapping}}, body)
+		if status != http.StatusCreated || len(report.Created) != 1 {
+			t.Fatalf("expected 201 with 1 created, got %d %+v", status, report)
+		}
+	})
+
+	t.Run("mapping errors", func(t *testing.T) {
+		app := newTestApp(t)
+		for _, mapping := range []string{
+			`{"Title":"name"}`,
+			`{"Title":"title","Station Name":"title"}`,
+			`{"Title":"title"}`,
+			`not json`,
+		} {
+			status, _ := callAssetImport(t, app, url.Values{"mapping": {mapping}}, valid)
+			if status != http.StatusBadRequest {
+				t.Fatalf("mapping %s: expected 400, got %d", mapping, status)
+			}
+		}
+	})
+
+	t.Run("oversize file is rejected", func(t *testing.T) {
+		app := newTestApp(t)
+		before := countOrgAssets(t, app, 1)
+		row := "Tower A,2025-01-02,2025-01-03,TA-1,J. Doe,2025-01-04,2025-01-05,,,,\n"
+		body := importTestHeader + strings.Repeat(row, maxImportSize/len(row)+1)
+
+		status, _ := callAssetImport(t, app, url.Values{"dryRun": {"true"}}, body)
+		if status != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected 413, got %d", status)
+		}
+		status, _ = callAssetImport(t, app, nil, body)
+		if status != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected 413, got %d", status)
+		}
+		if got := countOrgAssets(t, app, 1); got != before {
+			t.Fatalf("oversize import wrote assets: %d -> %d", before, got)
+		}
+	})
+}
+
+func TestImportRoundTripsExportedCSV(t *testing.T) {
+	app := newTestApp(t)
+
+	var r mockCallResourceResponseSender
+	err := app.CallResource(context.Background(), &backend.CallResourceRequest{
+		Method:        http.MethodGet,
+		Path:          "assets.csv",
+		PluginContext: backend.PluginContext{OrgID: 1},
+	}, &r)
+	if err != nil || r.response.Status != http.StatusOK {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	status, report := callAssetImport(t, app, url.Values{"dryRun": {"true"}}, string(r.response.Body))
+	if status != http.StatusOK || report.Invalid != 0 || report.Valid != 2 {
+		t.Fatalf("expected exported CSV to validate, got %d %+v", status, report)
+	}
+}
+
+func TestCSVUnescapeText(t *testing.T) {
+	for _, value := range []string{"=SUM(A1)", "+1", "-1", "@cmd", "plain", "'quoted"} {
+		if got := csvUnescapeText(csvSafeText(value)); got != value {
+			t.Fatalf("round trip %q: got %q", value, got)
+		}
+	}
+	if got := strings.Join(splitImportList("A; B;;C "), "|"); got != "A|B|C" {
+		t.Fatalf("unexpected split: %s", got)
+	}
+}
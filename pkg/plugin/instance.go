@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+)
+
+// DataSourcePluginID is the nested data source that exposes QueryData to
+// dashboards and alert rules. Grafana starts it as its own process from the
+// same executable as the app.
+const DataSourcePluginID = "rpatt-assetlog-datasource"
+
+// instanceProvider creates App instances for both the app and the nested data
+// source. App instances are keyed per organization like app.NewInstanceProvider;
+// data source instances are additionally keyed by data source UID.
+type instanceProvider struct{}
+
+func (instanceProvider) GetKey(_ context.Context, pCtx backend.PluginContext) (interface{}, error) {
+	switch {
+	case pCtx.DataSourceInstanceSettings != nil:
+		return fmt.Sprintf("%s#%d#%s", pCtx.PluginID, pCtx.OrgID, pCtx.DataSourceInstanceSettings.UID), nil
+	case pCtx.AppInstanceSettings != nil:
+		return fmt.Sprintf("%s#%d", pCtx.PluginID, pCtx.OrgID), nil
+	default:
+		return nil, fmt.Errorf("plugin context has neither app nor data source settings")
+	}
+}
+
+func (instanceProvider) NeedsUpdate(_ context.Context, pCtx backend.PluginContext, cached instancemgmt.CachedInstance) bool {
+	if !cached.PluginContext.GrafanaConfig.Equal(pCtx.GrafanaConfig) {
+		return true
+	}
+	if pCtx.DataSourceInstanceSettings != nil {
+		prev := cached.PluginContext.DataSourceInstanceSettings
+		return prev == nil || !prev.Updated.Equal(pCtx.DataSourceInstanceSettings.Updated)
+	}
+	prev := cached.PluginContext.AppInstanceSettings
+	return prev == nil || pCtx.AppInstanceSettings == nil || !prev.Updated.Equal(pCtx.AppInstanceSettings.Updated)
+}
+
+// NewInstance builds an App. Data source instances start from empty app
+// settings, so the org's persisted storage settings are picked up by newApp,
+// and leave the background workers to the org's app instance; otherwise each
+// data source UID would sweep and dispatch the same org again.
+func (instanceProvider) NewInstance(ctx context.Context, pCtx backend.PluginContext) (instancemgmt.Instance, error) {
+	ctx = backend.WithPluginContext(ctx, pCtx)
+	if pCtx.AppInstanceSettings != nil {
+		return NewApp(ctx, *pCtx.AppInstanceSettings)
+	}
+	return newApp(ctx, backend.AppInstanceSettings{})
+}
+
+// Handler dispatches plugin requests to the App instance of the calling
+// plugin context.
+type Handler struct {
+	instances instancemgmt.InstanceManager
+}
+
+// NewHandler returns a Handler with its own instance manager.
+func NewHandler() *Handler {
+	return &Handler{instances: instancemgmt.New(instanceProvider{})}
+}
+
+func (h *Handler) app(ctx context.Context, pCtx backend.PluginContext) (*App, error) {
+	inst, err := h.instances.Get(ctx, pCtx)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := inst.(*App)
+	if !ok {
+		return nil, fmt.Errorf("unexpected instance type %T", inst)
+	}
+	return a, nil
+}
+
+func (h *Handler) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	a, err := h.app(ctx, req.PluginContext)
+	if err != nil {
+		return &backend.CheckHealthResult{Status: backend.HealthStatusError, Message: err.Error()}, nil
+	}
+	return a.CheckHealth(ctx, req)
+}
+
+func (h *Handler) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	a, err := h.app(ctx, req.PluginContext)
+	if err != nil {
+		return err
+	}
+	return a.CallResource(ctx, req, sender)
+}
+
+func (h *Handler) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	a, err := h.app(ctx, req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+	return a.QueryData(ctx, req)
+}
@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const (
+	// assetStreamPrefix starts the path of an org's asset channel,
+	// plugin/rpatt-assetlog-app/assets/{orgId}.
+	assetStreamPrefix = "assets/"
+	// assetStreamBuffer is how many events a slow stream may fall behind
+	// before further events are dropped for it.
+	assetStreamBuffer = 64
+)
+
+// assetStreams fans asset events out to the RunStream calls of each org. It is
+// process-wide so that streams outlive the App instance that started them.
+var assetStreams = newAssetStreamBroker()
+
+type assetStreamBroker struct {
+	mu   sync.Mutex
+	subs map[int64]map[chan []byte]struct{}
+}
+
+func newAssetStreamBroker() *assetStreamBroker {
+	return &assetStreamBroker{subs: map[int64]map[chan []byte]struct{}{}}
+}
+
+// subscribe returns a channel receiving the org's encoded events and a func
+// that unsubscribes it.
+func (b *assetStreamBroker) subscribe(orgID int64) (<-chan []byte, func()) {
+	ch := make(chan []byte, assetStreamBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[orgID] == nil {
+		b.subs[orgID] = map[chan []byte]struct{}{}
+	}
+	b.subs[orgID][ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[orgID], ch)
+		if len(b.subs[orgID]) == 0 {
+			delete(b.subs, orgID)
+		}
+	}
+}
+
+func (b *assetStreamBroker) publish(orgID int64, body []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[orgID] {
+		select {
+		case ch <- body:
+		default:
+			log.Printf("live: dropping event for a slow stream of org %d", orgID)
+		}
+	}
+}
+
+// parseAssetStreamPath returns the org of an assets/{orgId} stream path.
+func parseAssetStreamPath(path string) (int64, bool) {
+	rest, ok := strings.CutPrefix(path, assetStreamPrefix)
+	if !ok {
+		return 0, false
+	}
+	orgID, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil || orgID <= 0 {
+		return 0, false
+	}
+	return orgID, true
+}
+
+// SubscribeStream lets users subscribe to their own org's asset channel. It
+// needs no App instance.
+func (h *Handler) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	orgID, ok := parseAssetStreamPath(req.Path)
+	switch {
+	case !ok:
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	case orgID != req.PluginContext.OrgID:
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusPermissionDenied}, nil
+	}
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream rejects client publications; only the backend publishes
+// asset events.
+func (h *Handler) PublishStream(_ context.Context, _ *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream forwards the org's asset events to Grafana Live until the last
+// subscriber leaves the channel.
+func (h *Handler) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	orgID, ok := parseAssetStreamPath(req.Path)
+	if !ok {
+		return fmt.Errorf("unknown stream path %q", req.Path)
+	}
+	events, unsubscribe := assetStreams.subscribe(orgID)
+	defer unsubscribe()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case body := <-events:
+			if err := sender.SendJSON(body); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+type channelPacketSender chan *backend.StreamPacket
+
+func (s channelPacketSender) Send(packet *backend.StreamPacket) error {
+	s <- packet
+	return nil
+}
+
+func assetStreamSubscribers(orgID int64) int {
+	assetStreams.mu.Lock()
+	defer assetStreams.mu.Unlock()
+	return len(assetStreams.subs[orgID])
+}
+
+func TestAssetStream(t *testing.T) {
+	handler := NewHandler()
+	ctx := context.Background()
+	for path, expected := range map[string]backend.SubscribeStreamStatus{
+		"assets/1": backend.SubscribeStreamStatusOK,
+		"assets/2": backend.SubscribeStreamStatusPermissionDenied,
+		"assets/x": backend.SubscribeStreamStatusNotFound,
+		"people/1": backend.SubscribeStreamStatusNotFound,
+	} {
+		resp, err := handler.SubscribeStream(ctx, &backend.SubscribeStreamRequest{Path: path, PluginContext: backend.PluginContext{OrgID: 1}})
+		if err != nil || resp.Status != expected {
+			t.Fatalf("%s: expected status %d, got %+v (%v)", path, expected, resp, err)
+		}
+	}
+	if resp, _ := handler.PublishStream(ctx, &backend.PublishStreamRequest{Path: "assets/1"}); resp.Status != backend.PublishStreamStatusPermissionDenied {
+		t.Fatalf("expected client publications to be denied, got %d", resp.Status)
+	}
+
+	app := newTestApp(t)
+	streamCtx, cancel := context.WithCancel(ctx)
+	packets := make(channelPacketSender, 8)
+	done := make(chan error, 1)
+	go func() {
+		done <- handler.RunStream(streamCtx, &backend.RunStreamRequest{Path: "assets/1"}, backend.NewStreamSender(packets))
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for assetStreamSubscribers(1) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "assets", Body: []byte(batchTestAsset), PluginContext: backend.PluginContext{OrgID: 2}})
+	var asset AssetRecord
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "assets", Body: []byte(batchTestAsset)}), &asset)
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: "assets/" + strconv.FormatInt(asset.ID, 10)}); resp.Status != http.StatusNoContent {
+		t.Fatalf("delete asset: %d", resp.Status)
+	}
+
+	for _, expected := range []string{webhookEventAssetCreated, webhookEventAssetDeleted} {
+		select {
+		case packet := <-packets:
+			var event struct {
+				Event string `json:"event"`
+				OrgID int64  `json:"org_id"`
+				Data  struct {
+					ID int64 `json:"id"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(packet.Data, &event); err != nil || event.Event != expected || event.OrgID != 1 || event.Data.ID != asset.ID {
+				t.Fatalf("expected %s of asset %d, got %s (%v)", expected, asset.ID, packet.Data, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", expected)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("run stream: %v", err)
+	}
+	if n := assetStreamSubscribers(1); n != 0 {
+		t.Fatalf("expected the stream to unsubscribe, %d left", n)
+	}
+	if len(packets) != 0 {
+		t.Fatalf("expected no events from other orgs, got %d", len(packets))
+	}
+}
@@ -0,0 +1,267 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "assetlog"
+	// metricsQueryTimeout bounds the database queries run on every scrape.
+	metricsQueryTimeout = 5 * time.Second
+)
+
+// The metrics live on the default registry, which the plugin SDK serves to
+// Grafana when it collects the plugin's metrics.
+var (
+	resourceRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "resource_requests_total",
+		Help:      "Resource requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+	resourceRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "resource_request_duration_seconds",
+		Help:      "Resource request latencies by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	storageUploadFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "storage_upload_failures_total",
+		Help:      "Attachment uploads the object storage rejected.",
+	})
+	storageDeleteFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "storage_delete_failures_total",
+		Help:      "Attachment deletions the object storage rejected.",
+	})
+	storageUploadedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "storage_uploaded_bytes_total",
+		Help:      "Bytes of attachments uploaded to the object storage.",
+	})
+	databases = newDatabaseCollector()
+)
+
+func init() {
+	prometheus.MustRegister(resourceRequests, resourceRequestDuration, storageUploadFailures, storageDeleteFailures, storageUploadedBytes, databases)
+}
+
+// statusRecorder captures the status code a handler writes.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming exports working through the recorder.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// instrumentRoutes counts and times the requests served by mux.
+func instrumentRoutes(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		mux.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		_, pattern := mux.Handler(r)
+		labels := []string{resourceRoute(pattern, r.URL.Path), r.Method, strconv.Itoa(recorder.status)}
+		resourceRequests.WithLabelValues(labels...).Inc()
+		resourceRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// resourceRouteLiterals are the fixed segments the subtree handlers route on
+// below their first segment, as in /assets/{id}/history/{id}/diff.
+var resourceRouteLiterals = map[string]bool{
+	"files": true, "history": true, "diff": true, "restore": true,
+	"subtree": true, "ancestors": true, "move": true,
+	"merge": true, "deliveries": true, "retry": true,
+}
+
+// resourceRouteMaxDepth is the most segments any route has below its subtree
+// pattern, as in /webhooks/{id}/deliveries/{id}/retry.
+const resourceRouteMaxDepth = 4
+
+// resourceRoute names the route of a request without its ids, e.g.
+// /assets/{id}/files/{id}, so that the label set stays small. The first
+// segment under a subtree pattern is always an id or a name; later segments
+// are ids or one of resourceRouteLiterals. Requests with any other segment, and
+// those only the fallback handler matches, are "other", so clients cannot
+// grow the label set.
+func resourceRoute(pattern, path string) string {
+	if pattern == "" || pattern == "/" {
+		return "other"
+	}
+	if !strings.HasSuffix(pattern, "/") {
+		return pattern
+	}
+	rest := strings.Trim(strings.TrimPrefix(path, pattern), "/")
+	if rest == "" {
+		return pattern
+	}
+	segments := strings.Split(rest, "/")
+	if len(segments) > resourceRouteMaxDepth {
+		return "other"
+	}
+	for i, segment := range segments {
+		switch _, err := strconv.ParseInt(segment, 10, 64); {
+		case err == nil:
+			segments[i] = "{id}"
+		case i == 0:
+			segments[i] = "{name}"
+		case !resourceRouteLiterals[segment]:
+			return "other"
+		}
+	}
+	return pattern + strings.Join(segments, "/")
+}
+
+// meteredStorage counts the failures and uploaded bytes of a StorageClient.
+type meteredStorage struct {
+	StorageClient
+}
+
+func (s meteredStorage) Upload(ctx context.Context, object string, r io.Reader, size int64, contentType string) error {
+	if err := s.StorageClient.Upload(ctx, object, r, size, contentType); err != nil {
+		storageUploadFailures.Inc()
+		return err
+	}
+	storageUploadedBytes.Add(float64(size))
+	return nil
+}
+
+func (s meteredStorage) Delete(ctx context.Context, object string) error {
+	if err := s.StorageClient.Delete(ctx, object); err != nil {
+		storageDeleteFailures.Inc()
+		return err
+	}
+	return nil
+}
+
+// databaseCollector reports per-org counts, the migration version and the
+// file size of the SQLite database. App instances share one database file, so
+// any open handle will do; when several files are open the first path wins.
+type databaseCollector struct {
+	mu   sync.Mutex
+	open map[*sql.DB]string
+
+	assets           *prometheus.Desc
+	attachments      *prometheus.Desc
+	migrationVersion *prometheus.Desc
+	fileSize         *prometheus.Desc
+}
+
+func newDatabaseCollector() *databaseCollector {
+	return &databaseCollector{
+		open:             map[*sql.DB]string{},
+		assets:           prometheus.NewDesc(metricsNamespace+"_assets", "Live assets per org.", []string{"org_id"}, nil),
+		attachments:      prometheus.NewDesc(metricsNamespace+"_attachments", "Attachments of live assets per org.", []string{"org_id"}, nil),
+		migrationVersion: prometheus.NewDesc(metricsNamespace+"_migration_version", "Latest schema migration applied to the database.", nil, nil),
+		fileSize:         prometheus.NewDesc(metricsNamespace+"_sqlite_file_size_bytes", "Size of the SQLite database file.", nil, nil),
+	}
+}
+
+func (c *databaseCollector) track(db *sql.DB, path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open[db] = path
+}
+
+func (c *databaseCollector) untrack(db *sql.DB) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.open, db)
+}
+
+// current returns an open database handle and its path.
+func (c *databaseCollector) current() (*sql.DB, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var db *sql.DB
+	var path string
+	for candidate, candidatePath := range c.open {
+		if db == nil || candidatePath < path {
+			db, path = candidate, candidatePath
+		}
+	}
+	return db, path
+}
+
+func (c *databaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.assets
+	ch <- c.attachments
+	ch <- c.migrationVersion
+	ch <- c.fileSize
+}
+
+func (c *databaseCollector) Collect(ch chan<- prometheus.Metric) {
+	db, path := c.current()
+	if db == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), metricsQueryTimeout)
+	defer cancel()
+
+	if info, err := os.Stat(path); err == nil {
+		ch <- prometheus.MustNewConstMetric(c.fileSize, prometheus.GaugeValue, float64(info.Size()))
+	}
+	var version int64
+	if err := db.QueryRowContext(ctx, `SELECT IFNULL(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		log.Printf("collect migration version: %v", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.migrationVersion, prometheus.GaugeValue, float64(version))
+	}
+	c.collectOrgCounts(ctx, ch, db, c.assets, `SELECT org_id, COUNT(*) FROM assets WHERE deleted_at IS NULL GROUP BY org_id`)
+	c.collectOrgCounts(ctx, ch, db, c.attachments, `SELECT f.org_id, COUNT(*) FROM asset_files AS f JOIN assets AS a ON a.id = f.asset_id
+WHERE a.deleted_at IS NULL GROUP BY f.org_id`)
+}
+
+func (c *databaseCollector) collectOrgCounts(ctx context.Context, ch chan<- prometheus.Metric, db *sql.DB, desc *prometheus.Desc, query string) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("collect %s: %v", desc, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orgID, count int64
+		if err := rows.Scan(&orgID, &count); err != nil {
+			log.Printf("collect %s: %v", desc, err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(count), strconv.FormatInt(orgID, 10))
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("collect %s: %v", desc, err)
+	}
+}
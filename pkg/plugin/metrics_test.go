@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+)
+
+// brokenStorage is a StorageClient whose bucket rejects every write.
+type brokenStorage struct {
+	recordingStorage
+}
+
+func (s *brokenStorage) Upload(context.Context, string, io.Reader, int64, string) error {
+	return errors.New("bucket unavailable")
+}
+
+func (s *brokenStorage) Delete(context.Context, string) error {
+	return errors.New("bucket unavailable")
+}
+
+func TestResourceRoute(t *testing.T) {
+	for _, tt := range []struct{ pattern, path, route string }{
+		{"/assets", "/assets", "/assets"},
+		{"/assets/", "/assets/12/files/3", "/assets/{id}/files/{id}"},
+		{"/assets/", "/assets/12/history/4/diff", "/assets/{id}/history/{id}/diff"},
+		{"/custom-fields/", "/custom-fields/serial", "/custom-fields/{name}"},
+		{"/webhooks/", "/webhooks/1/deliveries/7/retry", "/webhooks/{id}/deliveries/{id}/retry"},
+		{"/tags/", "/tags/x7f3", "/tags/{name}"},
+		{"/assets/", "/assets/1/x7f3", "other"},
+		{"/assets/", "/assets/1/files/1/files/1", "other"},
+		{"/", "/nope", "other"},
+	} {
+		if got := resourceRoute(tt.pattern, tt.path); got != tt.route {
+			t.Fatalf("%s: expected %s, got %s", tt.path, tt.route, got)
+		}
+	}
+}
+
+func TestCollectMetrics(t *testing.T) {
+	app := newTestApp(t)
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/1"}); resp.Status != http.StatusOK {
+		t.Fatalf("get asset: %d", resp.Status)
+	}
+	callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/999"})
+
+	// The plugin SDK serves the default registry to Grafana.
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	var buf bytes.Buffer
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+			t.Fatalf("encode metrics: %v", err)
+		}
+	}
+	text := buf.String()
+	for _, expected := range []string{
+		`assetlog_resource_requests_total{method="GET",route="/assets/{id}",status="200"}`,
+		`assetlog_resource_requests_total{method="GET",route="/assets/{id}",status="404"}`,
+		`assetlog_resource_request_duration_seconds_count{method="GET",route="/assets/{id}",status="200"}`,
+		`assetlog_assets{org_id="1"} 2`,
+		`assetlog_assets{org_id="2"} 2`,
+		`assetlog_migration_version `,
+		`assetlog_sqlite_file_size_bytes `,
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected %s in metrics:\n%s", expected, text)
+		}
+	}
+
+	uploads, deletes := testutil.ToFloat64(storageUploadFailures), testutil.ToFloat64(storageDeleteFailures)
+	bytesBefore := testutil.ToFloat64(storageUploadedBytes)
+	storage := meteredStorage{&brokenStorage{}}
+	_ = storage.Upload(context.Background(), "a", strings.NewReader("data"), 4, "text/plain")
+	_ = storage.Delete(context.Background(), "a")
+	if err := (meteredStorage{&recordingStorage{}}).Upload(context.Background(), "b", strings.NewReader("data"), 4, "text/plain"); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if testutil.ToFloat64(storageUploadFailures) != uploads+1 || testutil.ToFloat64(storageDeleteFailures) != deletes+1 || testutil.ToFloat64(storageUploadedBytes) != bytesBefore+4 {
+		t.Fatal("expected storage failures and uploaded bytes to be counted")
+	}
+}
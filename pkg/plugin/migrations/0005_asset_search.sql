@@ -0,0 +1,68 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS assets_fts USING fts5(
+    title,
+    service,
+    station_name,
+    technician,
+    staff,
+    file_names,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+INSERT INTO assets_fts (rowid, title, service, station_name, technician, staff, file_names)
+SELECT a.id,
+       a.title,
+       COALESCE(a.service, ''),
+       a.station_name,
+       a.technician,
+       CASE WHEN json_valid(a.staff) THEN (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(a.staff)) ELSE COALESCE(a.staff, '') END,
+       (SELECT COALESCE(group_concat(f.file_name, ' '), '') FROM asset_files AS f WHERE f.asset_id = a.id)
+FROM assets AS a;
+
+CREATE TRIGGER IF NOT EXISTS assets_fts_after_insert AFTER INSERT ON assets BEGIN
+    INSERT INTO assets_fts (rowid, title, service, station_name, technician, staff, file_names)
+    VALUES (
+        new.id,
+        new.title,
+        COALESCE(new.service, ''),
+        new.station_name,
+        new.technician,
+        CASE WHEN json_valid(new.staff) THEN (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(new.staff)) ELSE COALESCE(new.staff, '') END,
+        ''
+    );
+END;
+
+CREATE TRIGGER IF NOT EXISTS assets_fts_after_update AFTER UPDATE ON assets BEGIN
+    DELETE FROM assets_fts WHERE rowid = old.id;
+    INSERT INTO assets_fts (rowid, title, service, station_name, technician, staff, file_names)
+    VALUES (
+        new.id,
+        new.title,
+        COALESCE(new.service, ''),
+        new.station_name,
+        new.technician,
+        CASE WHEN json_valid(new.staff) THEN (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(new.staff)) ELSE COALESCE(new.staff, '') END,
+        (SELECT COALESCE(group_concat(file_name, ' '), '') FROM asset_files WHERE asset_id = new.id)
+    );
+END;
+
+CREATE TRIGGER IF NOT EXISTS assets_fts_after_delete AFTER DELETE ON assets BEGIN
+    DELETE FROM assets_fts WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS asset_files_fts_after_insert AFTER INSERT ON asset_files BEGIN
+    UPDATE assets_fts
+    SET file_names = (SELECT COALESCE(group_concat(file_name, ' '), '') FROM asset_files WHERE asset_id = new.asset_id)
+    WHERE rowid = new.asset_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS asset_files_fts_after_update AFTER UPDATE OF file_name ON asset_files BEGIN
+    UPDATE assets_fts
+    SET file_names = (SELECT COALESCE(group_concat(file_name, ' '), '') FROM asset_files WHERE asset_id = new.asset_id)
+    WHERE rowid = new.asset_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS asset_files_fts_after_delete AFTER DELETE ON asset_files BEGIN
+    UPDATE assets_fts
+    SET file_names = (SELECT COALESCE(group_concat(file_name, ' '), '') FROM asset_files WHERE asset_id = old.asset_id)
+    WHERE rowid = old.asset_id;
+END;
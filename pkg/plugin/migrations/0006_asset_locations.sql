@@ -0,0 +1,27 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS assets_rtree USING rtree(
+    id,
+    min_lat, max_lat,
+    min_lon, max_lon
+);
+
+INSERT INTO assets_rtree (id, min_lat, max_lat, min_lon, max_lon)
+SELECT id, latitude, latitude, longitude, longitude
+FROM assets
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL;
+
+CREATE TRIGGER IF NOT EXISTS assets_rtree_after_insert AFTER INSERT ON assets
+WHEN new.latitude IS NOT NULL AND new.longitude IS NOT NULL BEGIN
+    INSERT INTO assets_rtree (id, min_lat, max_lat, min_lon, max_lon)
+    VALUES (new.id, new.latitude, new.latitude, new.longitude, new.longitude);
+END;
+
+CREATE TRIGGER IF NOT EXISTS assets_rtree_after_update AFTER UPDATE OF latitude, longitude ON assets BEGIN
+    DELETE FROM assets_rtree WHERE id = old.id;
+    INSERT INTO assets_rtree (id, min_lat, max_lat, min_lon, max_lon)
+    SELECT new.id, new.latitude, new.latitude, new.longitude, new.longitude
+    WHERE new.latitude IS NOT NULL AND new.longitude IS NOT NULL;
+END;
+
+CREATE TRIGGER IF NOT EXISTS assets_rtree_after_delete AFTER DELETE ON assets BEGIN
+    DELETE FROM assets_rtree WHERE id = old.id;
+END;
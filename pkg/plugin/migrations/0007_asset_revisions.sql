@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS asset_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    asset_id INTEGER NOT NULL,
+    org_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    user_login TEXT,
+    user_name TEXT,
+    user_email TEXT,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (asset_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_asset_revisions_org_asset ON asset_revisions(org_id, asset_id);
+
+-- Seed a baseline revision so existing entries can be restored to their
+-- state at upgrade time.
+INSERT INTO asset_revisions (asset_id, org_id, revision, action, payload, created_at)
+SELECT id,
+       org_id,
+       1,
+       'create',
+       json_object(
+           'title', title,
+           'entry_date', entry_date,
+           'commissioning_date', commissioning_date,
+           'station_name', station_name,
+           'technician', technician,
+           'start_date', start_date,
+           'end_date', end_date,
+           'service', COALESCE(service, ''),
+           'staff', json(COALESCE(NULLIF(staff, ''), '[]')),
+           'latitude', COALESCE(latitude, 0),
+           'longitude', COALESCE(longitude, 0),
+           'pitch', COALESCE(pitch, 0),
+           'roll', COALESCE(roll, 0)
+       ),
+       COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)
+FROM assets;
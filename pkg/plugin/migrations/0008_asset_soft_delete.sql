@@ -0,0 +1,3 @@
+ALTER TABLE assets ADD COLUMN deleted_at TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_assets_org_deleted_at ON assets(org_id, deleted_at);
@@ -0,0 +1 @@
+ALTER TABLE assets ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
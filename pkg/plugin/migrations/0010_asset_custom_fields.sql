@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS asset_field_definitions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    org_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('text', 'number', 'date', 'enum', 'bool')),
+    required INTEGER NOT NULL DEFAULT 0,
+    allowed_values TEXT NOT NULL DEFAULT '[]',
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (org_id, name)
+);
+
+-- Values are kept as a JSON object keyed by field name, so they travel with
+-- the row through revisions, the trash and restores.
+ALTER TABLE assets ADD COLUMN custom_fields TEXT NOT NULL DEFAULT '{}';
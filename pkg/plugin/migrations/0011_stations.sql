@@ -0,0 +1,61 @@
+CREATE TABLE IF NOT EXISTS stations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    org_id INTEGER NOT NULL,
+    code TEXT NOT NULL,
+    -- code_key is the code upper-cased without spaces and -_./ separators, so
+    -- spelling variants such as WLS7-1273 and WLS71273 are one station.
+    code_key TEXT NOT NULL,
+    name TEXT NOT NULL,
+    latitude REAL,
+    longitude REAL,
+    type TEXT NOT NULL DEFAULT '',
+    owner TEXT NOT NULL DEFAULT '',
+    active INTEGER NOT NULL DEFAULT 1,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (org_id, code_key)
+);
+
+-- Register one station per distinct station_name, merging spelling variants.
+-- The most used spelling becomes the code, and the coordinates are the mean
+-- of the located assets.
+INSERT INTO stations (org_id, code, code_key, name, latitude, longitude)
+SELECT org_id,
+       (SELECT v.station_name FROM assets AS v
+        WHERE v.org_id = grouped.org_id
+          AND upper(replace(replace(replace(replace(replace(trim(v.station_name), ' ', ''), '-', ''), '_', ''), '.', ''), '/', '')) = grouped.code_key
+        GROUP BY v.station_name ORDER BY COUNT(*) DESC, v.station_name LIMIT 1),
+       code_key,
+       (SELECT v.station_name FROM assets AS v
+        WHERE v.org_id = grouped.org_id
+          AND upper(replace(replace(replace(replace(replace(trim(v.station_name), ' ', ''), '-', ''), '_', ''), '.', ''), '/', '')) = grouped.code_key
+        GROUP BY v.station_name ORDER BY COUNT(*) DESC, v.station_name LIMIT 1),
+       latitude,
+       longitude
+FROM (
+    SELECT org_id,
+           upper(replace(replace(replace(replace(replace(trim(station_name), ' ', ''), '-', ''), '_', ''), '.', ''), '/', '')) AS code_key,
+           AVG(CASE WHEN latitude IS NOT NULL AND longitude IS NOT NULL AND NOT (latitude = 0 AND longitude = 0) THEN latitude END) AS latitude,
+           AVG(CASE WHEN latitude IS NOT NULL AND longitude IS NOT NULL AND NOT (latitude = 0 AND longitude = 0) THEN longitude END) AS longitude
+    FROM assets
+    WHERE trim(station_name) <> ''
+    GROUP BY org_id, code_key
+) AS grouped
+WHERE code_key <> '';
+
+ALTER TABLE assets ADD COLUMN station_id INTEGER REFERENCES stations(id);
+
+UPDATE assets
+SET station_id = (
+    SELECT s.id FROM stations AS s
+    WHERE s.org_id = assets.org_id
+      AND s.code_key = upper(replace(replace(replace(replace(replace(trim(assets.station_name), ' ', ''), '-', ''), '_', ''), '.', ''), '/', ''))
+);
+
+-- station_name mirrors the code of the referenced station.
+UPDATE assets
+SET station_name = (SELECT s.code FROM stations AS s WHERE s.id = assets.station_id)
+WHERE station_id IS NOT NULL
+  AND station_name <> (SELECT s.code FROM stations AS s WHERE s.id = assets.station_id);
+
+CREATE INDEX IF NOT EXISTS idx_assets_station_id ON assets(station_id);
@@ -0,0 +1,84 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    org_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    name_key TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (org_id, name_key)
+);
+
+CREATE TABLE IF NOT EXISTS people (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    org_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    name_key TEXT NOT NULL,
+    organization_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (org_id, name_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_people_organization_id ON people(organization_id);
+
+-- asset_people links assets to the people named in their technician and
+-- staff columns, which stay the source of truth.
+CREATE TABLE IF NOT EXISTS asset_people (
+    asset_id INTEGER NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+    person_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('technician', 'staff')),
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (asset_id, role, person_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_asset_people_person_id ON asset_people(person_id);
+
+-- Split every technician and staff entry of the form "Name (Company)" into
+-- a person and a company. Entries without a trailing "(...)" are a name only.
+CREATE TEMP TABLE person_entries AS
+SELECT asset_id,
+       org_id,
+       role,
+       position,
+       CASE WHEN instr(entry, '(') > 1 AND entry LIKE '%)'
+            THEN trim(substr(entry, 1, instr(entry, '(') - 1))
+            ELSE entry END AS name,
+       CASE WHEN instr(entry, '(') > 1 AND entry LIKE '%)'
+            THEN trim(substr(entry, instr(entry, '(') + 1, length(entry) - instr(entry, '(') - 1))
+            ELSE '' END AS company
+FROM (
+    SELECT id AS asset_id, org_id, 'technician' AS role, 0 AS position, trim(technician) AS entry
+    FROM assets
+    UNION ALL
+    SELECT a.id, a.org_id, 'staff', CAST(s.key AS INTEGER), trim(s.value)
+    FROM assets AS a,
+         json_each(CASE WHEN json_valid(a.staff) THEN a.staff
+                        WHEN trim(COALESCE(a.staff, '')) <> '' THEN json_array(a.staff)
+                        ELSE '[]' END) AS s
+    WHERE s.type = 'text'
+)
+WHERE entry <> '';
+
+INSERT OR IGNORE INTO organizations (org_id, name, name_key)
+SELECT org_id, company, lower(company)
+FROM person_entries
+WHERE company <> ''
+ORDER BY asset_id, role, position;
+
+-- Entries naming a company go first so a person gets the company from any
+-- entry that mentions it.
+INSERT OR IGNORE INTO people (org_id, name, name_key, organization_id)
+SELECT e.org_id,
+       e.name,
+       lower(e.name),
+       (SELECT o.id FROM organizations AS o WHERE o.org_id = e.org_id AND o.name_key = lower(e.company))
+FROM person_entries AS e
+WHERE e.name <> ''
+ORDER BY e.company = '', e.asset_id, e.role, e.position;
+
+INSERT OR IGNORE INTO asset_people (asset_id, person_id, role, position)
+SELECT e.asset_id, p.id, e.role, e.position
+FROM person_entries AS e
+JOIN people AS p ON p.org_id = e.org_id AND p.name_key = lower(e.name);
+
+DROP TABLE person_entries;
@@ -0,0 +1,8 @@
+-- Dates are rewritten to RFC 3339 by convertAssetDates after this script.
+-- Values it cannot parse are kept and listed here.
+CREATE TABLE IF NOT EXISTS asset_date_issues (
+    asset_id INTEGER NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+    column_name TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (asset_id, column_name)
+);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    org_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    -- name_key is the lower-cased name, so "Lidar" and "lidar" are one tag.
+    name_key TEXT NOT NULL,
+    color TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (org_id, name_key)
+);
+
+CREATE TABLE IF NOT EXISTS asset_tags (
+    asset_id INTEGER NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (asset_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_asset_tags_tag_id ON asset_tags(tag_id);
@@ -0,0 +1,5 @@
+-- parent_id nests an asset under another asset of the same org, e.g. a
+-- sensor under its met tower. Purging a parent makes its children roots.
+ALTER TABLE assets ADD COLUMN parent_id INTEGER REFERENCES assets(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_assets_parent_id ON assets(parent_id);
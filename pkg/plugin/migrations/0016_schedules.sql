@@ -0,0 +1,21 @@
+-- Recurring maintenance schedules. A schedule targets either a station or an
+-- asset and its sub-components; task_type is matched against assets.service.
+CREATE TABLE IF NOT EXISTS schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    org_id INTEGER NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    station_id INTEGER REFERENCES stations(id) ON DELETE CASCADE,
+    asset_id INTEGER REFERENCES assets(id) ON DELETE CASCADE,
+    task_type TEXT NOT NULL,
+    interval_count INTEGER NOT NULL,
+    interval_unit TEXT NOT NULL,
+    -- meter_field names the number custom field holding operating-hour
+    -- readings; it is only set on schedules counted in hours.
+    meter_field TEXT NOT NULL DEFAULT '',
+    anchor_date TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((station_id IS NULL) <> (asset_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_org_id ON schedules(org_id);
@@ -1,19 +1,36 @@
 package plugin
 
 import (
+	"database/sql"
 	_ "embed"
 	"fmt"
 )
 
+// migrations run in order, each in its own transaction. apply, when set, runs
+// after script for conversions that need Go.
 var migrations = []struct {
 	version int
 	name    string
 	script  string
+	apply   func(tx *sql.Tx) error
 }{
 	{version: 1, name: "init", script: migration0001},
 	{version: 2, name: "attachments", script: migration0002},
 	{version: 3, name: "app_settings", script: migration0003},
 	{version: 4, name: "app_settings_provisioned", script: migration0004},
+	{version: 5, name: "asset_search", script: migration0005},
+	{version: 6, name: "asset_locations", script: migration0006},
+	{version: 7, name: "asset_revisions", script: migration0007},
+	{version: 8, name: "asset_soft_delete", script: migration0008},
+	{version: 9, name: "asset_versions", script: migration0009},
+	{version: 10, name: "asset_custom_fields", script: migration0010},
+	{version: 11, name: "stations", script: migration0011},
+	{version: 12, name: "people", script: migration0012},
+	{version: 13, name: "asset_dates_rfc3339", script: migration0013, apply: convertAssetDates},
+	{version: 14, name: "tags", script: migration0014},
+	{version: 15, name: "asset_hierarchy", script: migration0015},
+	{version: 16, name: "schedules", script: migration0016},
+	{version: 17, name: "webhooks", script: migration0017},
 }
 
 //go:embed migrations/0001_init.sql
@@ -28,6 +45,45 @@ var migration0003 string
 //go:embed migrations/0004_app_settings_provisioned.sql
 var migration0004 string
 
+//go:embed migrations/0005_asset_search.sql
+var migration0005 string
+
+//go:embed migrations/0006_asset_locations.sql
+var migration0006 string
+
+//go:embed migrations/0007_asset_revisions.sql
+var migration0007 string
+
+//go:embed migrations/0008_asset_soft_delete.sql
+var migration0008 string
+
+//go:embed migrations/0009_asset_versions.sql
+var migration0009 string
+
+//go:embed migrations/0010_asset_custom_fields.sql
+var migration0010 string
+
+//go:embed migrations/0011_stations.sql
+var migration0011 string
+
+//go:embed migrations/0012_people.sql
+var migration0012 string
+
+//go:embed migrations/0013_asset_dates_rfc3339.sql
+var migration0013 string
+
+//go:embed migrations/0014_tags.sql
+var migration0014 string
+
+//go:embed migrations/0015_asset_hierarchy.sql
+var migration0015 string
+
+//go:embed migrations/0016_schedules.sql
+var migration0016 string
+
+//go:embed migrations/0017_webhooks.sql
+var migration0017 string
+
 func migrationName(version int) string {
 	for _, m := range migrations {
 		if m.version == version {
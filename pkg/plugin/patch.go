@@ -0,0 +1,295 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// assetPatchColumns maps the AssetPayload JSON fields a merge patch may touch
+// to their assets columns.
+var assetPatchColumns = map[string]string{
+	"title":              "title",
+	"entry_date":         "entry_date",
+	"commissioning_date": "commissioning_date",
+	"station_name":       "station_name",
+	"station_id":         "station_id",
+	"technician":         "technician",
+	"start_date":         "start_date",
+	"end_date":           "end_date",
+	"service":            "service",
+	"staff":              "staff",
+	"latitude":           "latitude",
+	"longitude":          "longitude",
+	"pitch":              "pitch",
+	"roll":               "roll",
+	"custom_fields":      "custom_fields",
+	// tags live in asset_tags and are synced instead of written as a column.
+	"tags": "",
+	// parent_id is checked for cycles by setAssetParent, which writes it.
+	"parent_id": "",
+}
+
+// decodeMergePatch reads an RFC 7396 merge patch: an object whose members
+// replace or, when null, reset the matching fields. custom_fields is merged
+// member by member in the same way.
+func decodeMergePatch(r *http.Request) (map[string]json.RawMessage, error) {
+	defer func() {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != mergePatchContentType && mediaType != "application/json") {
+			return nil, httpError{status: http.StatusUnsupportedMediaType, message: "PATCH requires " + mergePatchContentType}
+		}
+	}
+
+	var patch map[string]json.RawMessage
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxAssetPayloadSize))
+	if err := dec.Decode(&patch); err != nil || patch == nil {
+		return nil, validationError{message: "merge patch must be a JSON object"}
+	}
+	var unknown []string
+	for field := range patch {
+		if _, ok := assetPatchColumns[field]; !ok {
+			unknown = append(unknown, field)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, validationError{message: "unknown fields in merge patch: " + strings.Join(unknown, ", ")}
+	}
+	return patch, nil
+}
+
+// patchAsset applies a merge patch to an asset. The merged record is
+// validated as a whole, but only the patched columns are written. A non-zero
+// ifVersion makes the update conditional like updateAsset.
+func (a *App) patchAsset(ctx context.Context, orgID, assetID int64, patch map[string]json.RawMessage, ifVersion int64) (AssetRecord, error) {
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
+	if err != nil {
+		return AssetRecord{}, err
+	}
+	err = a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		if err := checkAssetVersion(ctx, tx, orgID, assetID, ifVersion); err != nil {
+			return err
+		}
+		current, err := selectAssetPayload(ctx, tx, orgID, assetID)
+		if err != nil {
+			return err
+		}
+		merged, err := mergeAssetPatch(current, patch)
+		if err != nil {
+			return err
+		}
+		merged.normalize(a.location())
+		validator, err := a.assetValidatorFor(ctx, tx, orgID, assetID)
+		if err != nil {
+			return err
+		}
+		if err := merged.validate(fields, validator); err != nil {
+			return err
+		}
+		if len(patch) == 0 {
+			return nil
+		}
+		columns := make([]string, 0, len(patch)+1)
+		for field := range patch {
+			if assetPatchColumns[field] != "" {
+				columns = append(columns, field)
+			}
+		}
+		_, setsName := patch["station_name"]
+		_, setsID := patch["station_id"]
+		if setsName || setsID {
+			// A new name re-resolves the station; a new id renames.
+			if !setsID {
+				merged.StationID = 0
+				columns = append(columns, "station_id")
+			} else if !setsName {
+				columns = append(columns, "station_name")
+			}
+			if err := resolveAssetStation(ctx, tx, orgID, &merged); err != nil {
+				return err
+			}
+		}
+		if err := updateAssetColumns(ctx, tx, orgID, assetID, merged, columns); err != nil {
+			return err
+		}
+		_, setsTechnician := patch["technician"]
+		_, setsStaff := patch["staff"]
+		if setsTechnician || setsStaff {
+			if err := syncAssetPeople(ctx, tx, orgID, assetID, merged); err != nil {
+				return err
+			}
+		}
+		if _, setsTags := patch["tags"]; setsTags {
+			if merged.Tags == nil {
+				merged.Tags = []string{}
+			}
+			if err := syncAssetTags(ctx, tx, orgID, assetID, merged.Tags); err != nil {
+				return err
+			}
+		}
+		if _, setsParent := patch["parent_id"]; setsParent {
+			var parentID int64
+			if merged.ParentID != nil {
+				parentID = *merged.ParentID
+			}
+			if err := setAssetParent(ctx, tx, orgID, assetID, parentID); err != nil {
+				return err
+			}
+		}
+		if err := recordAssetRevision(ctx, tx, orgID, assetID, revisionActionUpdate); err != nil {
+			return err
+		}
+		return a.addAssetEvent(ctx, tx, events, webhookEventAssetUpdated, assetID)
+	})
+	if err != nil {
+		return AssetRecord{}, err
+	}
+	return a.getAsset(ctx, orgID, assetID)
+}
+
+func mergeAssetPatch(current AssetPayload, patch map[string]json.RawMessage) (AssetPayload, error) {
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return AssetPayload{}, err
+	}
+	var target interface{}
+	if err := json.Unmarshal(raw, &target); err != nil {
+		return AssetPayload{}, err
+	}
+	changes := make(map[string]interface{}, len(patch))
+	for field, value := range patch {
+		var change interface{}
+		if err := json.Unmarshal(value, &change); err != nil {
+			return AssetPayload{}, validationError{message: "invalid merge patch: " + err.Error()}
+		}
+		changes[field] = change
+	}
+
+	raw, err = json.Marshal(applyMergePatch(target, changes))
+	if err != nil {
+		return AssetPayload{}, err
+	}
+	var merged AssetPayload
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&merged); err != nil {
+		return AssetPayload{}, validationError{message: "invalid merge patch: " + err.Error()}
+	}
+	return merged, nil
+}
+
+// applyMergePatch is the MergePatch function of RFC 7396, section 2.
+func applyMergePatch(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(object, name)
+			continue
+		}
+		object[name] = applyMergePatch(object[name], value)
+	}
+	return object
+}
+
+// updateAssetColumns writes the given fields of an already normalized and
+// validated payload.
+func updateAssetColumns(ctx context.Context, exec sqlExecer, orgID, assetID int64, payload AssetPayload, fields []string) error {
+	sort.Strings(fields)
+
+	sets := make([]string, 0, len(fields)+2)
+	args := make([]interface{}, 0, len(fields)+3)
+	for _, field := range fields {
+		value, err := assetColumnValue(payload, field)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, assetPatchColumns[field]+" = ?")
+		args = append(args, value)
+	}
+	sets = append(sets, "updated_at = ?", "version = version + 1")
+	args = append(args, time.Now().UTC().Format(time.RFC3339Nano), orgID, assetID)
+
+	res, err := exec.ExecContext(ctx, `UPDATE assets SET `+strings.Join(sets, ", ")+` WHERE org_id = ? AND id = ? AND deleted_at IS NULL`, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errAssetNotFound
+	}
+	return nil
+}
+
+func assetColumnValue(p AssetPayload, field string) (interface{}, error) {
+	switch field {
+	case "title":
+		return p.Title, nil
+	case "entry_date":
+		return p.EntryDate, nil
+	case "commissioning_date":
+		return p.CommissioningDate, nil
+	case "station_name":
+		return p.StationName, nil
+	case "station_id":
+		return p.StationID, nil
+	case "technician":
+		return p.Technician, nil
+	case "start_date":
+		return p.StartDate, nil
+	case "end_date":
+		return p.EndDate, nil
+	case "service":
+		if p.Service == "" {
+			return nil, nil
+		}
+		return p.Service, nil
+	case "staff":
+		staff := p.Staff
+		if staff == nil {
+			staff = []string{}
+		}
+		staffJSON, err := json.Marshal(staff)
+		if err != nil {
+			return nil, fmt.Errorf("marshal staff: %w", err)
+		}
+		return string(staffJSON), nil
+	case "latitude":
+		return p.Latitude, nil
+	case "longitude":
+		return p.Longitude, nil
+	case "pitch":
+		return p.Pitch, nil
+	case "roll":
+		return p.Roll, nil
+	case "custom_fields":
+		return encodeCustomFields(p.CustomFields)
+	default:
+		return nil, fmt.Errorf("unknown asset field %q", field)
+	}
+}
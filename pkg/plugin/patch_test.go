@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func callAssetPatch(t *testing.T, app *App, path, ifMatch, body string) *backend.CallResourceResponse {
+	t.Helper()
+	headers := map[string][]string{"Content-Type": {mergePatchContentType}}
+	if ifMatch != "" {
+		headers["If-Match"] = []string{ifMatch}
+	}
+	return callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPatch, Path: path, Headers: headers, Body: []byte(body)})
+}
+
+func TestPatchAssetMergesFields(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	before, err := app.getAsset(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("get asset: %v", err)
+	}
+
+	resp := callAssetPatch(t, app, "assets/1", `"1"`, `{"service":"Quarterly","technician":" K. Lee "}`)
+	if resp.Status != http.StatusOK || headerValue(resp, "ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %d %q: %s", resp.Status, headerValue(resp, "ETag"), resp.Body)
+	}
+	var patched AssetRecord
+	decodeData(t, resp, &patched)
+	if patched.Service != "Quarterly" || patched.Technician != "K. Lee" || patched.Version != 2 {
+		t.Fatalf("expected patched fields to change, got %+v", patched)
+	}
+	if patched.Title != before.Title || patched.StationName != before.StationName || patched.Latitude != before.Latitude || len(patched.Staff) != len(before.Staff) {
+		t.Fatalf("expected untouched fields to be kept, got %+v", patched)
+	}
+
+	history, err := app.listAssetRevisions(ctx, 1, 1)
+	if err != nil || len(history) != 2 || history[0].Action != revisionActionUpdate || history[0].Asset.Service != "Quarterly" {
+		t.Fatalf("expected an update revision, got %+v (%v)", history, err)
+	}
+
+	resp = callAssetPatch(t, app, "assets/1", "", `{"service":null,"staff":null}`)
+	var cleared AssetRecord
+	decodeData(t, resp, &cleared)
+	if resp.Status != http.StatusOK || cleared.Service != "" || len(cleared.Staff) != 0 {
+		t.Fatalf("expected null to clear optional fields, got %d %+v", resp.Status, cleared)
+	}
+
+	resp = callAssetPatch(t, app, "assets/1", "", `{}`)
+	var unchanged AssetRecord
+	decodeData(t, resp, &unchanged)
+	if resp.Status != http.StatusOK || unchanged.Version != 3 {
+		t.Fatalf("expected empty patch to leave the version at 3, got %d %+v", resp.Status, unchanged)
+	}
+}
+
+func TestPatchAssetRejectsInvalidPatches(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, body := range []string{
+		`{"title":null}`,
+		`{"latitude":120}`,
+		`{"latitude":"north"}`,
+		`{"colour":"red"}`,
+		`[{"op":"replace","path":"/title","value":"x"}]`,
+	} {
+		if resp := callAssetPatch(t, app, "assets/1", "", body); resp.Status != http.StatusBadRequest {
+			t.Fatalf("patch %s: expected 400, got %d", body, resp.Status)
+		}
+	}
+	if resp := callAssetPatch(t, app, "assets/1", `"7"`, `{"title":"Stale"}`); resp.Status != http.StatusPreconditionFailed {
+		t.Fatalf("expected stale If-Match to 412, got %d", resp.Status)
+	}
+	if resp := callAssetPatch(t, app, "assets/999", "", `{"title":"Missing"}`); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected unknown asset to 404, got %d", resp.Status)
+	}
+	resp := callResource(t, app, &backend.CallResourceRequest{
+		Method:  http.MethodPatch,
+		Path:    "assets/1",
+		Headers: map[string][]string{"Content-Type": {"text/plain"}},
+		Body:    []byte(`{"title":"Plain"}`),
+	})
+	if resp.Status != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected text/plain to 415, got %d", resp.Status)
+	}
+
+	record, err := app.getAsset(context.Background(), 1, 1)
+	if err != nil || record.Version != 1 {
+		t.Fatalf("expected rejected patches to leave the asset untouched, got %+v (%v)", record, err)
+	}
+}
@@ -0,0 +1,442 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	personRoleTechnician = "technician"
+	personRoleStaff      = "staff"
+
+	defaultDirectoryLimit = 20
+	maxDirectoryLimit     = 100
+)
+
+var (
+	errPersonNotFound       = errors.New("person not found")
+	errOrganizationNotFound = errors.New("organization not found")
+)
+
+// Person is an entry of the org's people directory, built from the
+// technician and staff of its assets.
+type Person struct {
+	ID               int64  `json:"id"`
+	Name             string `json:"name"`
+	OrganizationID   int64  `json:"organization_id,omitempty"`
+	OrganizationName string `json:"organization_name,omitempty"`
+	AssetCount       int    `json:"asset_count"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+}
+
+// Organization is a company people of the directory work for.
+type Organization struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	PeopleCount int    `json:"people_count"`
+	AssetCount  int    `json:"asset_count"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// DirectorySearch narrows a people or organizations listing. Search matches
+// anywhere in the name, prefix matches first.
+type DirectorySearch struct {
+	Search         string
+	OrganizationID int64
+	Limit          int
+}
+
+func (s *DirectorySearch) normalize() {
+	s.Search = strings.TrimSpace(s.Search)
+	if s.Limit <= 0 {
+		s.Limit = defaultDirectoryLimit
+	}
+	if s.Limit > maxDirectoryLimit {
+		s.Limit = maxDirectoryLimit
+	}
+}
+
+// parsePersonEntry splits "M. Paxl (Acme)" into name and company. Entries
+// without a trailing "(...)" are a name only. Keep in sync with the 0012
+// migration.
+func parsePersonEntry(entry string) (string, string) {
+	entry = strings.TrimSpace(entry)
+	open := strings.Index(entry, "(")
+	if open <= 0 || !strings.HasSuffix(entry, ")") {
+		return entry, ""
+	}
+	return strings.TrimSpace(entry[:open]), strings.TrimSpace(entry[open+1 : len(entry)-1])
+}
+
+// directoryNameKey is the case-insensitive key of a person or company name.
+// Like SQLite's lower(), used by the 0012 migration, it folds ASCII only.
+func directoryNameKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, name)
+}
+
+// syncAssetPeople relinks an asset to the people named in its technician and
+// staff, registering new people and companies on first use.
+func syncAssetPeople(ctx context.Context, q sqlExecQueryer, orgID, assetID int64, payload AssetPayload) error {
+	if _, err := q.ExecContext(ctx, `DELETE FROM asset_people WHERE asset_id = ?`, assetID); err != nil {
+		return err
+	}
+	link := func(entry, role string, position int) error {
+		name, company := parsePersonEntry(entry)
+		if name == "" {
+			return nil
+		}
+		personID, err := resolvePerson(ctx, q, orgID, name, company)
+		if err != nil {
+			return err
+		}
+		_, err = q.ExecContext(ctx, `INSERT OR IGNORE INTO asset_people (asset_id, person_id, role, position) VALUES (?, ?, ?, ?)`, assetID, personID, role, position)
+		return err
+	}
+	if err := link(payload.Technician, personRoleTechnician, 0); err != nil {
+		return err
+	}
+	for i, entry := range payload.Staff {
+		if err := link(entry, personRoleStaff, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolvePerson returns the id of the named person, ignoring case. A company
+// is recorded for people that have none yet.
+func resolvePerson(ctx context.Context, q sqlExecQueryer, orgID int64, name, company string) (int64, error) {
+	var organizationID sql.NullInt64
+	if company != "" {
+		id, err := resolveOrganization(ctx, q, orgID, company)
+		if err != nil {
+			return 0, err
+		}
+		organizationID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	personID, err := queryDirectoryID(ctx, q, `SELECT id FROM people WHERE org_id = ? AND name_key = ?`, orgID, directoryNameKey(name))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	if personID != 0 {
+		if organizationID.Valid {
+			if _, err := q.ExecContext(ctx, `UPDATE people SET organization_id = ?, updated_at = ? WHERE id = ? AND organization_id IS NULL`, organizationID, now, personID); err != nil {
+				return 0, err
+			}
+		}
+		return personID, nil
+	}
+	res, err := q.ExecContext(ctx, `INSERT INTO people (org_id, name, name_key, organization_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`, orgID, name, directoryNameKey(name), organizationID, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func resolveOrganization(ctx context.Context, q sqlExecQueryer, orgID int64, name string) (int64, error) {
+	organizationID, err := queryDirectoryID(ctx, q, `SELECT id FROM organizations WHERE org_id = ? AND name_key = ?`, orgID, directoryNameKey(name))
+	if err != nil || organizationID != 0 {
+		return organizationID, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	res, err := q.ExecContext(ctx, `INSERT INTO organizations (org_id, name, name_key, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`, orgID, name, directoryNameKey(name), now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// queryDirectoryID returns the id selected by query, or 0 when no row matches.
+func queryDirectoryID(ctx context.Context, q sqlQueryer, query string, args ...interface{}) (int64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var id int64
+	if rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+	}
+	return id, rows.Err()
+}
+
+// directoryAssetCount counts the live assets linked through asset_people rows
+// matching the given condition.
+func directoryAssetCount(condition string) string {
+	return `(SELECT COUNT(DISTINCT ap.asset_id) FROM asset_people AS ap JOIN assets AS a ON a.id = ap.asset_id JOIN people AS lp ON lp.id = ap.person_id WHERE ` + condition + ` AND a.deleted_at IS NULL)`
+}
+
+var personSelectColumns = `p.id, p.name, p.organization_id, o.name, p.created_at, p.updated_at, ` + directoryAssetCount("lp.id = p.id")
+
+const personFrom = `people AS p LEFT JOIN organizations AS o ON o.id = p.organization_id`
+
+func scanPerson(scan func(dest ...interface{}) error) (Person, error) {
+	var person Person
+	var organizationID sql.NullInt64
+	var organizationName sql.NullString
+	if err := scan(&person.ID, &person.Name, &organizationID, &organizationName, &person.CreatedAt, &person.UpdatedAt, &person.AssetCount); err != nil {
+		return Person{}, err
+	}
+	person.OrganizationID, person.OrganizationName = organizationID.Int64, organizationName.String
+	return person, nil
+}
+
+// directorySearchQuery completes a directory listing on the table aliased as
+// alias: names containing the search term, prefix matches first.
+func directorySearchQuery(query string, args []interface{}, alias string, search DirectorySearch) (string, []interface{}) {
+	order := alias + ".name_key"
+	if search.Search != "" {
+		pattern := escapeLikePattern(directoryNameKey(search.Search))
+		query += fmt.Sprintf(` AND %s.name_key LIKE ? ESCAPE '\'`, alias)
+		order = fmt.Sprintf(`%s.name_key LIKE ? ESCAPE '\' DESC, %s`, alias, order)
+		args = append(args, "%"+pattern+"%", pattern+"%")
+	}
+	return query + ` ORDER BY ` + order + ` LIMIT ?`, append(args, search.Limit)
+}
+
+func (a *App) listPeople(ctx context.Context, orgID int64, search DirectorySearch) ([]Person, error) {
+	search.normalize()
+	query := `SELECT ` + personSelectColumns + ` FROM ` + personFrom + ` WHERE p.org_id = ?`
+	args := []interface{}{orgID}
+	if search.OrganizationID != 0 {
+		query += ` AND p.organization_id = ?`
+		args = append(args, search.OrganizationID)
+	}
+	query, args = directorySearchQuery(query, args, "p", search)
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	people := []Person{}
+	for rows.Next() {
+		person, err := scanPerson(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		people = append(people, person)
+	}
+	return people, rows.Err()
+}
+
+func getPerson(ctx context.Context, q sqlQueryer, orgID, personID int64) (Person, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+personSelectColumns+` FROM `+personFrom+` WHERE p.org_id = ? AND p.id = ?`, orgID, personID)
+	if err != nil {
+		return Person{}, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return Person{}, err
+		}
+		return Person{}, errPersonNotFound
+	}
+	return scanPerson(rows.Scan)
+}
+
+var organizationSelectColumns = `o.id, o.name, o.created_at, o.updated_at,
+  (SELECT COUNT(*) FROM people WHERE people.organization_id = o.id), ` + directoryAssetCount("lp.organization_id = o.id")
+
+func scanOrganization(scan func(dest ...interface{}) error) (Organization, error) {
+	var organization Organization
+	if err := scan(&organization.ID, &organization.Name, &organization.CreatedAt, &organization.UpdatedAt, &organization.PeopleCount, &organization.AssetCount); err != nil {
+		return Organization{}, err
+	}
+	return organization, nil
+}
+
+func (a *App) listOrganizations(ctx context.Context, orgID int64, search DirectorySearch) ([]Organization, error) {
+	search.normalize()
+	query, args := directorySearchQuery(`SELECT `+organizationSelectColumns+` FROM organizations AS o WHERE o.org_id = ?`, []interface{}{orgID}, "o", search)
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	organizations := []Organization{}
+	for rows.Next() {
+		organization, err := scanOrganization(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		organizations = append(organizations, organization)
+	}
+	return organizations, rows.Err()
+}
+
+func getOrganization(ctx context.Context, q sqlQueryer, orgID, organizationID int64) (Organization, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+organizationSelectColumns+` FROM organizations AS o WHERE o.org_id = ? AND o.id = ?`, orgID, organizationID)
+	if err != nil {
+		return Organization{}, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return Organization{}, err
+		}
+		return Organization{}, errOrganizationNotFound
+	}
+	return scanOrganization(rows.Scan)
+}
+
+// assetDirectoryFilters maps the asset list filters answered through the
+// people directory to the condition on a linked person p and company o.
+var assetDirectoryFilters = map[string]string{
+	"person_id":       "p.id",
+	"organization_id": "p.organization_id",
+	"organization":    "o.name_key",
+}
+
+// buildDirectoryFilter matches assets linked to any person the filter
+// selects, as technician or staff.
+func buildDirectoryFilter(key string, values []string) (string, []interface{}, []string, error) {
+	column := assetDirectoryFilters[key]
+	convert := func(value string) (interface{}, error) { return directoryNameKey(value), nil }
+	if strings.HasSuffix(key, "_id") {
+		convert = func(value string) (interface{}, error) {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, validationError{message: fmt.Sprintf("filter %s expects an id, got %q", key, value)}
+			}
+			return id, nil
+		}
+	}
+	clause, args, applied, err := buildEqualityFilter(column, values, convert)
+	if err != nil || clause == "" {
+		return "", nil, nil, err
+	}
+	return `EXISTS (SELECT 1 FROM asset_people AS ap JOIN people AS p ON p.id = ap.person_id LEFT JOIN organizations AS o ON o.id = p.organization_id WHERE ap.asset_id = assets.id AND ` + clause + `)`, args, applied, nil
+}
+
+func parseDirectorySearch(r *http.Request) (DirectorySearch, error) {
+	query := r.URL.Query()
+	search := DirectorySearch{Search: query.Get("q")}
+	search.Limit, _ = strconv.Atoi(strings.TrimSpace(query.Get("limit")))
+	if value := strings.TrimSpace(query.Get("organization_id")); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return DirectorySearch{}, validationError{message: "organization_id must be an id"}
+		}
+		search.OrganizationID = id
+	}
+	return search, nil
+}
+
+func parseDirectoryID(path, prefix string) (int64, error) {
+	return strconv.ParseInt(strings.Trim(strings.TrimPrefix(path, prefix), "/"), 10, 64)
+}
+
+// handlePeople serves /people?q=&organization_id=&limit=.
+func (a *App) handlePeople(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	search, err := parseDirectorySearch(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	people, err := a.listPeople(r.Context(), orgID, search)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": people})
+}
+
+// handlePerson serves /people/{id}.
+func (a *App) handlePerson(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	personID, err := parseDirectoryID(r.URL.Path, "/people/")
+	if err != nil {
+		http.Error(w, "invalid person id", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	person, err := getPerson(r.Context(), a.db, orgID, personID)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": person})
+}
+
+// handleOrganizations serves /organizations?q=&limit=.
+func (a *App) handleOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	search, err := parseDirectorySearch(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	organizations, err := a.listOrganizations(r.Context(), orgID, search)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": organizations})
+}
+
+// handleOrganization serves /organizations/{id}.
+func (a *App) handleOrganization(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	organizationID, err := parseDirectoryID(r.URL.Path, "/organizations/")
+	if err != nil {
+		http.Error(w, "invalid organization id", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	organization, err := getOrganization(r.Context(), a.db, orgID, organizationID)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": organization})
+}
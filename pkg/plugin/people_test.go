@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestParsePersonEntry(t *testing.T) {
+	tests := []struct {
+		entry, name, company string
+	}{
+		{entry: "M. Paxl (Acme)", name: "M. Paxl", company: "Acme"},
+		{entry: "  J. Meister ( Energiequelle ) ", name: "J. Meister", company: "Energiequelle"},
+		{entry: "GWU", name: "GWU"},
+		{entry: "(Acme)", name: "(Acme)"},
+		{entry: "A. Schmidt (Acme", name: "A. Schmidt (Acme"},
+	}
+	for _, tt := range tests {
+		name, company := parsePersonEntry(tt.entry)
+		if name != tt.name || company != tt.company {
+			t.Fatalf("%q: expected %q/%q, got %q/%q", tt.entry, tt.name, tt.company, name, company)
+		}
+	}
+}
+
+func TestDirectoryBackfilledFromAssets(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	organizations, err := app.listOrganizations(ctx, 1, DirectorySearch{})
+	if err != nil {
+		t.Fatalf("list organizations: %v", err)
+	}
+	if len(organizations) != 2 || organizations[1].Name != "Energiequelle" || organizations[1].PeopleCount != 3 || organizations[1].AssetCount != 2 {
+		t.Fatalf("unexpected organizations: %+v", organizations)
+	}
+
+	people, err := app.listPeople(ctx, 1, DirectorySearch{Search: "PAX"})
+	if err != nil {
+		t.Fatalf("list people: %v", err)
+	}
+	if len(people) != 1 || people[0].Name != "M. Paxl" || people[0].OrganizationName != "Acme" || people[0].AssetCount != 1 {
+		t.Fatalf("expected technician and staff entries to be one person, got %+v", people)
+	}
+
+	people, err = app.listPeople(ctx, 1, DirectorySearch{OrganizationID: organizations[1].ID})
+	if err != nil || len(people) != 3 {
+		t.Fatalf("expected 3 Energiequelle people, got %+v (%v)", people, err)
+	}
+	people, err = app.listPeople(ctx, 1, DirectorySearch{Search: "gwu"})
+	if err != nil || len(people) != 1 || people[0].OrganizationID != 0 {
+		t.Fatalf("expected GWU without a company, got %+v (%v)", people, err)
+	}
+
+	var other []Person
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "people", URL: "people?q=paxl", PluginContext: backend.PluginContext{OrgID: 2}}), &other)
+	if len(other) != 0 {
+		t.Fatalf("expected people to be scoped to the org, got %+v", other)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "organizations/9999"}); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected unknown organization to 404, got %d", resp.Status)
+	}
+}
+
+func TestAssetPeopleSync(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	created, err := app.createAsset(ctx, 1, AssetPayload{
+		Title:             "Directory",
+		EntryDate:         "2025-01-01",
+		CommissioningDate: "2025-01-01",
+		StationName:       "DIR-1",
+		Technician:        "c. braun",
+		StartDate:         "2025-01-01",
+		EndDate:           "2025-01-02",
+		Staff:             []string{"N. Neu (NewCo)"},
+	})
+	if err != nil {
+		t.Fatalf("create asset: %v", err)
+	}
+
+	var people []Person
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "people", URL: "people?q=braun"}), &people)
+	if len(people) != 1 || people[0].AssetCount != 2 {
+		t.Fatalf("expected the technician to link to the existing person, got %+v", people)
+	}
+
+	for _, tt := range []struct {
+		filters map[string][]string
+		total   int64
+	}{
+		{filters: map[string][]string{"organization": {"energiequelle"}}, total: 3},
+		{filters: map[string][]string{"organization": {"NewCo"}}, total: 1},
+		{filters: map[string][]string{"person_id": {strconv.FormatInt(people[0].ID, 10)}}, total: 2},
+	} {
+		result, err := app.listAssets(ctx, 1, AssetListOptions{Filters: tt.filters})
+		if err != nil || result.TotalCount != tt.total {
+			t.Fatalf("%v: expected %d assets, got %+v (%v)", tt.filters, tt.total, result, err)
+		}
+	}
+	if _, err := app.listAssets(ctx, 1, AssetListOptions{Filters: map[string][]string{"organization_id": {"acme"}}}); err == nil {
+		t.Fatal("expected a non-numeric organization_id to be rejected")
+	}
+
+	callAssetPatch(t, app, "assets/"+strconv.FormatInt(created.ID, 10), "", `{"staff":[]}`)
+	result, err := app.listAssets(ctx, 1, AssetListOptions{Filters: map[string][]string{"organization": {"newco"}}})
+	if err != nil || result.TotalCount != 0 {
+		t.Fatalf("expected patch to unlink staff, got %+v (%v)", result, err)
+	}
+
+	if err := app.deleteAsset(ctx, 1, created.ID, 0); err != nil {
+		t.Fatalf("delete asset: %v", err)
+	}
+	person, err := getPerson(ctx, app.db, 1, people[0].ID)
+	if err != nil || person.AssetCount != 1 {
+		t.Fatalf("expected trashed assets to be left out of counts, got %+v (%v)", person, err)
+	}
+}
@@ -0,0 +1,264 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	queryTypeAssets      = "assets"
+	queryTypeAnnotations = "annotations"
+
+	defaultAssetQueryLimit = 1000
+	maxAssetQueryLimit     = 10000
+)
+
+// assetQueryModel is the JSON model sent by dashboards and alert rules. The
+// field names mirror the asset-log-table panel options.
+type assetQueryModel struct {
+	Filters         map[string][]string `json:"filters"`
+	SortKey         string              `json:"sortKey"`
+	SortDirection   string              `json:"sortDirection"`
+	Limit           int                 `json:"limit"`
+	IgnoreTimeRange bool                `json:"ignoreTimeRange"`
+}
+
+func (m assetQueryModel) listOptions(timeRange backend.TimeRange) AssetListOptions {
+	opts := AssetListOptions{Filters: m.Filters}
+	if key := strings.TrimSpace(m.SortKey); key != "" {
+		direction := strings.TrimSpace(m.SortDirection)
+		if direction == "" {
+			direction = string(sortDirectionDesc)
+		}
+		opts.Sort = &AssetListSort{Key: key, Direction: AssetSortDirection(strings.ToLower(direction))}
+	}
+	if !m.IgnoreTimeRange && !timeRange.From.IsZero() && !timeRange.To.IsZero() {
+		opts.Window = &AssetTimeWindow{From: timeRange.From, To: timeRange.To}
+	}
+	return opts
+}
+
+func (m assetQueryModel) limit() int {
+	switch {
+	case m.Limit <= 0:
+		return defaultAssetQueryLimit
+	case m.Limit > maxAssetQueryLimit:
+		return maxAssetQueryLimit
+	default:
+		return m.Limit
+	}
+}
+
+// QueryData handles data queries so asset entries can be used by stock panels
+// and alert rules.
+func (a *App) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	response := backend.NewQueryDataResponse()
+	for _, q := range req.Queries {
+		response.Responses[q.RefID] = a.query(ctx, req.PluginContext, q)
+	}
+	return response, nil
+}
+
+func (a *App) query(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery) backend.DataResponse {
+	if pCtx.OrgID == 0 {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "could not determine caller organization")
+	}
+
+	var model assetQueryModel
+	if len(query.JSON) > 0 {
+		if err := json.Unmarshal(query.JSON, &model); err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid query: %v", err))
+		}
+	}
+
+	var build func([]assetRow, *time.Location) *data.Frame
+	switch query.QueryType {
+	case "", queryTypeAssets:
+		build = newAssetFrame
+	case queryTypeAnnotations:
+		build = newAssetAnnotationFrame
+	default:
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("unsupported query type %q", query.QueryType))
+	}
+
+	rows, err := a.collectAssets(ctx, pCtx.OrgID, model.listOptions(query.TimeRange), model.limit())
+	if err != nil {
+		var valErr validationError
+		if errors.As(err, &valErr) {
+			return backend.ErrDataResponse(backend.StatusBadRequest, valErr.Error())
+		}
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("list assets: %v", err))
+	}
+	frame := build(rows, a.location())
+	frame.RefID = query.RefID
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// collectAssets reads up to limit matching assets in a single query.
+func (a *App) collectAssets(ctx context.Context, orgID int64, opts AssetListOptions, limit int) ([]assetRow, error) {
+	rows := make([]assetRow, 0)
+	err := a.streamAssets(ctx, orgID, opts, limit, false, func(row assetRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func newAssetFrame(records []assetRow, loc *time.Location) *data.Frame {
+	ids := make([]int64, len(records))
+	titles := make([]string, len(records))
+	entryDates := make([]*time.Time, len(records))
+	commissioningDates := make([]*time.Time, len(records))
+	stations := make([]string, len(records))
+	technicians := make([]string, len(records))
+	startDates := make([]*time.Time, len(records))
+	endDates := make([]*time.Time, len(records))
+	services := make([]string, len(records))
+	staff := make([]string, len(records))
+	latitudes := make([]float64, len(records))
+	longitudes := make([]float64, len(records))
+	pitches := make([]float64, len(records))
+	rolls := make([]float64, len(records))
+	attachmentCounts := make([]int64, len(records))
+	createdAt := make([]*time.Time, len(records))
+	updatedAt := make([]*time.Time, len(records))
+
+	for i, record := range records {
+		ids[i] = record.ID
+		titles[i] = record.Title
+		entryDates[i] = parseAssetTimestamp(record.EntryDate, loc)
+		commissioningDates[i] = parseAssetTimestamp(record.CommissioningDate, loc)
+		stations[i] = record.StationName
+		technicians[i] = record.Technician
+		startDates[i] = parseAssetTimestamp(record.StartDate, loc)
+		endDates[i] = parseAssetTimestamp(record.EndDate, loc)
+		services[i] = record.Service
+		staff[i] = strings.Join(record.Staff, ", ")
+		latitudes[i] = record.Latitude
+		longitudes[i] = record.Longitude
+		pitches[i] = record.Pitch
+		rolls[i] = record.Roll
+		attachmentCounts[i] = int64(record.attachmentCount)
+		createdAt[i] = parseAssetTimestamp(record.CreatedAt, loc)
+		updatedAt[i] = parseAssetTimestamp(record.UpdatedAt, loc)
+	}
+
+	frame := data.NewFrame("assets",
+		data.NewField("id", nil, ids),
+		data.NewField("title", nil, titles),
+		data.NewField("entry_date", nil, entryDates),
+		data.NewField("commissioning_date", nil, commissioningDates),
+		data.NewField("station_name", nil, stations),
+		data.NewField("technician", nil, technicians),
+		data.NewField("start_date", nil, startDates),
+		data.NewField("end_date", nil, endDates),
+		data.NewField("service", nil, services),
+		data.NewField("staff", nil, staff),
+		data.NewField("latitude", nil, latitudes),
+		data.NewField("longitude", nil, longitudes),
+		data.NewField("pitch", nil, pitches),
+		data.NewField("roll", nil, rolls),
+		data.NewField("attachments", nil, attachmentCounts),
+		data.NewField("created_at", nil, createdAt),
+		data.NewField("updated_at", nil, updatedAt),
+	)
+	frame.SetMeta(&data.FrameMeta{PreferredVisualization: data.VisTypeTable})
+	return frame
+}
+
+// newAssetAnnotationFrame renders entries as region annotations spanning their
+// start_date to end_date window. Entries without a parseable end date become
+// point annotations at their start; entries without a parseable start date are
+// skipped. Full-dates are days in loc.
+func newAssetAnnotationFrame(records []assetRow, loc *time.Location) *data.Frame {
+	times := make([]time.Time, 0, len(records))
+	timeEnds := make([]time.Time, 0, len(records))
+	titles := make([]string, 0, len(records))
+	texts := make([]string, 0, len(records))
+	tags := make([]string, 0, len(records))
+	ids := make([]int64, 0, len(records))
+
+	for _, record := range records {
+		start := parseAssetTimestamp(record.StartDate, loc)
+		if start == nil {
+			continue
+		}
+		end := *start
+		if parsed := parseAssetTimestamp(record.EndDate, loc); parsed != nil {
+			end = *parsed
+			if len(strings.TrimSpace(record.EndDate)) == len(assetDateOnlyLayout) {
+				end = end.In(loc).AddDate(0, 0, 1).UTC()
+			}
+		}
+		if end.Before(*start) {
+			end = *start
+		}
+
+		textParts := make([]string, 0, 2)
+		if record.Service != "" {
+			textParts = append(textParts, record.Service)
+		}
+		if len(record.Staff) > 0 {
+			textParts = append(textParts, "Staff: "+strings.Join(record.Staff, ", "))
+		}
+
+		tagParts := make([]string, 0, 2)
+		for _, tag := range []string{record.StationName, record.Technician} {
+			if tag != "" {
+				tagParts = append(tagParts, tag)
+			}
+		}
+
+		times = append(times, *start)
+		timeEnds = append(timeEnds, end)
+		titles = append(titles, record.Title)
+		texts = append(texts, strings.Join(textParts, "\n"))
+		tags = append(tags, strings.Join(tagParts, ","))
+		ids = append(ids, record.ID)
+	}
+
+	return data.NewFrame("annotations",
+		data.NewField("time", nil, times),
+		data.NewField("timeEnd", nil, timeEnds),
+		data.NewField("title", nil, titles),
+		data.NewField("text", nil, texts),
+		data.NewField("tags", nil, tags),
+		data.NewField("id", nil, ids),
+	)
+}
+
+var assetTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseAssetTimestamp parses the date formats found in the assets table,
+// returning nil when the value is empty or not recognised. Values without an
+// offset, full-dates included, are read in loc.
+func parseAssetTimestamp(value string, loc *time.Location) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	for _, layout := range assetTimestampLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			parsed = parsed.UTC()
+			return &parsed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package plugin
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "assets.db"))
+	inst, err := NewApp(context.Background(), backend.AppInstanceSettings{})
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+	app := inst.(*App)
+	t.Cleanup(app.Dispose)
+	return app
+}
+
+// createTestAsset creates an org 1 asset from a valid payload, after modify
+// has adjusted it.
+func createTestAsset(t *testing.T, app *App, modify func(*AssetPayload)) AssetRecord {
+	t.Helper()
+	payload := AssetPayload{
+		Title:             "Test asset",
+		EntryDate:         "2025-01-01",
+		CommissioningDate: "2025-01-01",
+		StationName:       "TEST-1",
+		Technician:        "J. Doe",
+		StartDate:         "2025-01-01",
+		EndDate:           "2025-01-02",
+	}
+	if modify != nil {
+		modify(&payload)
+	}
+	record, err := app.createAsset(context.Background(), 1, payload)
+	if err != nil {
+		t.Fatalf("create asset %s: %v", payload.Title, err)
+	}
+	return record
+}
+
+func TestQueryDataReturnsAssetFrame(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, tc := range []struct {
+		name     string
+		json     string
+		from, to time.Time
+		expRows  int
+	}{
+		{
+			name:    "whole seeded range",
+			json:    `{}`,
+			from:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:      time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+			expRows: 2,
+		},
+		{
+			name:    "overlapping maintenance window",
+			json:    `{}`,
+			from:    time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC),
+			to:      time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+			expRows: 1,
+		},
+		{
+			name:    "date-only end date covers the whole day",
+			json:    `{}`,
+			from:    time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC),
+			to:      time.Date(2024, 2, 20, 13, 0, 0, 0, time.UTC),
+			expRows: 1,
+		},
+		{
+			name:    "filtered by station",
+			json:    `{"filters":{"station_name":["MT-202"]},"ignoreTimeRange":true}`,
+			expRows: 1,
+		},
+		{
+			name:    "outside time range",
+			json:    `{}`,
+			from:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:      time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC),
+			expRows: 0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := app.QueryData(context.Background(), &backend.QueryDataRequest{
+				PluginContext: backend.PluginContext{OrgID: 1},
+				Queries: []backend.DataQuery{{
+					RefID:     "A",
+					JSON:      []byte(tc.json),
+					TimeRange: backend.TimeRange{From: tc.from, To: tc.to},
+				}},
+			})
+			if err != nil {
+				t.Fatalf("QueryData returned error: %v", err)
+			}
+			res := resp.Responses["A"]
+			if res.Error != nil {
+				t.Fatalf("unexpected response error: %v", res.Error)
+			}
+			if len(res.Frames) != 1 {
+				t.Fatalf("expected one frame, got %d", len(res.Frames))
+			}
+			if rows := res.Frames[0].Rows(); rows != tc.expRows {
+				t.Fatalf("expected %d rows, got %d", tc.expRows, rows)
+			}
+		})
+	}
+}
+
+func TestQueryDataRejectsUnknownQueryType(t *testing.T) {
+	app := newTestApp(t)
+
+	resp, err := app.QueryData(context.Background(), &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{OrgID: 1},
+		Queries:       []backend.DataQuery{{RefID: "A", QueryType: "unknown"}},
+	})
+	if err != nil {
+		t.Fatalf("QueryData returned error: %v", err)
+	}
+	if resp.Responses["A"].Error == nil {
+		t.Fatalf("expected error for unknown query type")
+	}
+}
+
+func TestQueryDataAnnotationsEmitRegions(t *testing.T) {
+	app := newTestApp(t)
+
+	resp, err := app.QueryData(context.Background(), &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{OrgID: 1},
+		Queries: []backend.DataQuery{{
+			RefID:     "Anno",
+			QueryType: queryTypeAnnotations,
+			JSON:      []byte(`{"filters":{"technician":["A. Schmidt"]}}`),
+			TimeRange: backend.TimeRange{
+				From: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("QueryData returned error: %v", err)
+	}
+	res := resp.Responses["Anno"]
+	if res.Error != nil {
+		t.Fatalf("unexpected response error: %v", res.Error)
+	}
+	frame := res.Frames[0]
+	if frame.Rows() != 1 {
+		t.Fatalf("expected one annotation, got %d", frame.Rows())
+	}
+	start, _ := frame.FieldByName("time")
+	end, _ := frame.FieldByName("timeEnd")
+	if start == nil || end == nil {
+		t.Fatalf("expected time and timeEnd fields")
+	}
+	expEnd := time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC)
+	if got := end.At(0).(time.Time); !got.Equal(expEnd) {
+		t.Fatalf("expected region to end at %s, got %s", expEnd, got)
+	}
+	tags, _ := frame.FieldByName("tags")
+	if got := tags.At(0).(string); got != "MT-202,A. Schmidt" {
+		t.Fatalf("unexpected tags %q", got)
+	}
+}
+
+func TestQueryDataMapsValidationErrorsToBadRequest(t *testing.T) {
+	app := newTestApp(t)
+
+	resp, err := app.QueryData(context.Background(), &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{OrgID: 1},
+		Queries: []backend.DataQuery{{
+			RefID: "A",
+			JSON:  []byte(`{"sortKey":"distance","ignoreTimeRange":true}`),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("QueryData returned error: %v", err)
+	}
+	if res := resp.Responses["A"]; res.Status != backend.StatusBadRequest {
+		t.Fatalf("expected bad request status, got %v (%v)", res.Status, res.Error)
+	}
+}
+
+func TestHandlerServesDataSourceQueries(t *testing.T) {
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "assets.db"))
+	handler := NewHandler()
+
+	resp, err := handler.QueryData(context.Background(), &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{
+			OrgID:                      1,
+			PluginID:                   DataSourcePluginID,
+			DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{UID: "assetlog"},
+		},
+		Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"ignoreTimeRange":true,"limit":1}`)}},
+	})
+	if err != nil {
+		t.Fatalf("QueryData returned error: %v", err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("unexpected response error: %v", res.Error)
+	}
+	if rows := res.Frames[0].Rows(); rows != 1 {
+		t.Fatalf("expected the limit to cap rows at 1, got %d", rows)
+	}
+}
+
+func TestOnlyAppInstancesRunWorkers(t *testing.T) {
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "assets.db"))
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name     string
+		pCtx     backend.PluginContext
+		expected bool
+	}{
+		{name: "app", pCtx: backend.PluginContext{OrgID: 1, AppInstanceSettings: &backend.AppInstanceSettings{}}, expected: true},
+		{name: "data source", pCtx: backend.PluginContext{OrgID: 1, DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{UID: "assetlog"}}, expected: false},
+	} {
+		inst, err := instanceProvider{}.NewInstance(ctx, tc.pCtx)
+		if err != nil {
+			t.Fatalf("%s: new instance: %v", tc.name, err)
+		}
+		app := inst.(*App)
+		running := app.stopSweeper != nil && app.stopDispatcher != nil
+		app.Dispose()
+		if running != tc.expected {
+			t.Fatalf("%s: expected workers running to be %v", tc.name, tc.expected)
+		}
+	}
+}
+
+func TestQueryDataAnnotationsWithoutEndDateArePoints(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	record, err := app.createAsset(ctx, 1, AssetPayload{
+		Title:             "Open-ended inspection",
+		EntryDate:         "2025-06-01 08:00",
+		CommissioningDate: "2025-06-01",
+		StationName:       "MT-202",
+		Technician:        "A. Schmidt",
+		StartDate:         "2025-06-02 10:00",
+		EndDate:           "2025-06-02",
+	})
+	if err != nil {
+		t.Fatalf("create asset: %v", err)
+	}
+	// End dates the date migration could not convert are kept as entered.
+	if _, err := app.db.Exec(`UPDATE assets SET end_date = 'tbd' WHERE id = ?`, record.ID); err != nil {
+		t.Fatalf("set end date: %v", err)
+	}
+
+	resp, err := app.QueryData(ctx, &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{OrgID: 1},
+		Queries: []backend.DataQuery{{
+			RefID:     "Anno",
+			QueryType: queryTypeAnnotations,
+			TimeRange: backend.TimeRange{
+				From: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC),
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("QueryData returned error: %v", err)
+	}
+	frame := resp.Responses["Anno"].Frames[0]
+	if frame.Rows() != 1 {
+		t.Fatalf("expected one annotation, got %d", frame.Rows())
+	}
+	start, _ := frame.FieldByName("time")
+	end, _ := frame.FieldByName("timeEnd")
+	if !start.At(0).(time.Time).Equal(end.At(0).(time.Time)) {
+		t.Fatalf("expected a point annotation, got %v to %v", start.At(0), end.At(0))
+	}
+}
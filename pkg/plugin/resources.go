@@ -73,7 +73,29 @@ func (a *App) registerRoutes(mux *http.ServeMux) {
 
 	// register the assets routes (must match what the frontend calls)
 	mux.HandleFunc("/assets", a.handleAssetsCollection)
+	mux.HandleFunc("/assets.geojson", a.handleAssetsGeoJSON)
+	mux.HandleFunc("/assets.csv", a.handleAssetsCSV)
+	mux.HandleFunc("/assets/import", a.handleAssetImport)
+	mux.HandleFunc("/assets/batch", a.handleAssetBatch)
+	mux.HandleFunc("/assets/trash", a.handleAssetTrash)
+	mux.HandleFunc("/assets/date-issues", a.handleAssetDateIssues)
 	mux.HandleFunc("/assets/", a.handleAssetResource)
+	mux.HandleFunc("/custom-fields", a.handleAssetFields)
+	mux.HandleFunc("/custom-fields/", a.handleAssetField)
+	mux.HandleFunc("/stations", a.handleStations)
+	mux.HandleFunc("/stations/", a.handleStation)
+	mux.HandleFunc("/people", a.handlePeople)
+	mux.HandleFunc("/people/", a.handlePerson)
+	mux.HandleFunc("/organizations", a.handleOrganizations)
+	mux.HandleFunc("/organizations/", a.handleOrganization)
+	mux.HandleFunc("/tags", a.handleTags)
+	mux.HandleFunc("/tags/", a.handleTag)
+	mux.HandleFunc("/schedules", a.handleSchedules)
+	mux.HandleFunc("/schedules/due", a.handleSchedulesDue)
+	mux.HandleFunc("/schedules/overdue", a.handleSchedulesDue)
+	mux.HandleFunc("/schedules/", a.handleSchedule)
+	mux.HandleFunc("/webhooks", a.handleWebhooks)
+	mux.HandleFunc("/webhooks/", a.handleWebhook)
 
 	// fallback debug handler - runs only if no other route matches.
 	// Logs the incoming path so you can see what Grafana forwards.
@@ -95,20 +95,20 @@ func TestCallResource(t *testing.T) {
 				if payload.Meta.MaxUploadSizeBytes == 0 {
 					t.Fatalf("expected maxUploadSizeBytes in meta")
 				}
-				if payload.Meta.Page < 1 {
-					t.Fatalf("expected page to be >= 1, got %d", payload.Meta.Page)
+				if payload.Meta.Page == nil || *payload.Meta.Page < 1 {
+					t.Fatalf("expected page to be >= 1, got %v", payload.Meta.Page)
 				}
 				if payload.Meta.PageSize <= 0 {
 					t.Fatalf("expected page size > 0, got %d", payload.Meta.PageSize)
 				}
-				if payload.Meta.TotalCount < int64(len(payload.Data)) {
-					t.Fatalf("expected total count >= returned records, got %d", payload.Meta.TotalCount)
+				if payload.Meta.TotalCount == nil || *payload.Meta.TotalCount < int64(len(payload.Data)) {
+					t.Fatalf("expected total count >= returned records, got %v", payload.Meta.TotalCount)
 				}
 				if payload.Meta.Filters == nil {
 					t.Fatalf("expected filters map to be present")
 				}
-				if payload.Meta.PageCount < 1 {
-					t.Fatalf("expected page count >= 1, got %d", payload.Meta.PageCount)
+				if payload.Meta.PageCount == nil || *payload.Meta.PageCount < 1 {
+					t.Fatalf("expected page count >= 1, got %v", payload.Meta.PageCount)
 				}
 			},
 		},
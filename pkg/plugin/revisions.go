@@ -0,0 +1,414 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	revisionActionCreate  = "create"
+	revisionActionUpdate  = "update"
+	revisionActionDelete  = "delete"
+	revisionActionRestore = "restore"
+)
+
+var errAssetRevisionNotFound = errors.New("asset revision not found")
+
+// AssetRevision is a snapshot of an asset taken on every mutation. Create,
+// update and restore revisions hold the state that was written; delete
+// revisions hold the state that was removed.
+type AssetRevision struct {
+	AssetID   int64              `json:"asset_id"`
+	Revision  int64              `json:"revision"`
+	Action    string             `json:"action"`
+	User      *AssetRevisionUser `json:"user,omitempty"`
+	CreatedAt string             `json:"created_at"`
+	Asset     AssetPayload       `json:"asset"`
+}
+
+type AssetRevisionUser struct {
+	Login string `json:"login,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// AssetFieldChange is one field that differs between two revisions.
+type AssetFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type assetRevisionDiff struct {
+	From    int64              `json:"from"`
+	To      int64              `json:"to"`
+	Changes []AssetFieldChange `json:"changes"`
+}
+
+// recordAssetRevision snapshots the current row of an asset into
+// asset_revisions, attributing it to the Grafana user of the request context.
+func recordAssetRevision(ctx context.Context, q sqlExecQueryer, orgID, assetID int64, action string) error {
+	payload, err := selectAssetPayload(ctx, q, orgID, assetID)
+	if err != nil {
+		return err
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal revision: %w", err)
+	}
+
+	var login, name, email interface{}
+	if pc, ok := PluginContextFromContext(ctx); ok && pc.User != nil {
+		login, name, email = nullIfEmpty(pc.User.Login), nullIfEmpty(pc.User.Name), nullIfEmpty(pc.User.Email)
+	}
+
+	_, err = q.ExecContext(ctx, `INSERT INTO asset_revisions (asset_id, org_id, revision, action, payload, user_login, user_name, user_email, created_at)
+SELECT ?, ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ?, ?, ?, ? FROM asset_revisions WHERE asset_id = ?`,
+		assetID,
+		orgID,
+		action,
+		string(payloadJSON),
+		login,
+		name,
+		email,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		assetID,
+	)
+	return err
+}
+
+func selectAssetPayload(ctx context.Context, q sqlQueryer, orgID, assetID int64) (AssetPayload, error) {
+	rows, err := q.QueryContext(ctx, `SELECT title, entry_date, commissioning_date, station_name, technician, start_date, end_date, service, staff, latitude, longitude, pitch, roll, custom_fields, station_id, parent_id FROM assets WHERE org_id = ? AND id = ?`, orgID, assetID)
+	if err != nil {
+		return AssetPayload{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return AssetPayload{}, err
+		}
+		return AssetPayload{}, errAssetNotFound
+	}
+	var payload AssetPayload
+	var service, staffRaw sqlNullString
+	var customRaw string
+	var stationID, parentID sql.NullInt64
+	if err := rows.Scan(
+		&payload.Title,
+		&payload.EntryDate,
+		&payload.CommissioningDate,
+		&payload.StationName,
+		&payload.Technician,
+		&payload.StartDate,
+		&payload.EndDate,
+		&service,
+		&staffRaw,
+		&payload.Latitude,
+		&payload.Longitude,
+		&payload.Pitch,
+		&payload.Roll,
+		&customRaw,
+		&stationID,
+		&parentID,
+	); err != nil {
+		return AssetPayload{}, err
+	}
+	payload.Service = service.String
+	payload.Staff = []string{}
+	if staffRaw.Valid && strings.TrimSpace(staffRaw.String) != "" {
+		_ = json.Unmarshal([]byte(staffRaw.String), &payload.Staff)
+	}
+	payload.CustomFields = decodeCustomFields(customRaw)
+	payload.StationID = stationID.Int64
+	payload.ParentID = &parentID.Int64
+	rows.Close()
+
+	tags, err := loadAssetTags(ctx, q, []int64{assetID})
+	if err != nil {
+		return AssetPayload{}, err
+	}
+	payload.Tags = tags[assetID]
+	if payload.Tags == nil {
+		payload.Tags = []string{}
+	}
+	return payload, nil
+}
+
+const assetRevisionColumns = `asset_id, revision, action, payload, user_login, user_name, user_email, created_at`
+
+func scanAssetRevision(scan func(dest ...interface{}) error) (AssetRevision, error) {
+	var rev AssetRevision
+	var payloadRaw string
+	var login, name, email sqlNullString
+	if err := scan(&rev.AssetID, &rev.Revision, &rev.Action, &payloadRaw, &login, &name, &email, &rev.CreatedAt); err != nil {
+		return AssetRevision{}, err
+	}
+	if err := json.Unmarshal([]byte(payloadRaw), &rev.Asset); err != nil {
+		return AssetRevision{}, fmt.Errorf("decode revision %d of asset %d: %w", rev.Revision, rev.AssetID, err)
+	}
+	if rev.Asset.Staff == nil {
+		rev.Asset.Staff = []string{}
+	}
+	if login.Valid || name.Valid || email.Valid {
+		rev.User = &AssetRevisionUser{Login: login.String, Name: name.String, Email: email.String}
+	}
+	return rev, nil
+}
+
+// listAssetRevisions returns the history of an asset, newest first. History
+// outlives the asset, so deleted assets can still be inspected and restored.
+func (a *App) listAssetRevisions(ctx context.Context, orgID, assetID int64) ([]AssetRevision, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT `+assetRevisionColumns+` FROM asset_revisions WHERE org_id = ? AND asset_id = ? ORDER BY revision DESC`, orgID, assetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []AssetRevision{}
+	for rows.Next() {
+		rev, err := scanAssetRevision(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		rev.Asset.localizeDates(a.location())
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, errAssetNotFound
+	}
+	return revisions, nil
+}
+
+func (a *App) getAssetRevision(ctx context.Context, orgID, assetID, revision int64) (AssetRevision, error) {
+	rev, err := scanAssetRevision(a.db.QueryRowContext(ctx, `SELECT `+assetRevisionColumns+` FROM asset_revisions WHERE org_id = ? AND asset_id = ? AND revision = ?`, orgID, assetID, revision).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AssetRevision{}, errAssetRevisionNotFound
+	}
+	if err != nil {
+		return AssetRevision{}, err
+	}
+	rev.Asset.localizeDates(a.location())
+	return rev, nil
+}
+
+// diffAssetRevisions compares two revisions field by field. A zero "to"
+// selects the latest revision and a zero "from" the one before "to".
+func (a *App) diffAssetRevisions(ctx context.Context, orgID, assetID, from, to int64) (assetRevisionDiff, error) {
+	if to == 0 {
+		if err := a.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(revision), 0) FROM asset_revisions WHERE org_id = ? AND asset_id = ?`, orgID, assetID).Scan(&to); err != nil {
+			return assetRevisionDiff{}, err
+		}
+		if to == 0 {
+			return assetRevisionDiff{}, errAssetNotFound
+		}
+	}
+	if from == 0 {
+		from = to - 1
+	}
+	if from < 1 {
+		return assetRevisionDiff{}, validationError{message: "asset has a single revision; nothing to compare"}
+	}
+
+	fromRev, err := a.getAssetRevision(ctx, orgID, assetID, from)
+	if err != nil {
+		return assetRevisionDiff{}, err
+	}
+	toRev, err := a.getAssetRevision(ctx, orgID, assetID, to)
+	if err != nil {
+		return assetRevisionDiff{}, err
+	}
+	changes, err := diffAssetPayloads(fromRev.Asset, toRev.Asset)
+	if err != nil {
+		return assetRevisionDiff{}, err
+	}
+	return assetRevisionDiff{From: from, To: to, Changes: changes}, nil
+}
+
+// diffAssetPayloads lists the JSON fields whose values differ, sorted by name.
+func diffAssetPayloads(from, to AssetPayload) ([]AssetFieldChange, error) {
+	fromFields, err := payloadFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := payloadFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []AssetFieldChange{}
+	for field, toValue := range toFields {
+		if fromValue := fromFields[field]; !reflect.DeepEqual(fromValue, toValue) {
+			changes = append(changes, AssetFieldChange{Field: field, From: fromValue, To: toValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func payloadFields(p AssetPayload) (map[string]interface{}, error) {
+	if p.Staff == nil {
+		p.Staff = []string{}
+	}
+	if p.CustomFields == nil {
+		p.CustomFields = map[string]interface{}{}
+	}
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	return fields, json.Unmarshal(raw, &fields)
+}
+
+// restoreAssetRevision writes the snapshot of a revision back to the asset,
+// taking it out of the trash or re-creating it under its original id if it
+// was purged. Attachments are not
+// part of revisions and stay as they are.
+func (a *App) restoreAssetRevision(ctx context.Context, orgID, assetID, revision int64) (AssetRecord, error) {
+	rev, err := a.getAssetRevision(ctx, orgID, assetID, revision)
+	if err != nil {
+		return AssetRecord{}, err
+	}
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
+	if err != nil {
+		return AssetRecord{}, err
+	}
+	payload := rev.Asset
+	// Values of fields deleted since the revision was taken are dropped, a
+	// station deleted since is looked up again by name and an asset whose
+	// parent is gone becomes a root.
+	payload.CustomFields = fields.retain(payload.CustomFields)
+	if payload.StationID != 0 {
+		if _, err := getStation(ctx, a.db, orgID, payload.StationID); errors.Is(err, errStationNotFound) {
+			payload.StationID = 0
+		} else if err != nil {
+			return AssetRecord{}, err
+		}
+	}
+	if payload.ParentID != nil && *payload.ParentID != 0 {
+		parentID, err := queryDirectoryID(ctx, a.db, `SELECT id FROM assets WHERE org_id = ? AND id = ? AND deleted_at IS NULL`, orgID, *payload.ParentID)
+		if err != nil {
+			return AssetRecord{}, err
+		}
+		payload.ParentID = &parentID
+	}
+	payload.normalize(a.location())
+	validator, err := a.assetValidatorFor(ctx, a.db, orgID, assetID)
+	if err != nil {
+		return AssetRecord{}, err
+	}
+	if err := payload.validate(fields, validator); err != nil {
+		var valErr validationError
+		errors.As(err, &valErr)
+		return AssetRecord{}, validationError{message: fmt.Sprintf("revision %d cannot be restored: %v", revision, err), fields: valErr.fields}
+	}
+
+	err = a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE assets SET deleted_at = NULL WHERE org_id = ? AND id = ?`, orgID, assetID); err != nil {
+			return err
+		}
+		err := updateAssetRow(ctx, tx, orgID, assetID, payload)
+		if errors.Is(err, errAssetNotFound) {
+			_, err = insertAssetWithID(ctx, tx, orgID, assetID, payload)
+		}
+		if err != nil {
+			return err
+		}
+		if err := recordAssetRevision(ctx, tx, orgID, assetID, revisionActionRestore); err != nil {
+			return err
+		}
+		return a.addAssetEvent(ctx, tx, events, webhookEventAssetUpdated, assetID)
+	})
+	if err != nil {
+		return AssetRecord{}, err
+	}
+	return a.getAsset(ctx, orgID, assetID)
+}
+
+// handleAssetHistory serves /assets/{id}/history[/diff|/{rev}[/restore]].
+func (a *App) handleAssetHistory(w http.ResponseWriter, r *http.Request, orgID, assetID int64, segments []string) {
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodGet:
+		revisions, err := a.listAssetRevisions(r.Context(), orgID, assetID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": revisions})
+	case len(segments) == 1 && segments[0] == "diff" && r.Method == http.MethodGet:
+		from, err := parseRevisionParam(r.URL.Query().Get("from"))
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		to, err := parseRevisionParam(r.URL.Query().Get("to"))
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		diff, err := a.diffAssetRevisions(r.Context(), orgID, assetID, from, to)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": diff})
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		revision, err := parseRevisionParam(segments[0])
+		if err != nil || revision == 0 {
+			http.Error(w, "invalid revision", http.StatusBadRequest)
+			return
+		}
+		rev, err := a.getAssetRevision(r.Context(), orgID, assetID, revision)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": rev})
+	case len(segments) == 2 && segments[1] == "restore" && r.Method == http.MethodPost:
+		revision, err := parseRevisionParam(segments[0])
+		if err != nil || revision == 0 {
+			http.Error(w, "invalid revision", http.StatusBadRequest)
+			return
+		}
+		asset, err := a.restoreAssetRevision(r.Context(), orgID, assetID, revision)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": asset})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func parseRevisionParam(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision < 1 {
+		return 0, validationError{message: fmt.Sprintf("invalid revision %q", value)}
+	}
+	return revision, nil
+}
+
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// callResource sends req through CallResource and returns the response. The
+// plugin context defaults to org 1.
+func callResource(t *testing.T, app *App, req *backend.CallResourceRequest) *backend.CallResourceResponse {
+	t.Helper()
+	if req.PluginContext.OrgID == 0 {
+		req.PluginContext.OrgID = 1
+	}
+	var r mockCallResourceResponseSender
+	if err := app.CallResource(context.Background(), req, &r); err != nil {
+		t.Fatalf("CallResource %s %s: %v", req.Method, req.Path, err)
+	}
+	return r.response
+}
+
+func decodeData(t *testing.T, resp *backend.CallResourceResponse, dest interface{}) {
+	t.Helper()
+	var out struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body, &out); err != nil {
+		t.Fatalf("decode response: %v (%s)", err, resp.Body)
+	}
+	if err := json.Unmarshal(out.Data, dest); err != nil {
+		t.Fatalf("decode data: %v (%s)", err, out.Data)
+	}
+}
+
+func TestAssetRevisionHistory(t *testing.T) {
+	app := newTestApp(t)
+	editor := backend.PluginContext{OrgID: 1, User: &backend.User{Login: "editor", Name: "Ed Itor", Email: "ed@example.com"}}
+
+	original, err := app.getAsset(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("get asset: %v", err)
+	}
+	updated := `{"title":"Recommissioned","entry_date":"2024-02-01","commissioning_date":"2024-03-01","station_name":"` + original.StationName + `","technician":"` + original.Technician + `","start_date":"` + original.StartDate + `","end_date":"` + original.EndDate + `","latitude":` + jsonNumber(original.Latitude) + `,"longitude":` + jsonNumber(original.Longitude) + `}`
+	resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: "assets/1", Body: []byte(updated), PluginContext: editor})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", resp.Status, resp.Body)
+	}
+
+	var history []AssetRevision
+	resp = callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/1/history"})
+	decodeData(t, resp, &history)
+	if len(history) != 2 || history[0].Revision != 2 || history[0].Action != revisionActionUpdate || history[1].Action != revisionActionCreate {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+	if history[0].User == nil || history[0].User.Login != "editor" || history[1].User != nil {
+		t.Fatalf("expected revision 2 attributed to editor, got %+v / %+v", history[0].User, history[1].User)
+	}
+	if history[1].Asset.CommissioningDate != original.CommissioningDate {
+		t.Fatalf("expected baseline revision to keep the original commissioning date, got %q", history[1].Asset.CommissioningDate)
+	}
+
+	var diff assetRevisionDiff
+	resp = callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/1/history/diff", URL: "assets/1/history/diff?from=1&to=2"})
+	decodeData(t, resp, &diff)
+	fields := map[string]AssetFieldChange{}
+	for _, change := range diff.Changes {
+		fields[change.Field] = change
+	}
+	if change, ok := fields["commissioning_date"]; !ok || change.From != original.CommissioningDate || change.To != "2024-03-01" {
+		t.Fatalf("expected commissioning_date change, got %+v", diff.Changes)
+	}
+	if _, ok := fields["station_name"]; ok {
+		t.Fatalf("unchanged fields must not be reported: %+v", diff.Changes)
+	}
+
+	var restored AssetRecord
+	resp = callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "assets/1/history/1/restore", PluginContext: editor})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("restore: expected 200, got %d: %s", resp.Status, resp.Body)
+	}
+	decodeData(t, resp, &restored)
+	if restored.CommissioningDate != original.CommissioningDate || restored.Title != original.Title {
+		t.Fatalf("expected original values after restore, got %+v", restored)
+	}
+
+	resp = callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/1/history/9"})
+	if resp.Status != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown revision, got %d", resp.Status)
+	}
+}
+
+func TestAssetRevisionRestoresDeletedAsset(t *testing.T) {
+	app := newTestApp(t)
+
+	resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: "assets/2"})
+	if resp.Status != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.Status)
+	}
+
+	var history []AssetRevision
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/2/history"}), &history)
+	if len(history) != 2 || history[0].Action != revisionActionDelete || history[0].Asset.StationName != "MT-202" {
+		t.Fatalf("expected delete revision holding the removed state, got %+v", history)
+	}
+
+	resp = callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "assets/2/history/2/restore"})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("restore: expected 200, got %d: %s", resp.Status, resp.Body)
+	}
+	record, err := app.getAsset(context.Background(), 1, 2)
+	if err != nil || record.StationName != "MT-202" {
+		t.Fatalf("expected asset 2 to be back, got %+v (%v)", record, err)
+	}
+
+	resp = callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/2/history", PluginContext: backend.PluginContext{OrgID: 2}})
+	if resp.Status != http.StatusNotFound {
+		t.Fatalf("expected history to be org scoped, got %d", resp.Status)
+	}
+}
+
+func jsonNumber(v float64) string {
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
@@ -0,0 +1,281 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AssetFieldError is one violation found when validating an asset payload.
+// Rule names the check that failed, e.g. "required" or "date_order".
+type AssetFieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// fieldValidationError reports all violations in one validationError, or
+// returns nil when there are none.
+func fieldValidationError(violations []AssetFieldError) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Message
+	}
+	return validationError{message: strings.Join(messages, "; "), fields: violations}
+}
+
+// AssetValidationRules are an org's cross-field checks on asset payloads,
+// read from the app's jsonData under "validationRules". Keys left out keep
+// their defaults; an empty list turns a default off.
+type AssetValidationRules struct {
+	// DateOrder requires each Before date to be no later than its After date.
+	DateOrder []AssetDateOrderRule `json:"dateOrder"`
+	// NotInFuture lists date fields that must not be later than today.
+	NotInFuture []string `json:"notInFuture"`
+	// Patterns match text fields against regular expressions.
+	Patterns []AssetPatternRule `json:"patterns"`
+	// MaxPitch and MaxRoll bound the absolute pitch and roll in degrees.
+	MaxPitch *float64 `json:"maxPitch,omitempty"`
+	MaxRoll  *float64 `json:"maxRoll,omitempty"`
+	// RequiredAttachments sets the files an asset of a service must hold.
+	// Attachments are uploaded after an asset is created, so the rule is
+	// checked when an existing asset is saved.
+	RequiredAttachments []AssetAttachmentRule `json:"requiredAttachments"`
+}
+
+type AssetDateOrderRule struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type AssetPatternRule struct {
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+	// Message replaces the default violation message when set.
+	Message string `json:"message,omitempty"`
+
+	re *regexp.Regexp
+}
+
+type AssetAttachmentRule struct {
+	Service  string `json:"service"`
+	MinFiles int    `json:"minFiles"`
+}
+
+// assetPatternFields are the text fields a pattern rule may check.
+var assetPatternFields = map[string]func(p AssetPayload) string{
+	"title":        func(p AssetPayload) string { return p.Title },
+	"station_name": func(p AssetPayload) string { return p.StationName },
+	"technician":   func(p AssetPayload) string { return p.Technician },
+	"service":      func(p AssetPayload) string { return p.Service },
+}
+
+// defaultAssetValidationRules apply to orgs that configure no rules.
+func defaultAssetValidationRules() AssetValidationRules {
+	return AssetValidationRules{
+		DateOrder:   []AssetDateOrderRule{{Before: "start_date", After: "end_date"}},
+		NotInFuture: []string{"commissioning_date"},
+	}
+}
+
+// parseAssetValidationRules reads configured rules over the defaults. Rules
+// that cannot be used are logged and dropped so a typo does not block saving
+// assets.
+func parseAssetValidationRules(raw json.RawMessage) AssetValidationRules {
+	rules := defaultAssetValidationRules()
+	if len(raw) == 0 || string(raw) == "null" {
+		return rules
+	}
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		log.Printf("invalid validationRules, using defaults: %v", err)
+		return defaultAssetValidationRules()
+	}
+
+	dateOrder := rules.DateOrder[:0]
+	for _, rule := range rules.DateOrder {
+		if !isAssetDateColumn(rule.Before) || !isAssetDateColumn(rule.After) || rule.Before == rule.After {
+			log.Printf("validationRules: ignoring dateOrder %s before %s", rule.Before, rule.After)
+			continue
+		}
+		dateOrder = append(dateOrder, rule)
+	}
+	rules.DateOrder = dateOrder
+
+	notInFuture := rules.NotInFuture[:0]
+	for _, field := range rules.NotInFuture {
+		if !isAssetDateColumn(field) {
+			log.Printf("validationRules: ignoring notInFuture %q", field)
+			continue
+		}
+		notInFuture = append(notInFuture, field)
+	}
+	rules.NotInFuture = notInFuture
+
+	patterns := rules.Patterns[:0]
+	for _, rule := range rules.Patterns {
+		if _, ok := assetPatternFields[rule.Field]; !ok {
+			log.Printf("validationRules: ignoring pattern for unsupported field %q", rule.Field)
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			log.Printf("validationRules: ignoring pattern for %s: %v", rule.Field, err)
+			continue
+		}
+		rule.re = re
+		patterns = append(patterns, rule)
+	}
+	rules.Patterns = patterns
+
+	attachments := rules.RequiredAttachments[:0]
+	for _, rule := range rules.RequiredAttachments {
+		rule.Service = strings.TrimSpace(rule.Service)
+		if rule.Service == "" || rule.MinFiles < 1 {
+			log.Printf("validationRules: ignoring requiredAttachments for service %q", rule.Service)
+			continue
+		}
+		attachments = append(attachments, rule)
+	}
+	rules.RequiredAttachments = attachments
+
+	for _, limit := range []**float64{&rules.MaxPitch, &rules.MaxRoll} {
+		if *limit != nil && (**limit < 0 || math.IsNaN(**limit)) {
+			log.Printf("validationRules: ignoring negative angle limit %v", **limit)
+			*limit = nil
+		}
+	}
+	return rules
+}
+
+func isAssetDateColumn(name string) bool {
+	for _, column := range assetDateColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentsNotChecked marks an asset that does not exist yet and so cannot
+// hold the files RequiredAttachments asks for.
+const attachmentsNotChecked = -1
+
+// assetValidator holds what validating a payload needs besides the org's
+// custom fields: its rules and time zone, and the asset's attachment count.
+type assetValidator struct {
+	rules       AssetValidationRules
+	loc         *time.Location
+	now         time.Time
+	attachments int
+}
+
+// validator returns the org's validator for an asset holding attachments
+// files, or attachmentsNotChecked for a new one.
+func (a *App) validator(attachments int) *assetValidator {
+	return &assetValidator{
+		rules:       a.config.ValidationRules,
+		loc:         a.location(),
+		now:         time.Now(),
+		attachments: attachments,
+	}
+}
+
+// assetValidatorFor counts the files of an existing asset for its validator.
+func (a *App) assetValidatorFor(ctx context.Context, q sqlQueryer, orgID, assetID int64) (*assetValidator, error) {
+	rows, err := q.QueryContext(ctx, `SELECT COUNT(*) FROM asset_files WHERE org_id = ? AND asset_id = ?`, orgID, assetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var count int
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return a.validator(count), nil
+}
+
+// check applies the rules to a payload whose dates are in stored form.
+func (v *assetValidator) check(p AssetPayload) []AssetFieldError {
+	var violations []AssetFieldError
+	dates := p.dateFields()
+
+	for _, rule := range v.rules.DateOrder {
+		before, _, okBefore := v.dateRange(*dates[rule.Before])
+		_, after, okAfter := v.dateRange(*dates[rule.After])
+		if okBefore && okAfter && before.After(after) {
+			violations = append(violations, AssetFieldError{
+				Field:   rule.After,
+				Rule:    "date_order",
+				Message: fmt.Sprintf("%s must not be before %s", rule.After, rule.Before),
+			})
+		}
+	}
+
+	for _, field := range v.rules.NotInFuture {
+		if start, _, ok := v.dateRange(*dates[field]); ok && start.After(v.now) {
+			violations = append(violations, AssetFieldError{
+				Field:   field,
+				Rule:    "not_in_future",
+				Message: fmt.Sprintf("%s must not be in the future", field),
+			})
+		}
+	}
+
+	for _, rule := range v.rules.Patterns {
+		value := assetPatternFields[rule.Field](p)
+		if value == "" || rule.re.MatchString(value) {
+			continue
+		}
+		message := rule.Message
+		if message == "" {
+			message = fmt.Sprintf("%s %q does not match %s", rule.Field, value, rule.Pattern)
+		}
+		violations = append(violations, AssetFieldError{Field: rule.Field, Rule: "pattern", Message: message})
+	}
+
+	if limit := v.rules.MaxPitch; limit != nil && math.Abs(p.Pitch) > *limit {
+		violations = append(violations, AssetFieldError{Field: "pitch", Rule: "max_pitch", Message: fmt.Sprintf("pitch must be between -%g and %g", *limit, *limit)})
+	}
+	if limit := v.rules.MaxRoll; limit != nil && math.Abs(p.Roll) > *limit {
+		violations = append(violations, AssetFieldError{Field: "roll", Rule: "max_roll", Message: fmt.Sprintf("roll must be between -%g and %g", *limit, *limit)})
+	}
+
+	if v.attachments != attachmentsNotChecked {
+		for _, rule := range v.rules.RequiredAttachments {
+			if strings.EqualFold(p.Service, rule.Service) && v.attachments < rule.MinFiles {
+				violations = append(violations, AssetFieldError{
+					Field:   "attachments",
+					Rule:    "required_attachments",
+					Message: fmt.Sprintf("%s assets need at least %d attachments, have %d", p.Service, rule.MinFiles, v.attachments),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// dateRange returns the first and last instant of a stored date. A full-date
+// spans its whole day in the org's time zone.
+func (v *assetValidator) dateRange(value string) (time.Time, time.Time, bool) {
+	start, ok := storedAssetTime(value, v.loc)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	if len(value) == len(assetDateOnlyLayout) {
+		return start, start.AddDate(0, 0, 1).Add(-time.Nanosecond), true
+	}
+	return start, start, true
+}
@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestParseAssetValidationRules(t *testing.T) {
+	rules := parseAssetValidationRules(nil)
+	if len(rules.DateOrder) != 1 || len(rules.NotInFuture) != 1 {
+		t.Fatalf("expected default rules, got %+v", rules)
+	}
+
+	rules = parseAssetValidationRules(json.RawMessage(`{
+		"notInFuture": [],
+		"patterns": [{"field":"station_name","pattern":"^[A-Z]+-\\d+$"},{"field":"station_name","pattern":"("},{"field":"latitude","pattern":"."}],
+		"dateOrder": [{"before":"entry_date","after":"nope"}],
+		"maxPitch": 5,
+		"requiredAttachments": [{"service":"Inspection","minFiles":1},{"service":"Audit","minFiles":0}]
+	}`))
+	if len(rules.NotInFuture) != 0 || len(rules.DateOrder) != 0 {
+		t.Fatalf("expected configured lists to replace the defaults, got %+v", rules)
+	}
+	if len(rules.Patterns) != 1 || rules.Patterns[0].re == nil || len(rules.RequiredAttachments) != 1 || rules.MaxPitch == nil || *rules.MaxPitch != 5 {
+		t.Fatalf("expected unusable rules to be dropped, got %+v", rules)
+	}
+
+	if rules := parseAssetValidationRules(json.RawMessage(`{"patterns":"x"}`)); len(rules.DateOrder) != 1 {
+		t.Fatalf("expected malformed rules to fall back to the defaults, got %+v", rules)
+	}
+}
+
+func TestAssetValidationRules(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	app.config.ValidationRules = parseAssetValidationRules(json.RawMessage(`{
+		"patterns": [{"field":"station_name","pattern":"^[A-Z]+\\d+-\\d+$","message":"station codes look like WLS7-1273"}],
+		"maxPitch": 10,
+		"maxRoll": 10,
+		"requiredAttachments": [{"service":"Inspection","minFiles":1}]
+	}`))
+
+	body := `{"title":"","entry_date":"2025-02-24","commissioning_date":"2999-01-01","station_name":"bad","technician":"M. Paxl","start_date":"2025-03-01","end_date":"2025-02-01","pitch":12,"roll":-3}`
+	resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: "assets/1", Body: []byte(body)})
+	if resp.Status != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", resp.Status, resp.Body)
+	}
+	var payload struct {
+		Message string            `json:"message"`
+		Errors  []AssetFieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(resp.Body, &payload); err != nil {
+		t.Fatalf("decode errors: %v (%s)", err, resp.Body)
+	}
+	got := map[string]string{}
+	for _, violation := range payload.Errors {
+		got[violation.Field] = violation.Rule
+	}
+	expected := map[string]string{
+		"title":              "required",
+		"end_date":           "date_order",
+		"commissioning_date": "not_in_future",
+		"station_name":       "pattern",
+		"pitch":              "max_pitch",
+	}
+	if len(got) != len(expected) || payload.Message == "" {
+		t.Fatalf("expected all violations at once, got %+v", payload)
+	}
+	for field, rule := range expected {
+		if got[field] != rule {
+			t.Fatalf("expected %s to fail %s, got %+v", field, rule, payload.Errors)
+		}
+	}
+
+	// A same-day date-time end is not before a full-date start.
+	asset := AssetPayload{
+		Title:             "Inspected",
+		EntryDate:         "2025-02-24",
+		CommissioningDate: "2025-02-24",
+		StationName:       "WLS7-1274",
+		Technician:        "M. Paxl",
+		StartDate:         "2025-03-01",
+		EndDate:           "2025-03-01T10:00",
+		Service:           "inspection",
+	}
+	created, err := app.createAsset(ctx, 1, asset)
+	if err != nil {
+		t.Fatalf("expected attachments not to be required on create: %v", err)
+	}
+	asset.Title = "Inspected again"
+	if _, err := app.updateAsset(ctx, 1, created.ID, asset, 0); err == nil {
+		t.Fatal("expected an update without the required attachment to fail")
+	}
+	if _, err := app.insertAssetFile(ctx, 1, created.ID, "report.pdf", "application/pdf", "org-1/report.pdf"); err != nil {
+		t.Fatalf("insert file: %v", err)
+	}
+	if _, err := app.updateAsset(ctx, 1, created.ID, asset, 0); err != nil {
+		t.Fatalf("expected the update to pass with an attachment: %v", err)
+	}
+}
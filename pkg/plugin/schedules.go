@@ -0,0 +1,625 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errScheduleNotFound = errors.New("schedule not found")
+
+const (
+	scheduleUnitDays   = "days"
+	scheduleUnitWeeks  = "weeks"
+	scheduleUnitMonths = "months"
+	scheduleUnitYears  = "years"
+	// scheduleUnitHours counts operating hours read from a meter field.
+	scheduleUnitHours = "hours"
+
+	defaultScheduleWithinDays = 30
+	maxScheduleWithinDays     = 3660
+)
+
+// Schedule is a recurring maintenance task on a station, or on an asset and
+// its sub-components. The due fields are computed from the most recent live
+// asset entry whose service matches TaskType.
+type Schedule struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	StationID    int64  `json:"station_id,omitempty"`
+	AssetID      int64  `json:"asset_id,omitempty"`
+	Target       string `json:"target"`
+	TaskType     string `json:"task_type"`
+	Interval     int    `json:"interval"`
+	IntervalUnit string `json:"interval_unit"`
+	MeterField   string `json:"meter_field,omitempty"`
+	AnchorDate   string `json:"anchor_date"`
+	// LastDone is the end_date of the latest matching entry, LastAssetID its id.
+	LastDone    string `json:"last_done,omitempty"`
+	LastAssetID int64  `json:"last_asset_id,omitempty"`
+	// NextDue is a full-date in the org's time zone. It is empty for hours
+	// schedules whose usage cannot be projected yet.
+	NextDue      string   `json:"next_due,omitempty"`
+	DaysUntilDue *int     `json:"days_until_due,omitempty"`
+	Overdue      bool     `json:"overdue"`
+	MeterHours   *float64 `json:"meter_hours,omitempty"`
+	NextDueHours *float64 `json:"next_due_hours,omitempty"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+}
+
+// SchedulePayload is the writable part of a Schedule. Exactly one of
+// StationID and AssetID is set.
+type SchedulePayload struct {
+	Name         string `json:"name"`
+	StationID    int64  `json:"station_id"`
+	AssetID      int64  `json:"asset_id"`
+	TaskType     string `json:"task_type"`
+	Interval     int    `json:"interval"`
+	IntervalUnit string `json:"interval_unit"`
+	MeterField   string `json:"meter_field"`
+	AnchorDate   string `json:"anchor_date"`
+}
+
+func (p *SchedulePayload) normalize(loc *time.Location) {
+	p.Name = strings.TrimSpace(p.Name)
+	p.TaskType = strings.TrimSpace(p.TaskType)
+	p.MeterField = strings.TrimSpace(p.MeterField)
+	p.IntervalUnit = strings.ToLower(strings.TrimSpace(p.IntervalUnit))
+	if p.IntervalUnit != "" && !strings.HasSuffix(p.IntervalUnit, "s") {
+		p.IntervalUnit += "s"
+	}
+	p.AnchorDate, _ = normalizeAssetDate(strings.TrimSpace(p.AnchorDate), loc)
+}
+
+func (p SchedulePayload) validate(fields assetFieldDefinitions) error {
+	switch {
+	case (p.StationID == 0) == (p.AssetID == 0):
+		return validationError{message: "set exactly one of station_id and asset_id"}
+	case p.TaskType == "":
+		return validationError{message: "task_type is required"}
+	case p.Interval <= 0:
+		return validationError{message: "interval must be a positive number"}
+	case !isStoredAssetDate(p.AnchorDate):
+		return validationError{message: "anchor_date must be an RFC 3339 date"}
+	}
+	switch p.IntervalUnit {
+	case scheduleUnitDays, scheduleUnitWeeks, scheduleUnitMonths, scheduleUnitYears:
+		if p.MeterField != "" {
+			return validationError{message: "meter_field is only used by schedules counted in hours"}
+		}
+	case scheduleUnitHours:
+		def, ok := fields.lookup(p.MeterField)
+		if !ok || def.Type != fieldTypeNumber {
+			return validationError{message: "hours schedules need meter_field to name a number custom field"}
+		}
+	default:
+		return validationError{message: "interval_unit must be one of days, weeks, months, years, hours"}
+	}
+	return nil
+}
+
+// addScheduleInterval advances a calendar date by count units.
+func addScheduleInterval(t time.Time, count int, unit string) time.Time {
+	switch unit {
+	case scheduleUnitWeeks:
+		return t.AddDate(0, 0, 7*count)
+	case scheduleUnitMonths:
+		return t.AddDate(0, count, 0)
+	case scheduleUnitYears:
+		return t.AddDate(count, 0, 0)
+	default:
+		return t.AddDate(0, 0, count)
+	}
+}
+
+// calendarDay is the start of t's day in loc.
+func calendarDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// daysBetween counts the calendar days from one day start to another.
+func daysBetween(from, to time.Time) int {
+	fy, fm, fd := from.Date()
+	ty, tm, td := to.Date()
+	return int(time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
+
+const scheduleSelectColumns = `id, name, station_id, asset_id, task_type, interval_count, interval_unit, meter_field, anchor_date, created_at, updated_at,
+  COALESCE((SELECT code FROM stations WHERE stations.id = schedules.station_id), (SELECT title FROM assets WHERE assets.id = schedules.asset_id), '')`
+
+func scanSchedule(scan func(dest ...interface{}) error) (Schedule, error) {
+	var schedule Schedule
+	var stationID, assetID sql.NullInt64
+	if err := scan(&schedule.ID, &schedule.Name, &stationID, &assetID, &schedule.TaskType, &schedule.Interval, &schedule.IntervalUnit, &schedule.MeterField, &schedule.AnchorDate, &schedule.CreatedAt, &schedule.UpdatedAt, &schedule.Target); err != nil {
+		return Schedule{}, err
+	}
+	schedule.StationID, schedule.AssetID = stationID.Int64, assetID.Int64
+	return schedule, nil
+}
+
+// scheduleTarget is the condition selecting the asset entries a schedule
+// covers: those of its station, or its asset and the asset's descendants.
+func scheduleTarget(s Schedule) (string, []interface{}) {
+	if s.StationID != 0 {
+		return `station_id = ?`, []interface{}{s.StationID}
+	}
+	return `id IN (WITH RECURSIVE subtree(id) AS (
+    SELECT ?
+    UNION
+    SELECT child.id FROM assets AS child JOIN subtree ON child.parent_id = subtree.id
+) SELECT id FROM subtree)`, []interface{}{s.AssetID}
+}
+
+// computeScheduleDue fills in the due fields of s as of now. Calendar
+// schedules are due one interval after the later of the anchor date and the
+// last matching entry. Hours schedules are due when the meter reaches the
+// reading of the last matching entry, or zero, plus the interval; the date is
+// projected from the usage between the two latest readings.
+func computeScheduleDue(ctx context.Context, q sqlQueryer, orgID int64, s *Schedule, fields assetFieldDefinitions, loc *time.Location, now time.Time) error {
+	target, targetArgs := scheduleTarget(*s)
+	args := append([]interface{}{orgID}, targetArgs...)
+	rows, err := q.QueryContext(ctx, `SELECT id, end_date FROM assets
+WHERE org_id = ? AND deleted_at IS NULL AND `+target+` AND service = ? COLLATE NOCASE AND julianday(end_date) IS NOT NULL
+ORDER BY julianday(end_date) DESC, id DESC LIMIT 1`, append(args, s.TaskType)...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		if err := rows.Scan(&s.LastAssetID, &s.LastDone); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var due time.Time
+	if s.IntervalUnit == scheduleUnitHours {
+		def, ok := fields.lookup(s.MeterField)
+		if !ok {
+			return nil
+		}
+		due, err = projectMeterDue(ctx, q, s, def.expression(), args, loc)
+		if err != nil || due.IsZero() {
+			return err
+		}
+	} else {
+		base, ok := storedAssetTime(s.AnchorDate, loc)
+		if !ok {
+			return nil
+		}
+		if last, ok := storedAssetTime(s.LastDone, loc); ok && last.After(base) {
+			base = last
+		}
+		due = addScheduleInterval(calendarDay(base, loc), s.Interval, s.IntervalUnit)
+	}
+
+	due = calendarDay(due, loc)
+	days := daysBetween(calendarDay(now, loc), due)
+	s.NextDue = due.Format(assetDateOnlyLayout)
+	s.DaysUntilDue = &days
+	s.Overdue = s.Overdue || days < 0
+	return nil
+}
+
+// projectMeterDue walks the meter readings of an hours schedule in time order
+// and returns when the next service falls due, or the zero time when that
+// cannot be projected yet.
+func projectMeterDue(ctx context.Context, q sqlQueryer, s *Schedule, meter string, args []interface{}, loc *time.Location) (time.Time, error) {
+	target, _ := scheduleTarget(*s)
+	rows, err := q.QueryContext(ctx, `SELECT end_date, COALESCE(service, ''), `+meter+` FROM assets
+WHERE org_id = ? AND deleted_at IS NULL AND `+target+` AND typeof(`+meter+`) IN ('integer', 'real') AND julianday(end_date) IS NOT NULL
+ORDER BY julianday(end_date), id`, args...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer rows.Close()
+
+	var base, latest, previous float64
+	var latestAt, previousAt, reachedAt time.Time
+	for rows.Next() {
+		var endDate, service string
+		var reading float64
+		if err := rows.Scan(&endDate, &service, &reading); err != nil {
+			return time.Time{}, err
+		}
+		at, ok := storedAssetTime(endDate, loc)
+		if !ok {
+			continue
+		}
+		if !latestAt.IsZero() && at.After(latestAt) {
+			previous, previousAt = latest, latestAt
+		}
+		latest, latestAt = reading, at
+		if strings.EqualFold(service, s.TaskType) {
+			base, reachedAt = reading, time.Time{}
+		}
+		if reachedAt.IsZero() && reading >= base+float64(s.Interval) {
+			reachedAt = at
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return time.Time{}, err
+	}
+	if latestAt.IsZero() {
+		return time.Time{}, nil
+	}
+
+	next := base + float64(s.Interval)
+	s.MeterHours, s.NextDueHours = &latest, &next
+	if !reachedAt.IsZero() {
+		s.Overdue = true
+		return reachedAt, nil
+	}
+	if previousAt.IsZero() || latest <= previous {
+		return time.Time{}, nil
+	}
+	perDay := (latest - previous) / latestAt.Sub(previousAt).Hours() * 24
+	return latestAt.Add(time.Duration((next - latest) / perDay * 24 * float64(time.Hour))), nil
+}
+
+// listSchedules returns the org's schedules with their due fields as of now.
+// Schedules of trashed assets are left out until the asset is restored.
+func (a *App) listSchedules(ctx context.Context, orgID int64, now time.Time) ([]Schedule, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT `+scheduleSelectColumns+` FROM schedules
+WHERE org_id = ? AND (asset_id IS NULL OR asset_id IN (SELECT id FROM assets WHERE deleted_at IS NULL))
+ORDER BY id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	schedules := []Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows.Scan)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range schedules {
+		if err := a.finishSchedule(ctx, orgID, &schedules[i], fields, now); err != nil {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+// listDueSchedules returns the schedules falling due in the next withinDays
+// days, or the overdue ones, soonest first.
+func (a *App) listDueSchedules(ctx context.Context, orgID int64, now time.Time, withinDays int, overdue bool) ([]Schedule, error) {
+	schedules, err := a.listSchedules(ctx, orgID, now)
+	if err != nil {
+		return nil, err
+	}
+	due := []Schedule{}
+	for _, schedule := range schedules {
+		if schedule.DaysUntilDue == nil || schedule.Overdue != overdue {
+			continue
+		}
+		if overdue || *schedule.DaysUntilDue <= withinDays {
+			due = append(due, schedule)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return *due[i].DaysUntilDue < *due[j].DaysUntilDue
+	})
+	return due, nil
+}
+
+func (a *App) getSchedule(ctx context.Context, orgID, scheduleID int64, now time.Time) (Schedule, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT `+scheduleSelectColumns+` FROM schedules WHERE org_id = ? AND id = ?`, orgID, scheduleID)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if !rows.Next() {
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return Schedule{}, err
+		}
+		return Schedule{}, errScheduleNotFound
+	}
+	schedule, err := scanSchedule(rows.Scan)
+	rows.Close()
+	if err != nil {
+		return Schedule{}, err
+	}
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
+	if err != nil {
+		return Schedule{}, err
+	}
+	return schedule, a.finishSchedule(ctx, orgID, &schedule, fields, now)
+}
+
+// finishSchedule computes the due fields and renders the stored dates.
+func (a *App) finishSchedule(ctx context.Context, orgID int64, s *Schedule, fields assetFieldDefinitions, now time.Time) error {
+	loc := a.location()
+	if err := computeScheduleDue(ctx, a.db, orgID, s, fields, loc, now); err != nil {
+		return err
+	}
+	s.AnchorDate = renderAssetDate(s.AnchorDate, loc)
+	s.LastDone = renderAssetDate(s.LastDone, loc)
+	return nil
+}
+
+// checkScheduleTarget fails unless the payload's station or live asset
+// belongs to the org.
+func checkScheduleTarget(ctx context.Context, q sqlQueryer, orgID int64, payload SchedulePayload) error {
+	if payload.StationID != 0 {
+		if _, err := getStation(ctx, q, orgID, payload.StationID); errors.Is(err, errStationNotFound) {
+			return validationError{message: fmt.Sprintf("station_id %d does not exist", payload.StationID)}
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+	found, err := queryDirectoryID(ctx, q, `SELECT id FROM assets WHERE org_id = ? AND id = ? AND deleted_at IS NULL`, orgID, payload.AssetID)
+	if err != nil {
+		return err
+	}
+	if found == 0 {
+		return validationError{message: fmt.Sprintf("asset_id %d does not exist", payload.AssetID)}
+	}
+	return nil
+}
+
+// nullableID stores 0 as NULL.
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func (a *App) createSchedule(ctx context.Context, orgID int64, payload SchedulePayload, now time.Time) (Schedule, error) {
+	payload.normalize(a.location())
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if err := payload.validate(fields); err != nil {
+		return Schedule{}, err
+	}
+	if err := checkScheduleTarget(ctx, a.db, orgID, payload); err != nil {
+		return Schedule{}, err
+	}
+	stamp := time.Now().UTC().Format(time.RFC3339Nano)
+	res, err := a.db.ExecContext(ctx, `INSERT INTO schedules (org_id, name, station_id, asset_id, task_type, interval_count, interval_unit, meter_field, anchor_date, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		orgID,
+		payload.Name,
+		nullableID(payload.StationID),
+		nullableID(payload.AssetID),
+		payload.TaskType,
+		payload.Interval,
+		payload.IntervalUnit,
+		payload.MeterField,
+		payload.AnchorDate,
+		stamp,
+		stamp,
+	)
+	if err != nil {
+		return Schedule{}, err
+	}
+	scheduleID, err := res.LastInsertId()
+	if err != nil {
+		return Schedule{}, err
+	}
+	return a.getSchedule(ctx, orgID, scheduleID, now)
+}
+
+func (a *App) updateSchedule(ctx context.Context, orgID, scheduleID int64, payload SchedulePayload, now time.Time) (Schedule, error) {
+	payload.normalize(a.location())
+	fields, err := listAssetFieldDefinitions(ctx, a.db, orgID)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if err := payload.validate(fields); err != nil {
+		return Schedule{}, err
+	}
+	if err := checkScheduleTarget(ctx, a.db, orgID, payload); err != nil {
+		return Schedule{}, err
+	}
+	res, err := a.db.ExecContext(ctx, `UPDATE schedules SET name = ?, station_id = ?, asset_id = ?, task_type = ?, interval_count = ?, interval_unit = ?, meter_field = ?, anchor_date = ?, updated_at = ? WHERE org_id = ? AND id = ?`,
+		payload.Name,
+		nullableID(payload.StationID),
+		nullableID(payload.AssetID),
+		payload.TaskType,
+		payload.Interval,
+		payload.IntervalUnit,
+		payload.MeterField,
+		payload.AnchorDate,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		orgID,
+		scheduleID,
+	)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return Schedule{}, err
+	} else if affected == 0 {
+		return Schedule{}, errScheduleNotFound
+	}
+	return a.getSchedule(ctx, orgID, scheduleID, now)
+}
+
+func (a *App) deleteSchedule(ctx context.Context, orgID, scheduleID int64) error {
+	res, err := a.db.ExecContext(ctx, `DELETE FROM schedules WHERE org_id = ? AND id = ?`, orgID, scheduleID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errScheduleNotFound
+	}
+	return nil
+}
+
+// parseScheduleWithin reads a due horizon such as 30d or 6w; a bare number
+// counts days.
+func parseScheduleWithin(raw string) (int, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return defaultScheduleWithinDays, nil
+	}
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(raw, "d"):
+		raw = strings.TrimSuffix(raw, "d")
+	case strings.HasSuffix(raw, "w"):
+		raw, multiplier = strings.TrimSuffix(raw, "w"), 7
+	}
+	count, err := strconv.Atoi(raw)
+	if err != nil || count < 0 || count*multiplier > maxScheduleWithinDays {
+		return 0, validationError{message: fmt.Sprintf("within must be a number of days or weeks such as 30d or 6w, up to %d days", maxScheduleWithinDays)}
+	}
+	return count * multiplier, nil
+}
+
+func decodeSchedulePayload(r *http.Request) (SchedulePayload, error) {
+	defer func() {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	var payload SchedulePayload
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxAssetPayloadSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&payload); err != nil {
+		return SchedulePayload{}, validationError{message: "invalid JSON payload: " + err.Error()}
+	}
+	return payload, nil
+}
+
+// handleSchedules serves /schedules.
+func (a *App) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		schedules, err := a.listSchedules(r.Context(), orgID, time.Now())
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": schedules})
+	case http.MethodPost:
+		payload, err := decodeSchedulePayload(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		schedule, err := a.createSchedule(r.Context(), orgID, payload, time.Now())
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"data": schedule})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSchedulesDue serves /schedules/due?within=30d and /schedules/overdue.
+func (a *App) handleSchedulesDue(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	overdue := r.URL.Path == "/schedules/overdue"
+	within := 0
+	if !overdue {
+		within, err = parseScheduleWithin(r.URL.Query().Get("within"))
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+	}
+	schedules, err := a.listDueSchedules(r.Context(), orgID, time.Now(), within, overdue)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	meta := map[string]interface{}{"overdue": overdue}
+	if !overdue {
+		meta["withinDays"] = within
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": schedules, "meta": meta})
+}
+
+// handleSchedule serves /schedules/{id}.
+func (a *App) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	scheduleID, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/schedules/"), "/"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		schedule, err := a.getSchedule(r.Context(), orgID, scheduleID, time.Now())
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": schedule})
+	case http.MethodPut:
+		payload, err := decodeSchedulePayload(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		schedule, err := a.updateSchedule(r.Context(), orgID, scheduleID, payload, time.Now())
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": schedule})
+	case http.MethodDelete:
+		if err := a.deleteSchedule(r.Context(), orgID, scheduleID); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
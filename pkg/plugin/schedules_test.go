@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// serviceEntry makes a service entry that ended on endDate.
+func serviceEntry(station, service, endDate string, fields map[string]interface{}) func(*AssetPayload) {
+	return func(p *AssetPayload) {
+		p.Title = service + " " + endDate
+		p.EntryDate = endDate
+		p.CommissioningDate = "2024-01-01"
+		p.StationName = station
+		p.StartDate = endDate
+		p.EndDate = endDate
+		p.Service = service
+		p.CustomFields = fields
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	latest := createTestAsset(t, app, serviceEntry("CAL-1", "Calibration", "2025-01-10", nil))
+	createTestAsset(t, app, serviceEntry("CAL-1", "Calibration", "2024-12-01", nil))
+	createTestAsset(t, app, serviceEntry("CAL-1", "Inspection", "2025-03-01", nil))
+	calibration, err := app.createSchedule(ctx, 1, SchedulePayload{
+		StationID: latest.StationID, TaskType: "calibration", Interval: 6, IntervalUnit: "month", AnchorDate: "2024-01-01",
+	}, now)
+	if err != nil {
+		t.Fatalf("create calibration schedule: %v", err)
+	}
+	if calibration.LastAssetID != latest.ID || calibration.NextDue != "2025-07-10" || *calibration.DaysUntilDue != 39 || calibration.Overdue {
+		t.Fatalf("unexpected calibration schedule: %+v", calibration)
+	}
+
+	// Work logged on a sub-component counts for the tower's schedule.
+	tower := createTestAsset(t, app, serviceEntry("TWR-1", "Inspection", "2024-05-01", nil))
+	child := createTestAsset(t, app, serviceEntry("TWR-1", "inspection", "2024-11-15", nil))
+	if err := setAssetParent(ctx, app.db, 1, child.ID, tower.ID); err != nil {
+		t.Fatalf("nest entry: %v", err)
+	}
+	inspection, err := app.createSchedule(ctx, 1, SchedulePayload{
+		AssetID: tower.ID, TaskType: "Inspection", Interval: 6, IntervalUnit: "months", AnchorDate: "2024-01-01",
+	}, now)
+	if err != nil {
+		t.Fatalf("create inspection schedule: %v", err)
+	}
+	if inspection.LastAssetID != child.ID || inspection.NextDue != "2025-05-15" || !inspection.Overdue {
+		t.Fatalf("unexpected inspection schedule: %+v", inspection)
+	}
+
+	// Operating hours are projected from the usage between the last readings.
+	if resp := createTestField(t, app, `{"name":"hours","type":"number"}`); resp.Status != http.StatusCreated {
+		t.Fatalf("create field: %d %s", resp.Status, resp.Body)
+	}
+	oil := createTestAsset(t, app, serviceEntry("GEN-1", "Oil change", "2025-01-01", map[string]interface{}{"hours": 100.0}))
+	createTestAsset(t, app, serviceEntry("GEN-1", "Inspection", "2025-03-01", map[string]interface{}{"hours": 500.0}))
+	createTestAsset(t, app, serviceEntry("GEN-1", "Inspection", "2025-05-01", map[string]interface{}{"hours": 800.0}))
+	hours, err := app.createSchedule(ctx, 1, SchedulePayload{
+		StationID: oil.StationID, TaskType: "Oil change", Interval: 1000, IntervalUnit: "hours", MeterField: "hours", AnchorDate: "2025-01-01",
+	}, now)
+	if err != nil {
+		t.Fatalf("create hours schedule: %v", err)
+	}
+	if hours.NextDueHours == nil || *hours.NextDueHours != 1100 || *hours.MeterHours != 800 || hours.NextDue != "2025-07-01" {
+		t.Fatalf("unexpected hours schedule: %+v", hours)
+	}
+
+	due, err := app.listDueSchedules(ctx, 1, now, 30, false)
+	if err != nil || len(due) != 1 || due[0].ID != hours.ID {
+		t.Fatalf("expected only the hours schedule within 30 days, got %+v (%v)", due, err)
+	}
+	due, err = app.listDueSchedules(ctx, 1, now, 45, false)
+	if err != nil || len(due) != 2 || due[0].ID != hours.ID || due[1].ID != calibration.ID {
+		t.Fatalf("expected both schedules within 45 days, soonest first, got %+v (%v)", due, err)
+	}
+	overdue, err := app.listDueSchedules(ctx, 1, now, 0, true)
+	if err != nil || len(overdue) != 1 || overdue[0].ID != inspection.ID {
+		t.Fatalf("expected the inspection to be overdue, got %+v (%v)", overdue, err)
+	}
+
+	for _, payload := range []SchedulePayload{
+		{StationID: latest.StationID, AssetID: tower.ID, TaskType: "x", Interval: 1, IntervalUnit: "days", AnchorDate: "2025-01-01"},
+		{StationID: latest.StationID, TaskType: "x", Interval: 1, IntervalUnit: "hours", AnchorDate: "2025-01-01"},
+		{StationID: latest.StationID, TaskType: "x", Interval: 1, IntervalUnit: "fortnights", AnchorDate: "2025-01-01"},
+		{StationID: latest.StationID, TaskType: "x", Interval: 0, IntervalUnit: "days", AnchorDate: "2025-01-01"},
+		{StationID: 999, TaskType: "x", Interval: 1, IntervalUnit: "days", AnchorDate: "2025-01-01"},
+	} {
+		if _, err := app.createSchedule(ctx, 1, payload, now); err == nil {
+			t.Fatalf("expected %+v to be rejected", payload)
+		}
+	}
+
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "schedules/due", URL: "schedules/due?within=soon"}); resp.Status != http.StatusBadRequest {
+		t.Fatalf("expected an invalid horizon to 400, got %d", resp.Status)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "schedules/999"}); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected a missing schedule to 404, got %d", resp.Status)
+	}
+}
+
+func TestParseScheduleWithin(t *testing.T) {
+	for raw, expected := range map[string]int{"": 30, "30d": 30, "6w": 42, "10": 10} {
+		if days, err := parseScheduleWithin(raw); err != nil || days != expected {
+			t.Fatalf("%q: expected %d days, got %d (%v)", raw, expected, days, err)
+		}
+	}
+	for _, raw := range []string{"soon", "-1d", "1y", "9999d"} {
+		if _, err := parseScheduleWithin(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	snippetMatchStart = "\x02"
+	snippetMatchEnd   = "\x03"
+)
+
+// assetSearchSubquery resolves a MATCH expression to asset ids with their bm25
+// rank and a snippet taken from the best matching column. Matches are wrapped
+// in control characters rather than markup; parseSearchSnippet turns them into
+// offsets so clients never have to render stored text as HTML.
+const assetSearchSubquery = `SELECT rowid AS asset_id, bm25(assets_fts) AS search_rank, snippet(assets_fts, -1, char(2), char(3), '…', 16) AS search_snippet FROM assets_fts WHERE assets_fts MATCH ?`
+
+// buildSearchMatchExpression turns free text into an FTS5 MATCH expression.
+// Every term is quoted so user input cannot inject FTS5 syntax, and matched as
+// a prefix so partial words still find results.
+func buildSearchMatchExpression(raw string) string {
+	terms := strings.Fields(raw)
+	if len(terms) == 0 {
+		return ""
+	}
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// parseSearchSnippet strips the match markers from a raw snippet and returns
+// the plain text with the [start, end) offsets of each match. Offsets count
+// UTF-16 code units so they can be used with JavaScript string slicing.
+func parseSearchSnippet(raw string) (string, [][2]int) {
+	var text strings.Builder
+	highlights := make([][2]int, 0)
+	offset, start := 0, -1
+	for _, r := range raw {
+		switch string(r) {
+		case snippetMatchStart:
+			start = offset
+		case snippetMatchEnd:
+			if start >= 0 && offset > start {
+				highlights = append(highlights, [2]int{start, offset})
+			}
+			start = -1
+		default:
+			text.WriteRune(r)
+			if n := utf16.RuneLen(r); n > 0 {
+				offset += n
+			} else {
+				offset++
+			}
+		}
+	}
+	return text.String(), highlights
+}
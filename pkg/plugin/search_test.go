@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestListAssetsFullTextSearch(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	result, err := app.listAssets(ctx, 1, AssetListOptions{Search: "calibr"})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if result.TotalCount != 1 || len(result.Records) != 1 {
+		t.Fatalf("expected one match, got %d", result.TotalCount)
+	}
+	record := result.Records[0]
+	if record.StationName != "MT-202" {
+		t.Fatalf("unexpected match %q", record.StationName)
+	}
+	if record.Search == nil || len(record.Search.Highlights) == 0 {
+		t.Fatalf("expected highlighted snippet, got %+v", record.Search)
+	}
+	units := utf16.Encode([]rune(record.Search.Snippet))
+	if span := record.Search.Highlights[0]; string(utf16.Decode(units[span[0]:span[1]])) != "calibration" {
+		t.Fatalf("expected highlight to cover the match, got %+v", record.Search)
+	}
+	if strings.ContainsAny(record.Search.Snippet, snippetMatchStart+snippetMatchEnd) {
+		t.Fatalf("expected markers to be stripped from %q", record.Search.Snippet)
+	}
+	if result.AppliedSearch != "calibr" {
+		t.Fatalf("expected applied search to be reflected, got %q", result.AppliedSearch)
+	}
+
+	result, err = app.listAssets(ctx, 2, AssetListOptions{Search: "calibration"})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if result.TotalCount != 1 || result.Records[0].StationName != "SRS-11" {
+		t.Fatalf("expected search to be scoped to org 2, got %+v", result.Records)
+	}
+
+	result, err = app.listAssets(ctx, 1, AssetListOptions{Search: `energiequelle "`, PageSize: 1})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if result.TotalCount != 2 || len(result.Records) != 1 || result.PageCount != 2 {
+		t.Fatalf("expected staff matches to paginate, got total=%d page_count=%d", result.TotalCount, result.PageCount)
+	}
+}
+
+func TestListAssetsSearchIncludesAttachmentNames(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	if _, err := app.insertAssetFile(ctx, 2, 4, "turbine-nacelle.jpg", "image/jpeg", "org-2/asset-4/nacelle"); err != nil {
+		t.Fatalf("insertAssetFile returned error: %v", err)
+	}
+
+	result, err := app.listAssets(ctx, 2, AssetListOptions{Search: "nacelle"})
+	if err != nil {
+		t.Fatalf("listAssets returned error: %v", err)
+	}
+	if result.TotalCount != 1 || result.Records[0].ID != 4 {
+		t.Fatalf("expected attachment name match on asset 4, got %+v", result.Records)
+	}
+}
+
+func TestParseSearchSnippet(t *testing.T) {
+	text, highlights := parseSearchSnippet("…Tür <b> \x02Kalibrierung\x03 und \x02Prüfung\x03")
+	if text != "…Tür <b> Kalibrierung und Prüfung" {
+		t.Fatalf("unexpected text %q", text)
+	}
+	exp := [][2]int{{9, 21}, {26, 33}}
+	if len(highlights) != len(exp) || highlights[0] != exp[0] || highlights[1] != exp[1] {
+		t.Fatalf("expected highlights %v, got %v", exp, highlights)
+	}
+}
@@ -0,0 +1,392 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errStationNotFound = errors.New("station not found")
+
+// Station is an entry of the org's station registry. Assets reference it by
+// id and carry its code in station_name.
+type Station struct {
+	ID         int64    `json:"id"`
+	Code       string   `json:"code"`
+	Name       string   `json:"name"`
+	Latitude   *float64 `json:"latitude"`
+	Longitude  *float64 `json:"longitude"`
+	Type       string   `json:"type"`
+	Owner      string   `json:"owner"`
+	Active     bool     `json:"active"`
+	AssetCount int      `json:"asset_count"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// StationPayload is the writable part of a Station. Active defaults to true.
+type StationPayload struct {
+	Code      string   `json:"code"`
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Type      string   `json:"type"`
+	Owner     string   `json:"owner"`
+	Active    *bool    `json:"active"`
+}
+
+func (p *StationPayload) normalize() {
+	p.Code = strings.TrimSpace(p.Code)
+	p.Name = strings.TrimSpace(p.Name)
+	p.Type = strings.TrimSpace(p.Type)
+	p.Owner = strings.TrimSpace(p.Owner)
+	if p.Name == "" {
+		p.Name = p.Code
+	}
+	if p.Active == nil {
+		active := true
+		p.Active = &active
+	}
+}
+
+func (p StationPayload) validate() error {
+	switch {
+	case stationCodeKey(p.Code) == "":
+		return validationError{message: "code is required"}
+	case (p.Latitude == nil) != (p.Longitude == nil):
+		return validationError{message: "latitude and longitude must be set together"}
+	case p.Latitude != nil && math.Abs(*p.Latitude) > 90:
+		return validationError{message: "latitude must be between -90 and 90"}
+	case p.Longitude != nil && math.Abs(*p.Longitude) > 180:
+		return validationError{message: "longitude must be between -180 and 180"}
+	}
+	return nil
+}
+
+// stationCodeKey is the form under which station codes are unique: upper case
+// without spaces and -_./ separators. It matches the expression used by the
+// 0011 migration.
+func stationCodeKey(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "_", "", ".", "", "/", "").Replace(strings.TrimSpace(code)))
+}
+
+const stationSelectColumns = `id, code, name, latitude, longitude, type, owner, active, created_at, updated_at,
+  (SELECT COUNT(*) FROM assets WHERE assets.station_id = stations.id AND assets.deleted_at IS NULL)`
+
+func scanStation(scan func(dest ...interface{}) error) (Station, error) {
+	var station Station
+	var latitude, longitude sql.NullFloat64
+	if err := scan(&station.ID, &station.Code, &station.Name, &latitude, &longitude, &station.Type, &station.Owner, &station.Active, &station.CreatedAt, &station.UpdatedAt, &station.AssetCount); err != nil {
+		return Station{}, err
+	}
+	if latitude.Valid && longitude.Valid {
+		station.Latitude, station.Longitude = &latitude.Float64, &longitude.Float64
+	}
+	return station, nil
+}
+
+func (a *App) listStations(ctx context.Context, orgID int64, activeOnly bool) ([]Station, error) {
+	query := `SELECT ` + stationSelectColumns + ` FROM stations WHERE org_id = ?`
+	if activeOnly {
+		query += ` AND active = 1`
+	}
+	rows, err := a.db.QueryContext(ctx, query+` ORDER BY code`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stations := []Station{}
+	for rows.Next() {
+		station, err := scanStation(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, station)
+	}
+	return stations, rows.Err()
+}
+
+func getStation(ctx context.Context, q sqlQueryer, orgID, stationID int64) (Station, error) {
+	return queryStation(ctx, q, `org_id = ? AND id = ?`, orgID, stationID)
+}
+
+func getStationByCode(ctx context.Context, q sqlQueryer, orgID int64, code string) (Station, error) {
+	return queryStation(ctx, q, `org_id = ? AND code_key = ?`, orgID, stationCodeKey(code))
+}
+
+func queryStation(ctx context.Context, q sqlQueryer, where string, args ...interface{}) (Station, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+stationSelectColumns+` FROM stations WHERE `+where, args...)
+	if err != nil {
+		return Station{}, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return Station{}, err
+		}
+		return Station{}, errStationNotFound
+	}
+	return scanStation(rows.Scan)
+}
+
+func insertStation(ctx context.Context, exec sqlExecer, orgID int64, payload StationPayload) (int64, error) {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	res, err := exec.ExecContext(ctx, `INSERT INTO stations (org_id, code, code_key, name, latitude, longitude, type, owner, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		orgID,
+		payload.Code,
+		stationCodeKey(payload.Code),
+		payload.Name,
+		payload.Latitude,
+		payload.Longitude,
+		payload.Type,
+		payload.Owner,
+		*payload.Active,
+		now,
+		now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// checkStationCodeFree fails with 409 when another station of the org already
+// uses the code or a spelling variant of it.
+func checkStationCodeFree(ctx context.Context, q sqlQueryer, orgID, stationID int64, code string) error {
+	existing, err := getStationByCode(ctx, q, orgID, code)
+	switch {
+	case errors.Is(err, errStationNotFound):
+		return nil
+	case err != nil:
+		return err
+	case existing.ID != stationID:
+		return httpError{status: http.StatusConflict, message: fmt.Sprintf("station %s already exists", existing.Code)}
+	}
+	return nil
+}
+
+func (a *App) createStation(ctx context.Context, orgID int64, payload StationPayload) (Station, error) {
+	payload.normalize()
+	if err := payload.validate(); err != nil {
+		return Station{}, err
+	}
+
+	var stationID int64
+	err := a.inTx(ctx, func(tx *sql.Tx) error {
+		if err := checkStationCodeFree(ctx, tx, orgID, 0, payload.Code); err != nil {
+			return err
+		}
+		var err error
+		stationID, err = insertStation(ctx, tx, orgID, payload)
+		return err
+	})
+	if err != nil {
+		return Station{}, err
+	}
+	return getStation(ctx, a.db, orgID, stationID)
+}
+
+// updateStation overwrites a station. A new code is copied to the
+// station_name of its assets.
+func (a *App) updateStation(ctx context.Context, orgID, stationID int64, payload StationPayload) (Station, error) {
+	payload.normalize()
+	if err := payload.validate(); err != nil {
+		return Station{}, err
+	}
+
+	err := a.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := getStation(ctx, tx, orgID, stationID)
+		if err != nil {
+			return err
+		}
+		if err := checkStationCodeFree(ctx, tx, orgID, stationID, payload.Code); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE stations SET code = ?, code_key = ?, name = ?, latitude = ?, longitude = ?, type = ?, owner = ?, active = ?, updated_at = ? WHERE org_id = ? AND id = ?`,
+			payload.Code,
+			stationCodeKey(payload.Code),
+			payload.Name,
+			payload.Latitude,
+			payload.Longitude,
+			payload.Type,
+			payload.Owner,
+			*payload.Active,
+			time.Now().UTC().Format(time.RFC3339Nano),
+			orgID,
+			stationID,
+		); err != nil {
+			return err
+		}
+		if current.Code == payload.Code {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE assets SET station_name = ?, version = version + 1 WHERE org_id = ? AND station_id = ?`, payload.Code, orgID, stationID)
+		return err
+	})
+	if err != nil {
+		return Station{}, err
+	}
+	return getStation(ctx, a.db, orgID, stationID)
+}
+
+// deleteStation removes a station that no asset references, including assets
+// in the trash. Stations still in use can be deactivated instead.
+func (a *App) deleteStation(ctx context.Context, orgID, stationID int64) error {
+	return a.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getStation(ctx, tx, orgID, stationID); err != nil {
+			return err
+		}
+		var count int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM assets WHERE org_id = ? AND station_id = ?`, orgID, stationID).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return httpError{status: http.StatusConflict, message: fmt.Sprintf("station is referenced by %d assets; deactivate it instead", count)}
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM stations WHERE org_id = ? AND id = ?`, orgID, stationID)
+		return err
+	})
+}
+
+// resolveAssetStation links a payload to its station. A station_id must name
+// a station of the org and sets station_name to its code. Otherwise the
+// station is looked up by station_name, ignoring spelling variants, and
+// registered on first use so clients that only send names keep working.
+func resolveAssetStation(ctx context.Context, q sqlExecQueryer, orgID int64, payload *AssetPayload) error {
+	if payload.StationID != 0 {
+		station, err := getStation(ctx, q, orgID, payload.StationID)
+		if errors.Is(err, errStationNotFound) {
+			return validationError{message: fmt.Sprintf("station_id %d does not exist", payload.StationID)}
+		}
+		if err != nil {
+			return err
+		}
+		payload.StationName = station.Code
+		return nil
+	}
+
+	station, err := getStationByCode(ctx, q, orgID, payload.StationName)
+	if err == nil {
+		payload.StationID, payload.StationName = station.ID, station.Code
+		return nil
+	}
+	if !errors.Is(err, errStationNotFound) {
+		return err
+	}
+	created := StationPayload{Code: payload.StationName}
+	if payload.Latitude != 0 || payload.Longitude != 0 {
+		created.Latitude, created.Longitude = &payload.Latitude, &payload.Longitude
+	}
+	created.normalize()
+	if err := created.validate(); err != nil {
+		return err
+	}
+	payload.StationID, err = insertStation(ctx, q, orgID, created)
+	return err
+}
+
+func decodeStationPayload(r *http.Request) (StationPayload, error) {
+	defer func() {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	var payload StationPayload
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxAssetPayloadSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&payload); err != nil {
+		return StationPayload{}, validationError{message: "invalid JSON payload: " + err.Error()}
+	}
+	return payload, nil
+}
+
+// handleStations serves /stations.
+func (a *App) handleStations(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		activeOnly, err := parseOptionalBool(r.URL.Query().Get("active"))
+		if err != nil {
+			writeHTTPError(w, validationError{message: "active must be true or false"})
+			return
+		}
+		stations, err := a.listStations(r.Context(), orgID, activeOnly)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": stations})
+	case http.MethodPost:
+		payload, err := decodeStationPayload(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		station, err := a.createStation(r.Context(), orgID, payload)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"data": station})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStation serves /stations/{id}.
+func (a *App) handleStation(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	stationID, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/stations/"), "/"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid station id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		station, err := getStation(r.Context(), a.db, orgID, stationID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": station})
+	case http.MethodPut:
+		payload, err := decodeStationPayload(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		station, err := a.updateStation(r.Context(), orgID, stationID, payload)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": station})
+	case http.MethodDelete:
+		if err := a.deleteStation(r.Context(), orgID, stationID); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
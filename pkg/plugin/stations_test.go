@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestStationsBackfilledFromAssets(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	stations, err := app.listStations(ctx, 1, false)
+	if err != nil {
+		t.Fatalf("list stations: %v", err)
+	}
+	if len(stations) != 2 {
+		t.Fatalf("expected a station per distinct seed station_name, got %+v", stations)
+	}
+	byCode := map[string]Station{}
+	for _, station := range stations {
+		byCode[station.Code] = station
+	}
+	wls, ok := byCode["WLS7-1273"]
+	if !ok || wls.AssetCount != 1 || !wls.Active {
+		t.Fatalf("unexpected backfilled station: %+v", stations)
+	}
+
+	record, err := app.getAsset(ctx, 1, 1)
+	if err != nil || record.StationID != wls.ID {
+		t.Fatalf("expected seed asset to reference station %d, got %+v (%v)", wls.ID, record, err)
+	}
+
+	var other []Station
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "stations", PluginContext: backend.PluginContext{OrgID: 2}}), &other)
+	for _, station := range other {
+		if station.ID == wls.ID {
+			t.Fatalf("expected stations to be scoped to the org, got %+v", other)
+		}
+	}
+}
+
+func TestStationCRUD(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, body := range []string{
+		`{"name":"No code"}`,
+		`{"code":"ST-1","latitude":10}`,
+		`{"code":"ST-1","latitude":100,"longitude":10}`,
+	} {
+		if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "stations", Body: []byte(body)}); resp.Status != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, resp.Status)
+		}
+	}
+
+	resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "stations", Body: []byte(`{"code":" ST-1 ","latitude":-33.9,"longitude":151.2,"type":"tower","owner":"Acme"}`)})
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", resp.Status, resp.Body)
+	}
+	var station Station
+	decodeData(t, resp, &station)
+	if station.Code != "ST-1" || station.Name != "ST-1" || !station.Active || station.Latitude == nil || *station.Latitude != -33.9 {
+		t.Fatalf("unexpected station: %+v", station)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "stations", Body: []byte(`{"code":"st 1"}`)}); resp.Status != http.StatusConflict {
+		t.Fatalf("expected a spelling variant of an existing code to 409, got %d", resp.Status)
+	}
+
+	path := "stations/" + strconv.FormatInt(station.ID, 10)
+	resp = callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: path, Body: []byte(`{"code":"ST-1","name":"Harbour","active":false}`)})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", resp.Status, resp.Body)
+	}
+	var active []Station
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "stations", URL: "stations?active=true"}), &active)
+	for _, s := range active {
+		if s.ID == station.ID {
+			t.Fatalf("expected inactive station to be filtered out, got %+v", active)
+		}
+	}
+
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: path}); resp.Status != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.Status)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: path}); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected deleted station to 404, got %d", resp.Status)
+	}
+}
+
+func TestAssetStationReferences(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	seed, err := app.getAsset(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("get asset: %v", err)
+	}
+
+	// A spelling variant of a known code resolves to the existing station.
+	variant := createTestAsset(t, app, func(p *AssetPayload) { p.Title, p.StationName = "Station asset", "wls7 1273" })
+	if variant.StationID != seed.StationID || variant.StationName != "WLS7-1273" {
+		t.Fatalf("expected variant to resolve to station %d, got %+v", seed.StationID, variant)
+	}
+
+	// An unknown name registers a new station.
+	created := createTestAsset(t, app, func(p *AssetPayload) { p.Title, p.StationName = "Station asset", "NEW-1" })
+	if created.StationID == 0 || created.StationID == seed.StationID {
+		t.Fatalf("expected a new station, got %+v", created)
+	}
+	if _, err := app.createAsset(ctx, 1, AssetPayload{Title: "x", EntryDate: "2025-01-01", CommissioningDate: "2025-01-01", StationID: 9999, Technician: "x", StartDate: "2025-01-01", EndDate: "2025-01-01"}); err == nil {
+		t.Fatal("expected an unknown station_id to be rejected")
+	}
+
+	for _, filters := range []map[string][]string{
+		{"station_name": {"WLS71273"}},
+		{"station_id": {strconv.FormatInt(seed.StationID, 10)}},
+	} {
+		result, err := app.listAssets(ctx, 1, AssetListOptions{Filters: filters})
+		if err != nil || result.TotalCount != 2 {
+			t.Fatalf("%v: expected seed and variant assets, got %+v (%v)", filters, result, err)
+		}
+	}
+
+	patched := callAssetPatch(t, app, "assets/"+strconv.FormatInt(variant.ID, 10), "", `{"station_name":"new 1"}`)
+	var moved AssetRecord
+	decodeData(t, patched, &moved)
+	if moved.StationID != created.StationID || moved.StationName != "NEW-1" {
+		t.Fatalf("expected patch to move the asset to NEW-1, got %d %+v", patched.Status, moved)
+	}
+
+	path := "stations/" + strconv.FormatInt(created.StationID, 10)
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: path, Body: []byte(`{"code":"NEW-2"}`)}); resp.Status != http.StatusOK {
+		t.Fatalf("rename: expected 200, got %d: %s", resp.Status, resp.Body)
+	}
+	renamed, err := app.getAsset(ctx, 1, created.ID)
+	if err != nil || renamed.StationName != "NEW-2" || renamed.Version != created.Version+1 {
+		t.Fatalf("expected rename to propagate to assets, got %+v (%v)", renamed, err)
+	}
+
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: path}); resp.Status != http.StatusConflict {
+		t.Fatalf("expected deleting a referenced station to 409, got %d", resp.Status)
+	}
+}
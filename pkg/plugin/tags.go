@@ -0,0 +1,596 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var errTagNotFound = errors.New("tag not found")
+
+const (
+	maxTagNameLength = 64
+	// tagFilterKey is filter[tag]=a,b, matching assets with any of the tags;
+	// filter[tag][all]=a,b matches assets with every one of them.
+	tagFilterKey   = "tag"
+	tagFilterOpAny = "any"
+	tagFilterOpAll = "all"
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// Tag is a label of the org that assets carry by name.
+type Tag struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Color      string `json:"color"`
+	AssetCount int    `json:"asset_count"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+// TagPayload renames or recolours a tag. Fields left out of an update keep
+// their value.
+type TagPayload struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+// AssetTagCount is how many of the listed assets carry a tag.
+type AssetTagCount struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	Count int    `json:"count"`
+}
+
+// tagNameKey is the form under which tag names are unique.
+func tagNameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func validateTagName(name string) error {
+	switch {
+	case name == "":
+		return validationError{message: "tag name is required"}
+	case utf8.RuneCountInString(name) > maxTagNameLength:
+		return validationError{message: fmt.Sprintf("tag %q is longer than %d characters", name, maxTagNameLength)}
+	case strings.Contains(name, ","):
+		return validationError{message: fmt.Sprintf("tag %q must not contain a comma", name)}
+	}
+	return nil
+}
+
+func normalizeTagColor(color string) (string, error) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if color != "" && !tagColorPattern.MatchString(color) {
+		return "", validationError{message: fmt.Sprintf("color must be a hex colour like #1f77b4, got %q", color)}
+	}
+	return color, nil
+}
+
+// normalizeTags trims tags and drops duplicates that differ only in case,
+// keeping the first spelling. A nil list stays nil.
+func normalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := tagNameKey(tag)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// tagErrors reports the invalid tags of a payload.
+func tagErrors(tags []string) []AssetFieldError {
+	var violations []AssetFieldError
+	for _, tag := range tags {
+		if err := validateTagName(tag); err != nil {
+			violations = append(violations, AssetFieldError{Field: "tags", Rule: "format", Message: err.Error()})
+		}
+	}
+	return violations
+}
+
+const tagSelectColumns = `t.id, t.name, t.color, t.created_at, t.updated_at,
+  (SELECT COUNT(*) FROM asset_tags AS at JOIN assets AS a ON a.id = at.asset_id WHERE at.tag_id = t.id AND a.deleted_at IS NULL)`
+
+func scanTag(scan func(dest ...interface{}) error) (Tag, error) {
+	var tag Tag
+	err := scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt, &tag.AssetCount)
+	return tag, err
+}
+
+func (a *App) listTags(ctx context.Context, orgID int64) ([]Tag, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT `+tagSelectColumns+` FROM tags AS t WHERE t.org_id = ? ORDER BY t.name_key`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func getTag(ctx context.Context, q sqlQueryer, orgID, tagID int64) (Tag, error) {
+	return queryTag(ctx, q, `t.org_id = ? AND t.id = ?`, orgID, tagID)
+}
+
+func queryTag(ctx context.Context, q sqlQueryer, where string, args ...interface{}) (Tag, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+tagSelectColumns+` FROM tags AS t WHERE `+where, args...)
+	if err != nil {
+		return Tag{}, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return Tag{}, err
+		}
+		return Tag{}, errTagNotFound
+	}
+	return scanTag(rows.Scan)
+}
+
+// resolveTag returns the id of the named tag, ignoring case, and registers it
+// on first use.
+func resolveTag(ctx context.Context, q sqlExecQueryer, orgID int64, name string) (int64, error) {
+	tag, err := queryTag(ctx, q, `t.org_id = ? AND t.name_key = ?`, orgID, tagNameKey(name))
+	if err == nil {
+		return tag.ID, nil
+	}
+	if !errors.Is(err, errTagNotFound) {
+		return 0, err
+	}
+	return insertTag(ctx, q, orgID, name, "")
+}
+
+func insertTag(ctx context.Context, exec sqlExecer, orgID int64, name, color string) (int64, error) {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	res, err := exec.ExecContext(ctx, `INSERT INTO tags (org_id, name, name_key, color, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		orgID, name, tagNameKey(name), color, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// syncAssetTags relinks an asset to its tags in the payload's order. A nil
+// list keeps the current tags.
+func syncAssetTags(ctx context.Context, q sqlExecQueryer, orgID, assetID int64, tags []string) error {
+	if tags == nil {
+		return nil
+	}
+	if _, err := q.ExecContext(ctx, `DELETE FROM asset_tags WHERE asset_id = ?`, assetID); err != nil {
+		return err
+	}
+	for i, name := range tags {
+		tagID, err := resolveTag(ctx, q, orgID, name)
+		if err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, `INSERT OR IGNORE INTO asset_tags (asset_id, tag_id, position) VALUES (?, ?, ?)`, assetID, tagID, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAssetTags returns the tag names of each asset in their order.
+func loadAssetTags(ctx context.Context, q sqlQueryer, assetIDs []int64) (map[int64][]string, error) {
+	result := make(map[int64][]string, len(assetIDs))
+	if len(assetIDs) == 0 {
+		return result, nil
+	}
+	placeholders := strings.TrimRight(strings.Repeat("?,", len(assetIDs)), ",")
+	args := make([]interface{}, len(assetIDs))
+	for i, id := range assetIDs {
+		args[i] = id
+	}
+	rows, err := q.QueryContext(ctx, `SELECT at.asset_id, t.name FROM asset_tags AS at JOIN tags AS t ON t.id = at.tag_id WHERE at.asset_id IN (`+placeholders+`) ORDER BY at.asset_id, at.position, t.name_key`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var assetID int64
+		var name string
+		if err := rows.Scan(&assetID, &name); err != nil {
+			return nil, err
+		}
+		result[assetID] = append(result[assetID], name)
+	}
+	return result, rows.Err()
+}
+
+// buildTagFilter matches assets carrying any, or with op "all" every, of the
+// named tags. emptyFilterValue matches untagged assets.
+func buildTagFilter(op string, values []string) (string, []interface{}, []string, error) {
+	if op == "" {
+		op = tagFilterOpAny
+	}
+	if op != tagFilterOpAny && op != tagFilterOpAll {
+		return "", nil, nil, validationError{message: fmt.Sprintf("operator %s is not supported for tag; use any or all", op)}
+	}
+
+	includeEmpty := false
+	seen := map[string]struct{}{}
+	var keys []string
+	for _, raw := range values {
+		for _, part := range strings.Split(raw, ",") {
+			part = strings.TrimSpace(part)
+			if part == emptyFilterValue {
+				includeEmpty = true
+				continue
+			}
+			key := tagNameKey(part)
+			if key == "" {
+				continue
+			}
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	if op == tagFilterOpAll && includeEmpty {
+		return "", nil, nil, validationError{message: "filter tag[all] cannot include " + emptyFilterValue}
+	}
+	if len(keys) == 0 && !includeEmpty {
+		return "", nil, nil, nil
+	}
+	sort.Strings(keys)
+
+	args := make([]interface{}, 0, len(keys)+1)
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	placeholders := strings.TrimRight(strings.Repeat("?,", len(keys)), ",")
+	tagged := `SELECT %s FROM asset_tags AS at JOIN tags AS t ON t.id = at.tag_id WHERE at.asset_id = assets.id AND t.name_key IN (` + placeholders + `)`
+
+	var conditions []string
+	switch {
+	case op == tagFilterOpAll:
+		conditions = append(conditions, fmt.Sprintf(`(`+tagged+`) = ?`, "COUNT(*)"))
+		args = append(args, len(keys))
+	case len(keys) > 0:
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (`+tagged+`)`, "1"))
+	}
+	applied := keys
+	if includeEmpty {
+		conditions = append(conditions, `NOT EXISTS (SELECT 1 FROM asset_tags AS at WHERE at.asset_id = assets.id)`)
+		applied = append(applied, emptyFilterValue)
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args, applied, nil
+}
+
+// countAssetTags counts the tags over every asset matching q, not only the
+// returned page. With near, the radius is applied to the ids first.
+func (a *App) countAssetTags(ctx context.Context, q assetQuery) ([]AssetTagCount, error) {
+	matching := fmt.Sprintf(`SELECT assets.id FROM %s WHERE %s`, q.from, q.where)
+	args := q.args
+	if q.near != nil {
+		ids, err := a.queryNearAssetIDs(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		idsJSON, err := json.Marshal(ids)
+		if err != nil {
+			return nil, err
+		}
+		matching, args = `SELECT value FROM json_each(?)`, []interface{}{string(idsJSON)}
+	}
+	rows, err := a.db.QueryContext(ctx, `SELECT t.id, t.name, t.color, COUNT(*) FROM asset_tags AS at JOIN tags AS t ON t.id = at.tag_id
+WHERE at.asset_id IN (`+matching+`)
+GROUP BY t.id ORDER BY COUNT(*) DESC, t.name_key`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []AssetTagCount{}
+	for rows.Next() {
+		var count AssetTagCount
+		if err := rows.Scan(&count.ID, &count.Name, &count.Color, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// queryNearAssetIDs returns the ids of the assets matching q within the near
+// radius.
+func (a *App) queryNearAssetIDs(ctx context.Context, q assetQuery) ([]int64, error) {
+	rows, err := a.db.QueryContext(ctx, fmt.Sprintf(`SELECT assets.id, assets.latitude, assets.longitude FROM %s WHERE %s`, q.from, q.where), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		var latitude, longitude float64
+		if err := rows.Scan(&id, &latitude, &longitude); err != nil {
+			return nil, err
+		}
+		if haversineKm(q.near.Latitude, q.near.Longitude, latitude, longitude) <= q.near.RadiusKm {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// checkTagNameFree fails with 409 when another tag of the org already has the
+// name, ignoring case.
+func checkTagNameFree(ctx context.Context, q sqlQueryer, orgID, tagID int64, name string) error {
+	existing, err := queryTag(ctx, q, `t.org_id = ? AND t.name_key = ?`, orgID, tagNameKey(name))
+	switch {
+	case errors.Is(err, errTagNotFound):
+		return nil
+	case err != nil:
+		return err
+	case existing.ID != tagID:
+		return httpError{status: http.StatusConflict, message: fmt.Sprintf("tag %s already exists; merge into it instead", existing.Name)}
+	}
+	return nil
+}
+
+func (a *App) createTag(ctx context.Context, orgID int64, payload TagPayload) (Tag, error) {
+	if payload.Name == nil {
+		return Tag{}, validationError{message: "tag name is required"}
+	}
+	name := strings.TrimSpace(*payload.Name)
+	if err := validateTagName(name); err != nil {
+		return Tag{}, err
+	}
+	var color string
+	if payload.Color != nil {
+		var err error
+		if color, err = normalizeTagColor(*payload.Color); err != nil {
+			return Tag{}, err
+		}
+	}
+
+	var tagID int64
+	err := a.inTx(ctx, func(tx *sql.Tx) error {
+		if err := checkTagNameFree(ctx, tx, orgID, 0, name); err != nil {
+			return err
+		}
+		var err error
+		tagID, err = insertTag(ctx, tx, orgID, name, color)
+		return err
+	})
+	if err != nil {
+		return Tag{}, err
+	}
+	return getTag(ctx, a.db, orgID, tagID)
+}
+
+// updateTag renames or recolours a tag. The assets carrying it show the new
+// name, so their version is bumped.
+func (a *App) updateTag(ctx context.Context, orgID, tagID int64, payload TagPayload) (Tag, error) {
+	err := a.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := getTag(ctx, tx, orgID, tagID)
+		if err != nil {
+			return err
+		}
+		name, color := current.Name, current.Color
+		if payload.Name != nil {
+			name = strings.TrimSpace(*payload.Name)
+			if err := validateTagName(name); err != nil {
+				return err
+			}
+			if err := checkTagNameFree(ctx, tx, orgID, tagID, name); err != nil {
+				return err
+			}
+		}
+		if payload.Color != nil {
+			if color, err = normalizeTagColor(*payload.Color); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE tags SET name = ?, name_key = ?, color = ?, updated_at = ? WHERE org_id = ? AND id = ?`,
+			name, tagNameKey(name), color, time.Now().UTC().Format(time.RFC3339Nano), orgID, tagID); err != nil {
+			return err
+		}
+		if name == current.Name {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE assets SET version = version + 1 WHERE org_id = ? AND id IN (SELECT asset_id FROM asset_tags WHERE tag_id = ?)`, orgID, tagID)
+		return err
+	})
+	if err != nil {
+		return Tag{}, err
+	}
+	return getTag(ctx, a.db, orgID, tagID)
+}
+
+// mergeTag moves the assets of a tag to the target tag and deletes it.
+func (a *App) mergeTag(ctx context.Context, orgID, tagID, targetID int64) (Tag, error) {
+	if tagID == targetID {
+		return Tag{}, validationError{message: "a tag cannot be merged into itself"}
+	}
+	err := a.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getTag(ctx, tx, orgID, tagID); err != nil {
+			return err
+		}
+		if _, err := getTag(ctx, tx, orgID, targetID); errors.Is(err, errTagNotFound) {
+			return validationError{message: fmt.Sprintf("target tag %d does not exist", targetID)}
+		} else if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE assets SET version = version + 1 WHERE org_id = ? AND id IN (SELECT asset_id FROM asset_tags WHERE tag_id = ?)`, orgID, tagID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO asset_tags (asset_id, tag_id, position) SELECT asset_id, ?, position FROM asset_tags WHERE tag_id = ?`, targetID, tagID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE org_id = ? AND id = ?`, orgID, tagID)
+		return err
+	})
+	if err != nil {
+		return Tag{}, err
+	}
+	return getTag(ctx, a.db, orgID, targetID)
+}
+
+// deleteTag removes a tag from the org and its assets.
+func (a *App) deleteTag(ctx context.Context, orgID, tagID int64) error {
+	return a.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getTag(ctx, tx, orgID, tagID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE assets SET version = version + 1 WHERE org_id = ? AND id IN (SELECT asset_id FROM asset_tags WHERE tag_id = ?)`, orgID, tagID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE org_id = ? AND id = ?`, orgID, tagID)
+		return err
+	})
+}
+
+func decodeTagPayload(r *http.Request) (TagPayload, error) {
+	defer func() {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	var payload TagPayload
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxAssetPayloadSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&payload); err != nil {
+		return TagPayload{}, validationError{message: "invalid JSON payload: " + err.Error()}
+	}
+	return payload, nil
+}
+
+// handleTags serves /tags.
+func (a *App) handleTags(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tags, err := a.listTags(r.Context(), orgID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": tags})
+	case http.MethodPost:
+		payload, err := decodeTagPayload(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		tag, err := a.createTag(r.Context(), orgID, payload)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"data": tag})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTag serves /tags/{id} and POST /tags/{id}/merge.
+func (a *App) handleTag(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tags/"), "/")
+	idPart, action, _ := strings.Cut(rest, "/")
+	tagID, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid tag id", http.StatusBadRequest)
+		return
+	}
+
+	if action != "" {
+		if action != "merge" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Into int64 `json:"into"`
+		}
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxAssetPayloadSize))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&body); err != nil || body.Into <= 0 {
+			writeHTTPError(w, validationError{message: `merge expects {"into": <tag id>}`})
+			return
+		}
+		tag, err := a.mergeTag(r.Context(), orgID, tagID, body.Into)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": tag})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tag, err := getTag(r.Context(), a.db, orgID, tagID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": tag})
+	case http.MethodPatch:
+		payload, err := decodeTagPayload(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		tag, err := a.updateTag(r.Context(), orgID, tagID, payload)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": tag})
+	case http.MethodDelete:
+		if err := a.deleteTag(r.Context(), orgID, tagID); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
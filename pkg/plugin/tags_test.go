@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestAssetTagFilters(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	both := createTestAsset(t, app, func(p *AssetPayload) {
+		p.Title, p.StationName, p.Tags = "Both", "TAG-1", []string{"warranty", "Storm-Damage", "WARRANTY"}
+	})
+	if len(both.Tags) != 2 || both.Tags[0] != "warranty" || both.Tags[1] != "Storm-Damage" {
+		t.Fatalf("expected tags deduplicated in order, got %v", both.Tags)
+	}
+	createTestAsset(t, app, func(p *AssetPayload) {
+		p.Title, p.StationName, p.Tags = "Lidar", "TAG-1", []string{"lidar", "storm-damage"}
+	})
+
+	for _, tt := range []struct {
+		filters map[string][]string
+		total   int64
+	}{
+		{filters: map[string][]string{"tag": {"warranty,lidar"}}, total: 2},
+		{filters: map[string][]string{"tag[all]": {"storm-damage", "Warranty"}}, total: 1},
+		{filters: map[string][]string{"tag[all]": {"lidar,warranty"}}, total: 0},
+		{filters: map[string][]string{"tag": {emptyFilterValue}}, total: 2},
+	} {
+		result, err := app.listAssets(ctx, 1, AssetListOptions{Filters: tt.filters})
+		if err != nil || result.TotalCount != tt.total {
+			t.Fatalf("%v: expected %d assets, got %+v (%v)", tt.filters, tt.total, result, err)
+		}
+	}
+	if _, err := app.listAssets(ctx, 1, AssetListOptions{Filters: map[string][]string{"tag[none]": {"lidar"}}}); err == nil {
+		t.Fatal("expected an unknown tag operator to be rejected")
+	}
+
+	result, err := app.listAssets(ctx, 1, AssetListOptions{Filters: map[string][]string{"tag": {"storm-damage"}}})
+	if err != nil {
+		t.Fatalf("list assets: %v", err)
+	}
+	if len(result.TagCounts) != 3 || result.TagCounts[0].Name != "Storm-Damage" || result.TagCounts[0].Count != 2 {
+		t.Fatalf("expected tag counts over the filtered assets, got %+v", result.TagCounts)
+	}
+
+	// PUT without tags keeps them; a merge patch replaces them.
+	if _, err := app.updateAsset(ctx, 1, both.ID, AssetPayload{
+		Title: "Both", EntryDate: "2025-01-01", CommissioningDate: "2025-01-01", StationName: "TAG-1",
+		Technician: "J. Doe", StartDate: "2025-01-01", EndDate: "2025-01-02",
+	}, 0); err != nil {
+		t.Fatalf("update asset: %v", err)
+	}
+	patched := callAssetPatch(t, app, "assets/"+strconv.FormatInt(both.ID, 10), "", `{"tags":["lidar"]}`)
+	var record AssetRecord
+	decodeData(t, patched, &record)
+	if len(record.Tags) != 1 || record.Tags[0] != "lidar" {
+		t.Fatalf("expected the patch to replace the tags, got %v", record.Tags)
+	}
+	if resp := callAssetPatch(t, app, "assets/"+strconv.FormatInt(both.ID, 10), "", `{"tags":["a,b"]}`); resp.Status != http.StatusBadRequest {
+		t.Fatalf("expected a tag with a comma to be rejected, got %d", resp.Status)
+	}
+}
+
+func TestTagManagement(t *testing.T) {
+	app := newTestApp(t)
+	first := createTestAsset(t, app, func(p *AssetPayload) { p.Title, p.StationName, p.Tags = "First", "TAG-1", []string{"storm"} })
+	createTestAsset(t, app, func(p *AssetPayload) {
+		p.Title, p.StationName, p.Tags = "Second", "TAG-1", []string{"storm-damage", "lidar"}
+	})
+
+	var tags []Tag
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "tags"}), &tags)
+	if len(tags) != 3 || tags[1].Name != "storm" || tags[1].AssetCount != 1 {
+		t.Fatalf("unexpected tags: %+v", tags)
+	}
+	storm, damage := tags[1], tags[2]
+
+	path := "tags/" + strconv.FormatInt(storm.ID, 10)
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPatch, Path: path, Body: []byte(`{"name":"Storm-damage"}`)}); resp.Status != http.StatusConflict {
+		t.Fatalf("expected renaming onto another tag to 409, got %d: %s", resp.Status, resp.Body)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPatch, Path: path, Body: []byte(`{"color":"red"}`)}); resp.Status != http.StatusBadRequest {
+		t.Fatalf("expected an invalid colour to 400, got %d", resp.Status)
+	}
+	var renamed Tag
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPatch, Path: path, Body: []byte(`{"name":"Sturm","color":"#FF0000"}`)}), &renamed)
+	if renamed.Name != "Sturm" || renamed.Color != "#ff0000" {
+		t.Fatalf("unexpected renamed tag: %+v", renamed)
+	}
+	asset, err := app.getAsset(context.Background(), 1, first.ID)
+	if err != nil || len(asset.Tags) != 1 || asset.Tags[0] != "Sturm" || asset.Version != first.Version+1 {
+		t.Fatalf("expected the asset to show the new name, got %+v (%v)", asset, err)
+	}
+
+	body, _ := json.Marshal(map[string]int64{"into": damage.ID})
+	var merged Tag
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: path + "/merge", Body: body}), &merged)
+	if merged.ID != damage.ID || merged.AssetCount != 2 {
+		t.Fatalf("expected the merge target to carry both assets, got %+v", merged)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: path}); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected the merged tag to be gone, got %d", resp.Status)
+	}
+
+	var other []Tag
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "tags", PluginContext: backend.PluginContext{OrgID: 2}}), &other)
+	if len(other) != 0 {
+		t.Fatalf("expected tags to be scoped to the org, got %+v", other)
+	}
+}
@@ -0,0 +1,201 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+)
+
+const trashSweepInterval = time.Hour
+
+// AssetTrashEntry is a deleted asset waiting to be purged.
+type AssetTrashEntry struct {
+	ID              int64  `json:"id"`
+	Title           string `json:"title"`
+	StationName     string `json:"station_name"`
+	Technician      string `json:"technician"`
+	EntryDate       string `json:"entry_date"`
+	AttachmentCount int    `json:"attachment_count"`
+	DeletedAt       string `json:"deleted_at"`
+	PurgeAt         string `json:"purge_at"`
+}
+
+func (a *App) listTrashedAssets(ctx context.Context, orgID int64) ([]AssetTrashEntry, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT id, title, station_name, technician, entry_date, deleted_at,
+  (SELECT COUNT(*) FROM asset_files AS f WHERE f.asset_id = assets.id)
+FROM assets WHERE org_id = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AssetTrashEntry{}
+	for rows.Next() {
+		var entry AssetTrashEntry
+		if err := rows.Scan(&entry.ID, &entry.Title, &entry.StationName, &entry.Technician, &entry.EntryDate, &entry.DeletedAt, &entry.AttachmentCount); err != nil {
+			return nil, err
+		}
+		if deletedAt, err := time.Parse(sqliteTimestampLayout, entry.DeletedAt); err == nil {
+			entry.PurgeAt = deletedAt.Add(a.config.TrashRetention).Format(sqliteTimestampLayout)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// restoreTrashedAsset takes an asset out of the trash.
+func (a *App) restoreTrashedAsset(ctx context.Context, orgID, assetID int64) (AssetRecord, error) {
+	err := a.inEventTx(ctx, orgID, func(tx *sql.Tx, events *assetEvents) error {
+		res, err := tx.ExecContext(ctx, `UPDATE assets SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE org_id = ? AND id = ? AND deleted_at IS NOT NULL`,
+			time.Now().UTC().Format(time.RFC3339Nano),
+			orgID,
+			assetID,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return errAssetNotFound
+		}
+		if err := recordAssetRevision(ctx, tx, orgID, assetID, revisionActionRestore); err != nil {
+			return err
+		}
+		return a.addAssetEvent(ctx, tx, events, webhookEventAssetCreated, assetID)
+	})
+	if err != nil {
+		return AssetRecord{}, err
+	}
+	return a.getAsset(ctx, orgID, assetID)
+}
+
+// purgeTrashedAssets permanently removes assets of an org that were deleted
+// before cutoff, together with their stored objects. An asset whose objects
+// cannot be removed stays in the trash and is retried on the next sweep.
+func (a *App) purgeTrashedAssets(ctx context.Context, orgID int64, cutoff time.Time) (int, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT id FROM assets WHERE org_id = ? AND deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)`,
+		orgID,
+		cutoff.UTC().Format(sqliteTimestampLayout),
+	)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		keys, err := assetStorageKeys(ctx, a.db, orgID, id)
+		if err != nil {
+			return purged, err
+		}
+		if len(keys) > 0 && !a.storageConfigured() {
+			log.Printf("trash: keeping asset %d of org %d, storage is not configured to delete its %d objects", id, orgID, len(keys))
+			continue
+		}
+		if err := a.deleteStoredObjects(ctx, keys); err != nil {
+			log.Printf("trash: keeping asset %d of org %d: %v", id, orgID, err)
+			continue
+		}
+		if err := deleteAssetRow(ctx, a.db, orgID, id); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (a *App) deleteStoredObjects(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := a.storage.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func assetStorageKeys(ctx context.Context, q sqlQueryer, orgID, assetID int64) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT object_name FROM asset_files WHERE org_id = ? AND asset_id = ?`, orgID, assetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// startTrashSweeper purges the org's expired trash once at startup and then
+// every trashSweepInterval until Dispose.
+func (a *App) startTrashSweeper(orgID int64) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	a.stopSweeper = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(trashSweepInterval)
+		defer ticker.Stop()
+		for {
+			purged, err := a.purgeTrashedAssets(ctx, orgID, time.Now().Add(-a.config.TrashRetention))
+			switch {
+			case err != nil && ctx.Err() == nil:
+				log.Printf("trash: sweep for org %d failed: %v", orgID, err)
+			case purged > 0:
+				log.Printf("trash: purged %d assets for org %d", purged, orgID)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (a *App) handleAssetTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	entries, err := a.listTrashedAssets(r.Context(), orgID)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": entries,
+		"meta": map[string]interface{}{"retentionDays": int(a.config.TrashRetention / (24 * time.Hour))},
+	})
+}
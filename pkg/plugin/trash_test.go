@@ -0,0 +1,132 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// recordingStorage is a StorageClient that remembers deleted objects.
+type recordingStorage struct {
+	deleted []string
+}
+
+func (s *recordingStorage) Upload(context.Context, string, io.Reader, int64, string) error {
+	return nil
+}
+
+func (s *recordingStorage) Delete(_ context.Context, object string) error {
+	s.deleted = append(s.deleted, object)
+	return nil
+}
+
+func (s *recordingStorage) SignedURL(_ context.Context, object string, _ time.Duration) (string, error) {
+	return "https://storage.test/" + object, nil
+}
+
+func (s *recordingStorage) Close() error {
+	return nil
+}
+
+func TestDeleteMovesAssetToTrash(t *testing.T) {
+	app := newTestApp(t)
+	before := countLiveAssets(t, app, 1)
+
+	resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: "assets/2"})
+	if resp.Status != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.Status)
+	}
+	if got := countLiveAssets(t, app, 1); got != before-1 {
+		t.Fatalf("expected %d listed assets after delete, got %d", before-1, got)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/2"}); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected trashed asset to be hidden, got %d", resp.Status)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: "assets/2"}); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected second delete to 404, got %d", resp.Status)
+	}
+
+	var trash []AssetTrashEntry
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/trash"}), &trash)
+	if len(trash) != 1 || trash[0].ID != 2 || trash[0].AttachmentCount != 2 || trash[0].PurgeAt == "" {
+		t.Fatalf("unexpected trash: %+v", trash)
+	}
+
+	resp = callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "assets/2/restore"})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("restore: expected 200, got %d: %s", resp.Status, resp.Body)
+	}
+	record, err := app.getAsset(context.Background(), 1, 2)
+	if err != nil || len(record.Attachments) != 2 {
+		t.Fatalf("expected asset 2 restored with its attachments, got %+v (%v)", record, err)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "assets/2/restore"}); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected restoring a live asset to 404, got %d", resp.Status)
+	}
+}
+
+func TestPurgeTrashedAssets(t *testing.T) {
+	t.Setenv(envForceLocalStorage, "true")
+	app := newTestApp(t)
+	storage := &recordingStorage{}
+	app.storage = storage
+	ctx := context.Background()
+
+	for _, id := range []int64{1, 2} {
+		if err := app.deleteAsset(ctx, 1, id, 0); err != nil {
+			t.Fatalf("delete asset %d: %v", id, err)
+		}
+	}
+	keys, err := assetStorageKeys(ctx, app.db, 1, 2)
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("expected 2 stored objects for asset 2, got %v (%v)", keys, err)
+	}
+
+	purged, err := app.purgeTrashedAssets(ctx, 1, time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Fatalf("expected nothing to purge before the retention period, got %d (%v)", purged, err)
+	}
+
+	purged, err = app.purgeTrashedAssets(ctx, 1, time.Now().Add(time.Minute))
+	if err != nil || purged != 2 {
+		t.Fatalf("expected 2 purged assets, got %d (%v)", purged, err)
+	}
+	sort.Strings(storage.deleted)
+	sort.Strings(keys)
+	if len(storage.deleted) != 2 || storage.deleted[0] != keys[0] || storage.deleted[1] != keys[1] {
+		t.Fatalf("expected stored objects %v to be deleted, got %v", keys, storage.deleted)
+	}
+
+	var remaining int
+	if err := app.db.QueryRow(`SELECT COUNT(*) FROM assets WHERE org_id = 1 AND id IN (1, 2)`).Scan(&remaining); err != nil || remaining != 0 {
+		t.Fatalf("expected purged rows to be gone, got %d (%v)", remaining, err)
+	}
+	if err := app.db.QueryRow(`SELECT COUNT(*) FROM asset_files WHERE asset_id = 2`).Scan(&remaining); err != nil || remaining != 0 {
+		t.Fatalf("expected attachment rows to cascade, got %d (%v)", remaining, err)
+	}
+}
+
+func TestParseConfigTrashRetention(t *testing.T) {
+	cfg, err := parseConfig(backend.AppInstanceSettings{JSONData: []byte(`{"trashRetentionDays":7}`)})
+	if err != nil || cfg.TrashRetention != 7*24*time.Hour {
+		t.Fatalf("expected 7 day retention, got %v (%v)", cfg.TrashRetention, err)
+	}
+	cfg, _ = parseConfig(backend.AppInstanceSettings{})
+	if cfg.TrashRetention != defaultTrashRetentionDays*24*time.Hour {
+		t.Fatalf("expected default retention, got %v", cfg.TrashRetention)
+	}
+}
+
+func countLiveAssets(t *testing.T, app *App, orgID int64) int64 {
+	t.Helper()
+	result, err := app.listAssets(context.Background(), orgID, AssetListOptions{Page: 1, PageSize: 100})
+	if err != nil {
+		t.Fatalf("list assets: %v", err)
+	}
+	return result.TotalCount
+}
@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestAssetIfMatchPreconditions(t *testing.T) {
+	app := newTestApp(t)
+
+	get := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: "assets/1"})
+	etag := headerValue(get, "ETag")
+	if get.Status != http.StatusOK || etag != `"1"` {
+		t.Fatalf("expected ETag \"1\", got %d %q", get.Status, etag)
+	}
+	var record AssetRecord
+	decodeData(t, get, &record)
+	body, err := json.Marshal(AssetPayload{
+		Title:             "Edited",
+		EntryDate:         record.EntryDate,
+		CommissioningDate: record.CommissioningDate,
+		StationName:       record.StationName,
+		Technician:        record.Technician,
+		StartDate:         record.StartDate,
+		EndDate:           record.EndDate,
+		Staff:             record.Staff,
+		Latitude:          record.Latitude,
+		Longitude:         record.Longitude,
+	})
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	ifMatch := func(value string) map[string][]string {
+		return map[string][]string{"If-Match": {value}}
+	}
+
+	put := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: "assets/1", Headers: ifMatch(etag), Body: body})
+	if put.Status != http.StatusOK || headerValue(put, "ETag") != `"2"` {
+		t.Fatalf("expected update to bump ETag to \"2\", got %d %q", put.Status, headerValue(put, "ETag"))
+	}
+
+	stale := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: "assets/1", Headers: ifMatch(etag), Body: body})
+	if stale.Status != http.StatusPreconditionFailed || headerValue(stale, "ETag") != `"2"` {
+		t.Fatalf("expected 412 with current ETag, got %d %q", stale.Status, headerValue(stale, "ETag"))
+	}
+	var current AssetRecord
+	decodeData(t, stale, &current)
+	if current.Version != 2 || current.Title != "Edited" {
+		t.Fatalf("expected 412 body to hold the current record, got %+v", current)
+	}
+
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: "assets/1", Headers: ifMatch(etag)}); resp.Status != http.StatusPreconditionFailed {
+		t.Fatalf("expected stale delete to 412, got %d", resp.Status)
+	}
+	for _, value := range []string{`W/"2"`, "2", `"2", "3"`} {
+		if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: "assets/1", Headers: ifMatch(value)}); resp.Status != http.StatusBadRequest {
+			t.Fatalf("If-Match %s: expected 400, got %d", value, resp.Status)
+		}
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPut, Path: "assets/1", Body: body}); resp.Status != http.StatusOK {
+		t.Fatalf("expected update without If-Match to succeed, got %d", resp.Status)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: "assets/1", Headers: ifMatch(`"3"`)}); resp.Status != http.StatusNoContent {
+		t.Fatalf("expected delete with current ETag to succeed, got %d", resp.Status)
+	}
+}
+
+func TestAssetBatchHonoursVersions(t *testing.T) {
+	app := newTestApp(t)
+
+	status, resp := callAssetBatch(t, app, `{"operations":[{"op":"update","id":1,"version":5,"asset":`+batchTestAsset+`}]}`)
+	if status != http.StatusUnprocessableEntity || resp.Results[0].Status != http.StatusPreconditionFailed {
+		t.Fatalf("expected version mismatch to fail with 412, got %d %+v", status, resp)
+	}
+	status, resp = callAssetBatch(t, app, `{"operations":[{"op":"update","id":1,"version":1,"asset":`+batchTestAsset+`}]}`)
+	if status != http.StatusOK || resp.Results[0].Asset.Version != 2 {
+		t.Fatalf("expected update at current version to succeed, got %d %+v", status, resp)
+	}
+}
+
+func headerValue(resp *backend.CallResourceResponse, name string) string {
+	for key, values := range resp.Headers {
+		if http.CanonicalHeaderKey(key) == http.CanonicalHeaderKey(name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,815 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	errWebhookNotFound         = errors.New("webhook not found")
+	errWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	errWebhookAddressBlocked   = errors.New("webhook address is not allowed")
+)
+
+const (
+	webhookEventAssetCreated = "asset.created"
+	webhookEventAssetUpdated = "asset.updated"
+	webhookEventAssetDeleted = "asset.deleted"
+	webhookEventFileUploaded = "file.uploaded"
+	webhookEventFileDeleted  = "file.deleted"
+
+	webhookStatusPending   = "pending"
+	webhookStatusDelivered = "delivered"
+	webhookStatusFailed    = "failed"
+
+	webhookPollInterval   = 5 * time.Second
+	webhookRequestTimeout = 10 * time.Second
+	// webhookLease keeps a claimed delivery from being sent again by another
+	// dispatcher while its request is in flight.
+	webhookLease       = time.Minute
+	webhookRetryBase   = 30 * time.Second
+	webhookRetryMax    = time.Hour
+	webhookMaxAttempts = 8
+	webhookBatchSize   = 20
+	// webhookRetention is how long finished deliveries stay in the log.
+	webhookRetention = 30 * 24 * time.Hour
+
+	minWebhookSecretLength      = 16
+	maxWebhookURLLength         = 2048
+	maxWebhookErrorLength       = 500
+	defaultWebhookDeliveryLimit = 50
+	maxWebhookDeliveryLimit     = 500
+)
+
+var webhookEvents = []string{
+	webhookEventAssetCreated,
+	webhookEventAssetUpdated,
+	webhookEventAssetDeleted,
+	webhookEventFileUploaded,
+	webhookEventFileDeleted,
+}
+
+// newWebhookHTTPClient builds the client deliveries are sent with. It checks
+// every address it connects to, so a host name that resolves to an internal
+// address is refused like the address itself. It does not follow redirects; a
+// redirect counts as a failed delivery.
+func newWebhookHTTPClient(allowed func() []*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookRequestTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !webhookAddressAllowed(ip, allowed()) {
+				return fmt.Errorf("%w: %s", errWebhookAddressBlocked, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be the only address checked.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhookRequestTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// webhookAddressAllowed refuses loopback, link-local, private, unspecified and
+// multicast addresses unless one of allowed contains them, so org users cannot
+// have the backend call internal services.
+func webhookAddressAllowed(ip net.IP, allowed []*net.IPNet) bool {
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsUnspecified() &&
+		!ip.IsMulticast()
+}
+
+// Webhook is an org's subscription to asset and attachment events.
+type Webhook struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+	// Secret is only returned when the webhook is created.
+	Secret    string `json:"secret,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// WebhookPayload is the writable part of a Webhook. An empty Secret is
+// generated on create and keeps the current one on update; a nil Active
+// defaults to true on create and keeps the current state on update.
+type WebhookPayload struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+// WebhookDelivery is one event queued for, or sent to, a webhook.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  string          `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      string          `json:"created_at"`
+	DeliveredAt    string          `json:"delivered_at,omitempty"`
+}
+
+func (p *WebhookPayload) normalize() {
+	p.URL = strings.TrimSpace(p.URL)
+	p.Secret = strings.TrimSpace(p.Secret)
+	seen := make(map[string]struct{}, len(p.Events))
+	events := make([]string, 0, len(p.Events))
+	for _, event := range p.Events {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if _, ok := seen[event]; ok || event == "" {
+			continue
+		}
+		seen[event] = struct{}{}
+		events = append(events, event)
+	}
+	p.Events = events
+}
+
+// validate checks the payload. Host names are checked again when a delivery
+// connects, as they may resolve differently by then.
+func (p WebhookPayload) validate(allowed []*net.IPNet) error {
+	parsed, err := url.Parse(p.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return validationError{message: "url must be an absolute http or https URL"}
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	ip := net.ParseIP(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		ip = net.IPv4(127, 0, 0, 1)
+	}
+	if ip != nil && !webhookAddressAllowed(ip, allowed) {
+		return validationError{message: "url must not point to a loopback, link-local or private address"}
+	}
+	if len(p.URL) > maxWebhookURLLength {
+		return validationError{message: fmt.Sprintf("url must be at most %d characters", maxWebhookURLLength)}
+	}
+	if p.Secret != "" && len(p.Secret) < minWebhookSecretLength {
+		return validationError{message: fmt.Sprintf("secret must be at least %d characters", minWebhookSecretLength)}
+	}
+	if len(p.Events) == 0 {
+		return validationError{message: "events must not be empty"}
+	}
+	for _, event := range p.Events {
+		if !isWebhookEvent(event) {
+			return validationError{message: fmt.Sprintf("unknown event %q, expected one of %s", event, strings.Join(webhookEvents, ", "))}
+		}
+	}
+	return nil
+}
+
+func isWebhookEvent(event string) bool {
+	for _, known := range webhookEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// signWebhookPayload returns the X-Assetlog-Signature value for a body sent at
+// timestamp: sha256= followed by the hex HMAC-SHA256 of "<timestamp>.<body>".
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is the wait before the next attempt after attempts failures.
+func webhookBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMax {
+		delay = webhookRetryMax
+	}
+	return delay
+}
+
+func scanWebhook(scan func(dest ...interface{}) error) (Webhook, error) {
+	var hook Webhook
+	var events string
+	var active int
+	if err := scan(&hook.ID, &hook.URL, &events, &active, &hook.CreatedAt, &hook.UpdatedAt); err != nil {
+		return Webhook{}, err
+	}
+	if err := json.Unmarshal([]byte(events), &hook.Events); err != nil {
+		return Webhook{}, fmt.Errorf("decode events of webhook %d: %w", hook.ID, err)
+	}
+	hook.Active = active != 0
+	return hook, nil
+}
+
+const webhookSelectColumns = `id, url, events, active, created_at, updated_at`
+
+func (a *App) listWebhooks(ctx context.Context, orgID int64) ([]Webhook, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT `+webhookSelectColumns+` FROM webhooks WHERE org_id = ? ORDER BY id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+func (a *App) getWebhook(ctx context.Context, orgID, webhookID int64) (Webhook, error) {
+	row := a.db.QueryRowContext(ctx, `SELECT `+webhookSelectColumns+` FROM webhooks WHERE org_id = ? AND id = ?`, orgID, webhookID)
+	hook, err := scanWebhook(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Webhook{}, errWebhookNotFound
+	}
+	return hook, err
+}
+
+func (a *App) createWebhook(ctx context.Context, orgID int64, payload WebhookPayload) (Webhook, error) {
+	payload.normalize()
+	if err := payload.validate(a.config.WebhookAllowedNetworks); err != nil {
+		return Webhook{}, err
+	}
+	if payload.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return Webhook{}, err
+		}
+		payload.Secret = secret
+	}
+	active := payload.Active == nil || *payload.Active
+	events, err := json.Marshal(payload.Events)
+	if err != nil {
+		return Webhook{}, err
+	}
+	stamp := time.Now().UTC().Format(time.RFC3339Nano)
+	res, err := a.db.ExecContext(ctx, `INSERT INTO webhooks (org_id, url, secret, events, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		orgID,
+		payload.URL,
+		payload.Secret,
+		string(events),
+		active,
+		stamp,
+		stamp,
+	)
+	if err != nil {
+		return Webhook{}, err
+	}
+	webhookID, err := res.LastInsertId()
+	if err != nil {
+		return Webhook{}, err
+	}
+	hook, err := a.getWebhook(ctx, orgID, webhookID)
+	if err != nil {
+		return Webhook{}, err
+	}
+	hook.Secret = payload.Secret
+	return hook, nil
+}
+
+func (a *App) updateWebhook(ctx context.Context, orgID, webhookID int64, payload WebhookPayload) (Webhook, error) {
+	payload.normalize()
+	if err := payload.validate(a.config.WebhookAllowedNetworks); err != nil {
+		return Webhook{}, err
+	}
+	events, err := json.Marshal(payload.Events)
+	if err != nil {
+		return Webhook{}, err
+	}
+	var active interface{}
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+	res, err := a.db.ExecContext(ctx, `UPDATE webhooks SET url = ?, secret = COALESCE(NULLIF(?, ''), secret), events = ?, active = COALESCE(?, active), updated_at = ? WHERE org_id = ? AND id = ?`,
+		payload.URL,
+		payload.Secret,
+		string(events),
+		active,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		orgID,
+		webhookID,
+	)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return Webhook{}, err
+	} else if affected == 0 {
+		return Webhook{}, errWebhookNotFound
+	}
+	return a.getWebhook(ctx, orgID, webhookID)
+}
+
+func (a *App) deleteWebhook(ctx context.Context, orgID, webhookID int64) error {
+	res, err := a.db.ExecContext(ctx, `DELETE FROM webhooks WHERE org_id = ? AND id = ?`, orgID, webhookID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errWebhookNotFound
+	}
+	return nil
+}
+
+const webhookDeliverySelectColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func scanWebhookDelivery(scan func(dest ...interface{}) error) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	var payload string
+	var statusCode sql.NullInt64
+	var deliveredAt sql.NullString
+	if err := scan(&delivery.ID, &delivery.WebhookID, &delivery.Event, &payload, &delivery.Status, &delivery.Attempts,
+		&delivery.NextAttemptAt, &statusCode, &delivery.LastError, &delivery.CreatedAt, &deliveredAt); err != nil {
+		return WebhookDelivery{}, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		delivery.LastStatusCode = &code
+	}
+	delivery.DeliveredAt = deliveredAt.String
+	if delivery.Status != webhookStatusPending {
+		delivery.NextAttemptAt = ""
+	}
+	return delivery, nil
+}
+
+// listWebhookDeliveries returns the latest deliveries of a webhook, newest
+// first, optionally only those with status.
+func (a *App) listWebhookDeliveries(ctx context.Context, orgID, webhookID int64, status string, limit int) ([]WebhookDelivery, error) {
+	if _, err := a.getWebhook(ctx, orgID, webhookID); err != nil {
+		return nil, err
+	}
+	rows, err := a.db.QueryContext(ctx, `SELECT `+webhookDeliverySelectColumns+` FROM webhook_deliveries
+WHERE org_id = ? AND webhook_id = ? AND (? = '' OR status = ?) ORDER BY id DESC LIMIT ?`,
+		orgID, webhookID, status, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (a *App) getWebhookDelivery(ctx context.Context, orgID, webhookID, deliveryID int64) (WebhookDelivery, error) {
+	row := a.db.QueryRowContext(ctx, `SELECT `+webhookDeliverySelectColumns+` FROM webhook_deliveries WHERE org_id = ? AND webhook_id = ? AND id = ?`,
+		orgID, webhookID, deliveryID)
+	delivery, err := scanWebhookDelivery(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return WebhookDelivery{}, errWebhookDeliveryNotFound
+	}
+	return delivery, err
+}
+
+// retryWebhookDelivery queues a delivery to be sent again right away. A
+// delivery that already failed for good gets one more attempt.
+func (a *App) retryWebhookDelivery(ctx context.Context, orgID, webhookID, deliveryID int64) (WebhookDelivery, error) {
+	res, err := a.db.ExecContext(ctx, `UPDATE webhook_deliveries SET status = ?, next_attempt_at = ? WHERE org_id = ? AND webhook_id = ? AND id = ?`,
+		webhookStatusPending,
+		time.Now().UTC().Format(sqliteTimestampLayout),
+		orgID,
+		webhookID,
+		deliveryID,
+	)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return WebhookDelivery{}, err
+	} else if affected == 0 {
+		return WebhookDelivery{}, errWebhookDeliveryNotFound
+	}
+	a.wakeWebhookDispatcher()
+	return a.getWebhookDelivery(ctx, orgID, webhookID, deliveryID)
+}
+
+// enqueueWebhookEvent queues a delivery of an encoded assetEvent for every
+// active webhook of the org subscribed to event.
+func enqueueWebhookEvent(ctx context.Context, q sqlExecer, orgID int64, event string, body []byte, now time.Time) (int64, error) {
+	res, err := q.ExecContext(ctx, `INSERT INTO webhook_deliveries (org_id, webhook_id, event, payload, status, next_attempt_at, created_at)
+SELECT org_id, id, ?, ?, ?, ?, ? FROM webhooks
+WHERE org_id = ? AND active = 1 AND EXISTS (SELECT 1 FROM json_each(webhooks.events) WHERE value = ?)`,
+		event,
+		string(body),
+		webhookStatusPending,
+		now.UTC().Format(sqliteTimestampLayout),
+		now.UTC().Format(sqliteTimestampLayout),
+		orgID,
+		event,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (a *App) wakeWebhookDispatcher() {
+	select {
+	case a.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// startWebhookDispatcher sends the org's due deliveries every
+// webhookPollInterval, and right away when an event is queued, until Dispose.
+func (a *App) startWebhookDispatcher(orgID int64) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	wake := make(chan struct{}, 1)
+	a.webhookWake = wake
+	a.stopDispatcher = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		var pruned time.Time
+		for {
+			now := time.Now()
+			if _, err := a.dispatchWebhookDeliveries(ctx, orgID, now); err != nil && ctx.Err() == nil {
+				log.Printf("webhooks: dispatch for org %d failed: %v", orgID, err)
+			}
+			if now.Sub(pruned) >= time.Hour {
+				if err := a.pruneWebhookDeliveries(ctx, orgID, now.Add(-webhookRetention)); err != nil && ctx.Err() == nil {
+					log.Printf("webhooks: prune for org %d failed: %v", orgID, err)
+				}
+				pruned = now
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+func (a *App) pruneWebhookDeliveries(ctx context.Context, orgID int64, cutoff time.Time) error {
+	_, err := a.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE org_id = ? AND status <> ? AND created_at < ?`,
+		orgID, webhookStatusPending, cutoff.UTC().Format(sqliteTimestampLayout))
+	return err
+}
+
+// dispatchWebhookDeliveries sends the org's deliveries that are due at now and
+// returns how many were attempted.
+func (a *App) dispatchWebhookDeliveries(ctx context.Context, orgID int64, now time.Time) (int, error) {
+	attempted := 0
+	for {
+		candidates, err := a.dueWebhookDeliveries(ctx, orgID, now)
+		if err != nil {
+			return attempted, err
+		}
+		for _, candidate := range candidates {
+			claimed, err := a.claimWebhookDelivery(ctx, candidate.id, candidate.nextAttemptAt, now)
+			if err != nil {
+				return attempted, err
+			}
+			if !claimed {
+				continue
+			}
+			if err := a.attemptWebhookDelivery(ctx, candidate.id, now); err != nil {
+				return attempted, err
+			}
+			attempted++
+		}
+		if len(candidates) < webhookBatchSize {
+			return attempted, nil
+		}
+	}
+}
+
+type dueWebhookDelivery struct {
+	id            int64
+	nextAttemptAt string
+}
+
+func (a *App) dueWebhookDeliveries(ctx context.Context, orgID int64, now time.Time) ([]dueWebhookDelivery, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT d.id, d.next_attempt_at FROM webhook_deliveries AS d JOIN webhooks AS w ON w.id = d.webhook_id
+WHERE d.org_id = ? AND d.status = ? AND d.next_attempt_at <= ? AND w.active = 1
+ORDER BY d.next_attempt_at, d.id LIMIT ?`,
+		orgID, webhookStatusPending, now.UTC().Format(sqliteTimestampLayout), webhookBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []dueWebhookDelivery
+	for rows.Next() {
+		var d dueWebhookDelivery
+		if err := rows.Scan(&d.id, &d.nextAttemptAt); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// claimWebhookDelivery leases a due delivery by moving its next attempt past
+// the request timeout. Only the dispatcher whose update matches the attempt
+// time it read gets to send it.
+func (a *App) claimWebhookDelivery(ctx context.Context, deliveryID int64, nextAttemptAt string, now time.Time) (bool, error) {
+	res, err := a.db.ExecContext(ctx, `UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at = ?`,
+		now.Add(webhookLease).UTC().Format(sqliteTimestampLayout),
+		deliveryID,
+		webhookStatusPending,
+		nextAttemptAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// attemptWebhookDelivery sends a claimed delivery and records the outcome.
+// Failures are retried with exponential backoff until webhookMaxAttempts.
+func (a *App) attemptWebhookDelivery(ctx context.Context, deliveryID int64, now time.Time) error {
+	var event, payload, target, secret string
+	var attempts int
+	err := a.db.QueryRowContext(ctx, `SELECT d.event, d.payload, d.attempts, w.url, w.secret
+FROM webhook_deliveries AS d JOIN webhooks AS w ON w.id = d.webhook_id WHERE d.id = ?`, deliveryID).Scan(&event, &payload, &attempts, &target, &secret)
+	if err != nil {
+		return err
+	}
+
+	statusCode, sendErr := sendWebhook(ctx, a.webhookClient, target, secret, event, deliveryID, []byte(payload), now)
+	if ctx.Err() != nil {
+		// Shutting down; the lease expires and the delivery is retried.
+		return ctx.Err()
+	}
+	attempts++
+	var code interface{}
+	if statusCode != 0 {
+		code = statusCode
+	}
+	if sendErr == nil {
+		_, err := a.db.ExecContext(ctx, `UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status_code = ?, last_error = '', delivered_at = ? WHERE id = ?`,
+			webhookStatusDelivered, attempts, code, now.UTC().Format(sqliteTimestampLayout), deliveryID)
+		return err
+	}
+
+	status := webhookStatusPending
+	if attempts >= webhookMaxAttempts {
+		status = webhookStatusFailed
+	}
+	message := sendErr.Error()
+	if len(message) > maxWebhookErrorLength {
+		message = message[:maxWebhookErrorLength]
+	}
+	_, err = a.db.ExecContext(ctx, `UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ? WHERE id = ?`,
+		status,
+		attempts,
+		now.Add(webhookBackoff(attempts)).UTC().Format(sqliteTimestampLayout),
+		code,
+		message,
+		deliveryID,
+	)
+	return err
+}
+
+// sendWebhook POSTs a signed delivery and returns the response status. Any
+// status outside 2xx is an error.
+func sendWebhook(ctx context.Context, client *http.Client, target, secret, event string, deliveryID int64, body []byte, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "assetlog-webhooks")
+	req.Header.Set("X-Assetlog-Event", event)
+	req.Header.Set("X-Assetlog-Delivery", strconv.FormatInt(deliveryID, 10))
+	req.Header.Set("X-Assetlog-Timestamp", timestamp)
+	req.Header.Set("X-Assetlog-Signature", signWebhookPayload(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func decodeWebhookPayload(r *http.Request) (WebhookPayload, error) {
+	defer func() {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	var payload WebhookPayload
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxAssetPayloadSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&payload); err != nil {
+		return WebhookPayload{}, validationError{message: "invalid JSON payload: " + err.Error()}
+	}
+	return payload, nil
+}
+
+// handleWebhooks serves /webhooks.
+func (a *App) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		hooks, err := a.listWebhooks(r.Context(), orgID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": hooks, "meta": map[string]interface{}{"events": webhookEvents}})
+	case http.MethodPost:
+		payload, err := decodeWebhookPayload(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		hook, err := a.createWebhook(r.Context(), orgID, payload)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"data": hook})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWebhook serves /webhooks/{id}, /webhooks/{id}/deliveries and
+// /webhooks/{id}/deliveries/{id}/retry.
+func (a *App) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	orgID, err := resolveOrgIDFromRequest(r)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/webhooks/"), "/"), "/")
+	webhookID, err := strconv.ParseInt(segments[0], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid webhook id", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(segments) == 1:
+		a.handleWebhookItem(w, r, orgID, webhookID)
+	case len(segments) == 2 && segments[1] == "deliveries":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		status := strings.ToLower(strings.TrimSpace(query.Get("status")))
+		if status != "" && status != webhookStatusPending && status != webhookStatusDelivered && status != webhookStatusFailed {
+			http.Error(w, "status must be pending, delivered or failed", http.StatusBadRequest)
+			return
+		}
+		limit := defaultWebhookDeliveryLimit
+		if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+			limit, err = strconv.Atoi(raw)
+			if err != nil || limit < 1 || limit > maxWebhookDeliveryLimit {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxWebhookDeliveryLimit), http.StatusBadRequest)
+				return
+			}
+		}
+		deliveries, err := a.listWebhookDeliveries(r.Context(), orgID, webhookID, status, limit)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": deliveries, "meta": map[string]interface{}{"limit": limit}})
+	case len(segments) == 4 && segments[1] == "deliveries" && segments[3] == "retry":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		deliveryID, err := strconv.ParseInt(segments[2], 10, 64)
+		if err != nil {
+			http.Error(w, "invalid delivery id", http.StatusBadRequest)
+			return
+		}
+		delivery, err := a.retryWebhookDelivery(r.Context(), orgID, webhookID, deliveryID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": delivery})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *App) handleWebhookItem(w http.ResponseWriter, r *http.Request, orgID, webhookID int64) {
+	switch r.Method {
+	case http.MethodGet:
+		hook, err := a.getWebhook(r.Context(), orgID, webhookID)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": hook})
+	case http.MethodPut:
+		payload, err := decodeWebhookPayload(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		hook, err := a.updateWebhook(r.Context(), orgID, webhookID, payload)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": hook})
+	case http.MethodDelete:
+		if err := a.deleteWebhook(r.Context(), orgID, webhookID); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
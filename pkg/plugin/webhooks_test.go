@@ -0,0 +1,242 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver answers each request with the next of statuses, repeating
+// the last one.
+func webhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []receivedWebhook) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []receivedWebhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, receivedWebhook{header: r.Header.Clone(), body: body})
+		w.WriteHeader(statuses[min(len(received), len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []receivedWebhook {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedWebhook(nil), received...)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	app := newTestApp(t)
+	// The receivers listen on loopback.
+	app.config.WebhookAllowedNetworks = parseNetworks([]string{"127.0.0.0/8", "::1"})
+	ctx := context.Background()
+	server, received := webhookReceiver(t, http.StatusInternalServerError, http.StatusOK)
+
+	var hook Webhook
+	resp := callResource(t, app, &backend.CallResourceRequest{
+		Method: http.MethodPost, Path: "webhooks",
+		Body: []byte(`{"url":"` + server.URL + `/hook","events":["asset.created","FILE.DELETED","asset.created"]}`),
+	})
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("create webhook: %d %s", resp.Status, resp.Body)
+	}
+	decodeData(t, resp, &hook)
+	if hook.Secret == "" || !hook.Active || len(hook.Events) != 2 || hook.Events[1] != webhookEventFileDeleted {
+		t.Fatalf("unexpected webhook: %+v", hook)
+	}
+	var fetched Webhook
+	hookPath := "webhooks/" + strconv.FormatInt(hook.ID, 10)
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: hookPath}), &fetched)
+	if fetched.Secret != "" {
+		t.Fatal("expected the secret to be returned only on create")
+	}
+	for _, body := range []string{
+		`{"url":"ftp://example.com","events":["asset.created"]}`,
+		`{"url":"/relative","events":["asset.created"]}`,
+		`{"url":"https://example.com","events":[]}`,
+		`{"url":"https://example.com","events":["asset.renamed"]}`,
+		`{"url":"https://example.com","events":["asset.created"],"secret":"short"}`,
+	} {
+		if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "webhooks", Body: []byte(body)}); resp.Status != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, resp.Status)
+		}
+	}
+
+	// Only subscribed events of the webhook's own org are queued.
+	var asset AssetRecord
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "assets", Body: []byte(batchTestAsset)}), &asset)
+	if resp := callAssetPatch(t, app, "assets/"+strconv.FormatInt(asset.ID, 10), "", `{"title":"Renamed"}`); resp.Status != http.StatusOK {
+		t.Fatalf("patch asset: %d %s", resp.Status, resp.Body)
+	}
+	callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: "assets", Body: []byte(batchTestAsset), PluginContext: backend.PluginContext{OrgID: 2}})
+
+	now := time.Now()
+	if attempted, err := app.dispatchWebhookDeliveries(ctx, 1, now); err != nil || attempted != 1 {
+		t.Fatalf("expected one attempt, got %d (%v)", attempted, err)
+	}
+	deliveries, err := app.listWebhookDeliveries(ctx, 1, hook.ID, "", defaultWebhookDeliveryLimit)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("expected one delivery, got %+v (%v)", deliveries, err)
+	}
+	failed := deliveries[0]
+	if failed.Status != webhookStatusPending || failed.Attempts != 1 || *failed.LastStatusCode != http.StatusInternalServerError ||
+		failed.NextAttemptAt != now.Add(webhookRetryBase).UTC().Format(sqliteTimestampLayout) {
+		t.Fatalf("expected a retry to be scheduled, got %+v", failed)
+	}
+
+	if attempted, _ := app.dispatchWebhookDeliveries(ctx, 1, now.Add(time.Second)); attempted != 0 {
+		t.Fatalf("expected the retry to wait for its backoff, got %d attempts", attempted)
+	}
+	if attempted, err := app.dispatchWebhookDeliveries(ctx, 1, now.Add(webhookRetryBase+time.Second)); err != nil || attempted != 1 {
+		t.Fatalf("expected the retry to be sent, got %d (%v)", attempted, err)
+	}
+
+	requests := received()
+	if len(requests) != 2 {
+		t.Fatalf("expected two requests, got %d", len(requests))
+	}
+	last := requests[1]
+	if got := last.header.Get("X-Assetlog-Signature"); got != signWebhookPayload(hook.Secret, last.header.Get("X-Assetlog-Timestamp"), last.body) {
+		t.Fatalf("signature %s does not match the body", got)
+	}
+	if last.header.Get("X-Assetlog-Event") != webhookEventAssetCreated || last.header.Get("X-Assetlog-Delivery") != strconv.FormatInt(failed.ID, 10) {
+		t.Fatalf("unexpected headers: %v", last.header)
+	}
+	var envelope struct {
+		Event string      `json:"event"`
+		OrgID int64       `json:"org_id"`
+		Data  AssetRecord `json:"data"`
+	}
+	if err := json.Unmarshal(last.body, &envelope); err != nil || envelope.OrgID != 1 || envelope.Data.ID != asset.ID {
+		t.Fatalf("unexpected body %s (%v)", last.body, err)
+	}
+
+	var delivered []WebhookDelivery
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{
+		Method: http.MethodGet, Path: hookPath + "/deliveries", URL: hookPath + "/deliveries?status=delivered",
+	}), &delivered)
+	if len(delivered) != 1 || delivered[0].Attempts != 2 || delivered[0].DeliveredAt == "" || delivered[0].NextAttemptAt != "" {
+		t.Fatalf("expected the delivery to be logged as delivered, got %+v", delivered)
+	}
+
+	// A delivery that keeps failing is given up after webhookMaxAttempts.
+	gone := httptest.NewServer(http.NotFoundHandler())
+	defer gone.Close()
+	if _, err := app.updateWebhook(ctx, 1, hook.ID, WebhookPayload{URL: gone.URL, Events: []string{webhookEventFileDeleted}}); err != nil {
+		t.Fatalf("update webhook: %v", err)
+	}
+	// Deliveries are queued with the change: a rolled back change queues none.
+	err = app.inEventTx(ctx, 1, func(tx *sql.Tx, events *assetEvents) error {
+		if err := events.add(ctx, tx, webhookEventFileDeleted, map[string]int64{"id": 2}); err != nil {
+			return err
+		}
+		return errAssetNotFound
+	})
+	if !errors.Is(err, errAssetNotFound) {
+		t.Fatalf("expected the change to fail, got %v", err)
+	}
+	err = app.inEventTx(ctx, 1, func(tx *sql.Tx, events *assetEvents) error {
+		if err := events.add(ctx, tx, webhookEventFileDeleted, map[string]int64{"id": 1}); err != nil {
+			return err
+		}
+		return events.add(ctx, tx, webhookEventAssetCreated, map[string]int64{"id": 1})
+	})
+	if err != nil {
+		t.Fatalf("queue events: %v", err)
+	}
+	at := now
+	for i := 0; i < webhookMaxAttempts; i++ {
+		at = at.Add(webhookRetryMax + time.Second)
+		if _, err := app.dispatchWebhookDeliveries(ctx, 1, at); err != nil {
+			t.Fatalf("dispatch: %v", err)
+		}
+	}
+	var failures []WebhookDelivery
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{
+		Method: http.MethodGet, Path: hookPath + "/deliveries", URL: hookPath + "/deliveries?status=failed",
+	}), &failures)
+	if len(failures) != 1 || failures[0].Attempts != webhookMaxAttempts || failures[0].Event != webhookEventFileDeleted {
+		t.Fatalf("expected the file.deleted delivery to fail for good, got %+v", failures)
+	}
+
+	var retried WebhookDelivery
+	retryPath := hookPath + "/deliveries/" + strconv.FormatInt(failures[0].ID, 10) + "/retry"
+	decodeData(t, callResource(t, app, &backend.CallResourceRequest{Method: http.MethodPost, Path: retryPath}), &retried)
+	if retried.Status != webhookStatusPending || retried.NextAttemptAt == "" {
+		t.Fatalf("expected the delivery to be queued again, got %+v", retried)
+	}
+
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodGet, Path: hookPath, PluginContext: backend.PluginContext{OrgID: 2}}); resp.Status != http.StatusNotFound {
+		t.Fatalf("expected another org's webhook to 404, got %d", resp.Status)
+	}
+	if resp := callResource(t, app, &backend.CallResourceRequest{Method: http.MethodDelete, Path: hookPath}); resp.Status != http.StatusNoContent {
+		t.Fatalf("delete webhook: %d", resp.Status)
+	}
+	var remaining int
+	if err := app.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_deliveries`).Scan(&remaining); err != nil || remaining != 0 {
+		t.Fatalf("expected deliveries to be removed with the webhook, %d left (%v)", remaining, err)
+	}
+}
+
+func TestWebhookRejectsInternalAddresses(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+
+	for _, target := range []string{
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.20/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+	} {
+		resp := callResource(t, app, &backend.CallResourceRequest{
+			Method: http.MethodPost, Path: "webhooks",
+			Body: []byte(`{"url":"` + target + `","events":["asset.created"]}`),
+		})
+		if resp.Status != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d %s", target, resp.Status, resp.Body)
+		}
+	}
+
+	// Allowed networks open up internal targets.
+	app.config.WebhookAllowedNetworks = parseNetworks([]string{"10.0.0.0/8", "not-a-network"})
+	if _, err := app.createWebhook(ctx, 1, WebhookPayload{URL: "http://10.0.0.1/hook", Events: []string{webhookEventAssetCreated}}); err != nil {
+		t.Fatalf("expected an allowed network to be accepted: %v", err)
+	}
+
+	// Stored URLs are checked again when a delivery connects.
+	server, received := webhookReceiver(t, http.StatusOK)
+	if _, err := sendWebhook(ctx, app.webhookClient, server.URL, "secret", webhookEventAssetCreated, 1, []byte(`{}`), time.Now()); !errors.Is(err, errWebhookAddressBlocked) {
+		t.Fatalf("expected the loopback receiver to be refused, got %v", err)
+	}
+	if len(received()) != 0 {
+		t.Fatal("expected no request to reach the receiver")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	for attempts, expected := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 7: 32 * time.Minute, 8: time.Hour, 20: time.Hour} {
+		if got := webhookBackoff(attempts); got != expected {
+			t.Fatalf("attempt %d: expected %s, got %s", attempts, expected, got)
+		}
+	}
+}
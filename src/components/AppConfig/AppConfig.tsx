@@ -3,14 +3,19 @@ import { lastValueFrom } from 'rxjs';
 import { css } from '@emotion/css';
 import { AppPluginMeta, GrafanaTheme2, PluginConfigPageProps, PluginMeta } from '@grafana/data';
 import { getBackendSrv } from '@grafana/runtime';
-import { Button, Field, FieldSet, Input, SecretInput, useStyles2 } from '@grafana/ui';
+import { Button, Field, FieldSet, Input, SecretInput, TextArea, useStyles2 } from '@grafana/ui';
 import { testIds } from '../testIds';
+import type { AssetValidationRules } from '../../types/assets';
 
 type AppPluginSettings = {
   apiUrl?: string;
   bucketName?: string;
   objectPrefix?: string;
   maxUploadSizeMb?: number;
+  trashRetentionDays?: number;
+  timeZone?: string;
+  validationRules?: AssetValidationRules;
+  webhookAllowedNetworks?: string[];
 };
 
 type PersistedAppSettingsResponse = {
@@ -19,6 +24,10 @@ type PersistedAppSettingsResponse = {
     bucketName?: string;
     objectPrefix?: string;
     maxUploadSizeMb?: number;
+    trashRetentionDays?: number;
+    timeZone?: string;
+    validationRules?: AssetValidationRules;
+    webhookAllowedNetworks?: string[];
   };
   secureJsonFields?: {
     apiKey?: boolean;
@@ -43,6 +52,14 @@ type State = {
   objectPrefix: string;
   // Maximum upload size in megabytes.
   maxUploadSizeMb: string;
+  // Days deleted assets stay in the trash before they are purged.
+  trashRetentionDays: string;
+  // IANA zone for entry dates sent without an offset; empty means UTC.
+  timeZone: string;
+  // Validation rules as JSON; empty keeps the defaults.
+  validationRules: string;
+  // Comma separated networks webhooks may reach despite being internal.
+  webhookAllowedNetworks: string;
   // Raw service account JSON used to access the storage bucket.
   serviceAccount: string;
   // Tells us if the service account JSON is already configured.
@@ -53,6 +70,8 @@ export interface AppConfigProps extends PluginConfigPageProps<AppPluginMeta<AppP
 
 const DEFAULT_MAX_UPLOAD_SIZE_MB = 25;
 const MAX_UPLOAD_SIZE_LIMIT_MB = 5120; // 5 GiB cap to avoid misconfiguration.
+const DEFAULT_TRASH_RETENTION_DAYS = 30;
+const MAX_TRASH_RETENTION_DAYS = 3650;
 
 const AppConfig = ({ plugin }: AppConfigProps) => {
   const s = useStyles2(getStyles);
@@ -67,6 +86,13 @@ const AppConfig = ({ plugin }: AppConfigProps) => {
       jsonData?.maxUploadSizeMb && jsonData.maxUploadSizeMb > 0
         ? String(jsonData.maxUploadSizeMb)
         : String(DEFAULT_MAX_UPLOAD_SIZE_MB),
+    trashRetentionDays:
+      jsonData?.trashRetentionDays && jsonData.trashRetentionDays > 0
+        ? String(jsonData.trashRetentionDays)
+        : String(DEFAULT_TRASH_RETENTION_DAYS),
+    timeZone: jsonData?.timeZone || '',
+    validationRules: jsonData?.validationRules ? JSON.stringify(jsonData.validationRules, null, 2) : '',
+    webhookAllowedNetworks: jsonData?.webhookAllowedNetworks?.join(', ') || '',
     serviceAccount: '',
     isServiceAccountSet: Boolean(secureJsonFields?.gcsServiceAccount),
   });
@@ -106,6 +132,22 @@ const AppConfig = ({ plugin }: AppConfigProps) => {
           ) {
             next.maxUploadSizeMb = String(persisted.maxUploadSizeMb);
           }
+          if (
+            typeof persisted.trashRetentionDays === 'number' &&
+            Number.isFinite(persisted.trashRetentionDays) &&
+            persisted.trashRetentionDays > 0
+          ) {
+            next.trashRetentionDays = String(persisted.trashRetentionDays);
+          }
+          if (typeof persisted.timeZone === 'string') {
+            next.timeZone = persisted.timeZone;
+          }
+          if (persisted.validationRules && typeof persisted.validationRules === 'object') {
+            next.validationRules = JSON.stringify(persisted.validationRules, null, 2);
+          }
+          if (Array.isArray(persisted.webhookAllowedNetworks)) {
+            next.webhookAllowedNetworks = persisted.webhookAllowedNetworks.join(', ');
+          }
 
           const secureFields = response.secureJsonFields ?? {};
           if (typeof secureFields.apiKey === 'boolean') {
@@ -135,12 +177,20 @@ const AppConfig = ({ plugin }: AppConfigProps) => {
     Number.isFinite(parsedMaxUploadSize) &&
     parsedMaxUploadSize > 0 &&
     parsedMaxUploadSize <= MAX_UPLOAD_SIZE_LIMIT_MB;
+  const parsedTrashRetention = Number(state.trashRetentionDays);
+  const isTrashRetentionValid =
+    Number.isFinite(parsedTrashRetention) &&
+    parsedTrashRetention >= 1 &&
+    parsedTrashRetention <= MAX_TRASH_RETENTION_DAYS;
+  const parsedValidationRules = parseValidationRules(state.validationRules);
   const isSubmitDisabled = Boolean(
     !state.apiUrl ||
       (!state.isApiKeySet && !state.apiKey) ||
       !state.bucketName ||
       (!state.isServiceAccountSet && !state.serviceAccount) ||
-      !isUploadSizeValid
+      !isUploadSizeValid ||
+      !isTrashRetentionValid ||
+      parsedValidationRules === null
   );
 
   const onResetApiKey = () =>
@@ -159,7 +209,7 @@ const AppConfig = ({ plugin }: AppConfigProps) => {
 
   const onChange = (event: ChangeEvent<HTMLInputElement>) => {
     const { name, value } = event.target;
-    const nextValue = name === 'maxUploadSizeMb' ? value : value.trim();
+    const nextValue = name === 'maxUploadSizeMb' || name === 'trashRetentionDays' ? value : value.trim();
 
     setState({
       ...state,
@@ -167,6 +217,13 @@ const AppConfig = ({ plugin }: AppConfigProps) => {
     });
   };
 
+  const onChangeValidationRules = (event: ChangeEvent<HTMLTextAreaElement>) => {
+    setState({
+      ...state,
+      validationRules: event.target.value,
+    });
+  };
+
   const onSubmit = () => {
     if (isSubmitDisabled) {
       return;
@@ -192,6 +249,10 @@ const AppConfig = ({ plugin }: AppConfigProps) => {
         bucketName: state.bucketName,
         objectPrefix: state.objectPrefix,
         maxUploadSizeMb: normalizedMaxUploadSizeMb,
+        trashRetentionDays: Math.floor(parsedTrashRetention),
+        timeZone: state.timeZone || undefined,
+        validationRules: parsedValidationRules ?? undefined,
+        webhookAllowedNetworks: parseNetworkList(state.webhookAllowedNetworks),
       },
       // These secrets cannot be queried later by the frontend.
       // We don't want to override them in case they were set previously and left untouched now.
@@ -279,6 +340,74 @@ const AppConfig = ({ plugin }: AppConfigProps) => {
           />
         </Field>
 
+        <Field
+          label="Trash retention (days)"
+          description="Deleted entries and their attachments are purged after this many days"
+          className={s.marginTop}
+        >
+          <Input
+            width={20}
+            name="trashRetentionDays"
+            id="config-trash-retention"
+            data-testid={testIds.appConfig.trashRetention}
+            value={state.trashRetentionDays}
+            type="number"
+            min={1}
+            max={MAX_TRASH_RETENTION_DAYS}
+            onChange={onChange}
+          />
+        </Field>
+
+        <Field
+          label="Time zone"
+          description="IANA time zone, e.g. Europe/Berlin, for entry dates given without an offset. Defaults to UTC"
+          className={s.marginTop}
+        >
+          <Input
+            width={30}
+            name="timeZone"
+            id="config-time-zone"
+            data-testid={testIds.appConfig.timeZone}
+            value={state.timeZone}
+            placeholder="UTC"
+            onChange={onChange}
+          />
+        </Field>
+
+        <Field
+          label="Validation rules"
+          description="JSON with dateOrder, notInFuture, patterns, maxPitch, maxRoll and requiredAttachments. Leave empty for the defaults"
+          invalid={parsedValidationRules === null}
+          error="Validation rules must be a JSON object"
+          className={s.marginTop}
+        >
+          <TextArea
+            name="validationRules"
+            id="config-validation-rules"
+            data-testid={testIds.appConfig.validationRules}
+            value={state.validationRules}
+            rows={8}
+            placeholder={'{"dateOrder": [{"before": "start_date", "after": "end_date"}]}'}
+            onChange={onChangeValidationRules}
+          />
+        </Field>
+
+        <Field
+          label="Webhook allowed networks"
+          description="Comma separated CIDRs, e.g. 10.0.0.0/8, that webhooks may reach. Loopback, link-local and private addresses are refused otherwise"
+          className={s.marginTop}
+        >
+          <Input
+            width={60}
+            name="webhookAllowedNetworks"
+            id="config-webhook-allowed-networks"
+            data-testid={testIds.appConfig.webhookAllowedNetworks}
+            value={state.webhookAllowedNetworks}
+            placeholder="None"
+            onChange={onChange}
+          />
+        </Field>
+
         <Field
           label="Service account JSON"
           description="Paste a Google Cloud service account JSON with storage access"
@@ -318,6 +447,30 @@ const getStyles = (theme: GrafanaTheme2) => ({
   `,
 });
 
+// parseValidationRules returns undefined for an empty value and null when the
+// text is not a JSON object.
+const parseValidationRules = (value: string): AssetValidationRules | undefined | null => {
+  if (value.trim() === '') {
+    return undefined;
+  }
+  try {
+    const parsed = JSON.parse(value);
+    return parsed && typeof parsed === 'object' && !Array.isArray(parsed) ? parsed : null;
+  } catch {
+    return null;
+  }
+};
+
+// parseNetworkList splits a comma separated list, returning undefined when it
+// is empty.
+const parseNetworkList = (value: string): string[] | undefined => {
+  const networks = value
+    .split(',')
+    .map((network) => network.trim())
+    .filter(Boolean);
+  return networks.length ? networks : undefined;
+};
+
 const updatePluginAndReload = async (pluginId: string, data: Partial<PluginMeta<AppPluginSettings>>) => {
   try {
     await updatePlugin(pluginId, data);
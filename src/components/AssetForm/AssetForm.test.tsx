@@ -106,6 +106,8 @@ describe('AssetForm', () => {
       attachments: [],
       created_at: '2024-01-01T00:00:00Z',
       updated_at: '2024-01-01T00:00:00Z',
+      custom_fields: {},
+      version: 1,
     };
 
     render(
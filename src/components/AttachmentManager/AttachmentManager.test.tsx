@@ -23,6 +23,8 @@ describe('AttachmentManager', () => {
     image_urls: [],
     created_at: '2024-01-01T00:00:00Z',
     updated_at: '2024-01-01T00:00:00Z',
+    custom_fields: {},
+    version: 1,
   };
 
   it('renders notice when storage is not configured', () => {
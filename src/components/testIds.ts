@@ -5,6 +5,10 @@ export const testIds = {
     bucketName: 'data-testid ac-bucket-name',
     objectPrefix: 'data-testid ac-object-prefix',
     maxUploadSize: 'data-testid ac-max-upload-size',
+    trashRetention: 'data-testid ac-trash-retention',
+    timeZone: 'data-testid ac-time-zone',
+    validationRules: 'data-testid ac-validation-rules',
+    webhookAllowedNetworks: 'data-testid ac-webhook-allowed-networks',
     serviceAccount: 'data-testid ac-service-account',
     submit: 'data-testid ac-submit-form',
   },
@@ -0,0 +1,117 @@
+import React, { type ChangeEvent } from 'react';
+import type { QueryEditorProps, SelectableValue } from '@grafana/data';
+import { InlineField, InlineFieldRow, InlineSwitch, Input, Select } from '@grafana/ui';
+
+import type { AssetFilterKey, AssetSortDirection, AssetSortKey } from '../../types/assets';
+import type { AssetLogDataSource } from '../datasource';
+import type { AssetQuery, AssetQueryType } from '../types';
+
+type Props = QueryEditorProps<AssetLogDataSource, AssetQuery>;
+
+const QUERY_TYPES: Array<SelectableValue<AssetQueryType>> = [
+  { label: 'Assets', value: 'assets', description: 'One row per asset entry' },
+  { label: 'Annotations', value: 'annotations', description: 'Maintenance windows as region annotations' },
+];
+
+const SORT_OPTIONS: Array<SelectableValue<AssetSortKey>> = [
+  { label: 'Entry date', value: 'entry_date' },
+  { label: 'Commissioning date', value: 'commissioning_date' },
+  { label: 'Title', value: 'title' },
+  { label: 'Station', value: 'station_name' },
+  { label: 'Technician', value: 'technician' },
+  { label: 'Service', value: 'service' },
+  { label: 'Start date', value: 'start_date' },
+  { label: 'End date', value: 'end_date' },
+];
+
+const DIRECTION_OPTIONS: Array<SelectableValue<AssetSortDirection>> = [
+  { label: 'Descending', value: 'desc' },
+  { label: 'Ascending', value: 'asc' },
+];
+
+const FILTER_FIELDS: Array<{ key: AssetFilterKey; label: string }> = [
+  { key: 'station_name', label: 'Station' },
+  { key: 'technician', label: 'Technician' },
+  { key: 'title', label: 'Title' },
+];
+
+const parseList = (value: string): string[] =>
+  value
+    .split(',')
+    .map((part) => part.trim())
+    .filter((part) => part.length > 0);
+
+export function QueryEditor({ query, onChange, onRunQuery }: Props) {
+  const update = (patch: Partial<AssetQuery>) => {
+    onChange({ ...query, ...patch });
+    onRunQuery();
+  };
+
+  const onFilterChange = (key: AssetFilterKey) => (event: ChangeEvent<HTMLInputElement>) => {
+    const filters = { ...(query.filters ?? {}) };
+    const values = parseList(event.target.value);
+    if (values.length > 0) {
+      filters[key] = values;
+    } else {
+      delete filters[key];
+    }
+    update({ filters });
+  };
+
+  return (
+    <>
+      <InlineFieldRow>
+        <InlineField label="Query type" labelWidth={14}>
+          <Select
+            width={20}
+            options={QUERY_TYPES}
+            value={query.queryType ?? 'assets'}
+            onChange={(option) => update({ queryType: option.value })}
+          />
+        </InlineField>
+        <InlineField label="Sort" labelWidth={8}>
+          <Select
+            width={22}
+            options={SORT_OPTIONS}
+            value={query.sortKey}
+            onChange={(option) => update({ sortKey: option.value })}
+          />
+        </InlineField>
+        <InlineField>
+          <Select
+            width={16}
+            options={DIRECTION_OPTIONS}
+            value={query.sortDirection ?? 'desc'}
+            onChange={(option) => update({ sortDirection: option.value })}
+          />
+        </InlineField>
+        <InlineField label="Limit" labelWidth={8} tooltip="Defaults to 1000, capped at 10000">
+          <Input
+            type="number"
+            width={10}
+            min={1}
+            defaultValue={query.limit}
+            onBlur={(event) => update({ limit: Number(event.currentTarget.value) || undefined })}
+          />
+        </InlineField>
+        <InlineField label="Ignore time range" labelWidth={18}>
+          <InlineSwitch
+            value={query.ignoreTimeRange ?? false}
+            onChange={(event) => update({ ignoreTimeRange: event.currentTarget.checked })}
+          />
+        </InlineField>
+      </InlineFieldRow>
+      <InlineFieldRow>
+        {FILTER_FIELDS.map(({ key, label }) => (
+          <InlineField key={key} label={label} labelWidth={14} tooltip="Comma-separated values to include">
+            <Input
+              width={24}
+              defaultValue={(query.filters?.[key] ?? []).join(', ')}
+              onBlur={onFilterChange(key)}
+            />
+          </InlineField>
+        ))}
+      </InlineFieldRow>
+    </>
+  );
+}
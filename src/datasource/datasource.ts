@@ -0,0 +1,22 @@
+import type { CoreApp, DataSourceInstanceSettings } from '@grafana/data';
+import { DataSourceWithBackend } from '@grafana/runtime';
+
+import { DEFAULT_QUERY, type AssetQuery } from './types';
+
+export class AssetLogDataSource extends DataSourceWithBackend<AssetQuery> {
+  constructor(instanceSettings: DataSourceInstanceSettings) {
+    super(instanceSettings);
+    // Annotation queries are answered by the backend with queryType "annotations".
+    this.annotations = {
+      getDefaultQuery: () => ({ queryType: 'annotations' }),
+    };
+  }
+
+  getDefaultQuery(_: CoreApp): Partial<AssetQuery> {
+    return DEFAULT_QUERY;
+  }
+
+  filterQuery(query: AssetQuery): boolean {
+    return !query.hide;
+  }
+}
@@ -0,0 +1,9 @@
+import { DataSourcePlugin } from '@grafana/data';
+
+import { AssetLogDataSource } from './datasource';
+import { QueryEditor } from './components/QueryEditor';
+import type { AssetQuery } from './types';
+
+export const plugin = new DataSourcePlugin<AssetLogDataSource, AssetQuery>(AssetLogDataSource).setQueryEditor(
+  QueryEditor
+);
@@ -0,0 +1,23 @@
+import type { DataQuery } from '@grafana/schema';
+
+import type { AssetFilterKey, AssetSortDirection, AssetSortKey } from '../types/assets';
+
+export type AssetQueryType = 'assets' | 'annotations';
+
+export interface AssetQuery extends DataQuery {
+  queryType?: AssetQueryType;
+  /** Values to match per column, mirroring the asset-log-table panel filters. */
+  filters?: Partial<Record<AssetFilterKey, string[]>>;
+  sortKey?: AssetSortKey;
+  sortDirection?: AssetSortDirection;
+  /** Maximum number of rows returned. The backend defaults to 1000 and caps at 10000. */
+  limit?: number;
+  /** Return every matching entry instead of those overlapping the dashboard time range. */
+  ignoreTimeRange?: boolean;
+}
+
+export const DEFAULT_QUERY: Partial<AssetQuery> = {
+  queryType: 'assets',
+  sortKey: 'entry_date',
+  sortDirection: 'desc',
+};
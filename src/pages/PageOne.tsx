@@ -22,6 +22,8 @@ import {
   deleteAsset,
   deleteAttachment,
   fetchAssets,
+  isVersionConflict,
+  subscribeToAssetEvents,
   toErrorMessage,
   updateAsset,
   uploadAttachment,
@@ -101,6 +103,24 @@ function PageOne() {
     };
   }, [page, pageSize, filterKey, sortKey, refreshToken]);
 
+  // Changes made elsewhere update edited rows in place; creations and deletions
+  // reload the page so that sorting and paging stay right.
+  useEffect(
+    () =>
+      subscribeToAssetEvents((event) => {
+        switch (event.event) {
+          case 'asset.updated':
+            setAssets((prev) => prev.map((item) => (item.id === event.data.id ? event.data : item)));
+            break;
+          case 'asset.created':
+          case 'asset.deleted':
+            setRefreshToken((token) => token + 1);
+            break;
+        }
+      }),
+    []
+  );
+
   const openCreate = () => {
     setModalState({ mode: 'create' });
     setFormError(null);
@@ -126,7 +146,7 @@ function PageOne() {
 
     try {
       if (modalState.mode === 'edit' && modalState.asset) {
-        const updated = await updateAsset(modalState.asset.id, payload);
+        const updated = await updateAsset(modalState.asset.id, payload, modalState.asset.version);
         setAssets((prev) => prev.map((item) => (item.id === updated.id ? updated : item)));
         setStatus({ severity: 'success', message: `Updated asset "${updated.title}".` });
       } else {
@@ -137,7 +157,18 @@ function PageOne() {
       setModalState(null);
       setRefreshToken((token) => token + 1);
     } catch (err) {
-      setFormError(toErrorMessage(err));
+      if (isVersionConflict(err) && modalState.asset) {
+        // Keep the form values but target the latest version, so saving again
+        // is a deliberate overwrite.
+        const current = err.data.data;
+        setModalState({ mode: 'edit', asset: current });
+        setAssets((prev) => prev.map((item) => (item.id === current.id ? current : item)));
+        setFormError(
+          `"${current.title}" was changed by someone else since you opened it. Review the latest values and save again to overwrite them.`
+        );
+      } else {
+        setFormError(toErrorMessage(err));
+      }
     } finally {
       setIsSubmitting(false);
     }
@@ -161,7 +192,7 @@ function PageOne() {
     setDeleteLoading(true);
     setDeleteError(null);
     try {
-      await deleteAsset(deleteState.id);
+      await deleteAsset(deleteState.id, deleteState.version);
       const currentPage = page;
       if (assets.length === 1 && currentPage > 1) {
         setPage(currentPage - 1);
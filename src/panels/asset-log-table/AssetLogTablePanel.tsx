@@ -15,7 +15,7 @@ import type {
   AssetSortKey,
 } from '../../types/assets';
 import { EMPTY_FILTER_VALUE } from '../../types/assets';
-import { fetchAssets, toErrorMessage } from '../../utils/assetsApi';
+import { fetchAssets, subscribeToAssetEvents, toErrorMessage } from '../../utils/assetsApi';
 import type { AssetListQuery } from '../../utils/assetsApi';
 import { AssetTable } from '../../components/AssetTable';
 import type { AssetLogTableOptions } from './types';
@@ -77,6 +77,22 @@ export const AssetLogTablePanel: React.FC<PanelProps<AssetLogTableOptions>> = ({
     };
   }, [filters, sort, maxItems, manualReload, requestId]);
 
+  useEffect(
+    () =>
+      subscribeToAssetEvents((event) => {
+        switch (event.event) {
+          case 'asset.updated':
+            setAssets((prev) => prev.map((item) => (item.id === event.data.id ? event.data : item)));
+            break;
+          case 'asset.created':
+          case 'asset.deleted':
+            setManualReload((value) => value + 1);
+            break;
+        }
+      }),
+    []
+  );
+
   const visibleAssets = useMemo(() => {
     if (!maxItems) {
       return assets;
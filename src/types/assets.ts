@@ -14,6 +14,10 @@ export interface AssetRecord {
   entry_date: string;
   commissioning_date: string;
   station_name: string;
+  // Registered station the asset belongs to; station_name mirrors its code.
+  station_id?: number;
+  // Asset this one is a sub-component of; absent for roots.
+  parent_id?: number;
   technician: string;
   start_date: string;
   end_date: string;
@@ -24,9 +28,14 @@ export interface AssetRecord {
   pitch: number;
   roll: number;
   attachments: AssetFile[];
+  tags: string[];
   image_urls?: string[];
   created_at: string;
   updated_at: string;
+  // Values of the org's custom fields, keyed by field name.
+  custom_fields: Record<string, CustomFieldValue>;
+  // Row version, sent back as If-Match to detect concurrent edits.
+  version: number;
 }
 
 export interface AssetPayload {
@@ -34,6 +43,8 @@ export interface AssetPayload {
   entry_date: string;
   commissioning_date: string;
   station_name: string;
+  // Takes precedence over station_name; unknown names register a station.
+  station_id?: number;
   technician: string;
   start_date: string;
   end_date: string;
@@ -43,6 +54,191 @@ export interface AssetPayload {
   longitude: number;
   pitch: number;
   roll: number;
+  // Omit to keep the stored values on update.
+  custom_fields?: Record<string, CustomFieldValue>;
+  // Tag names, registered on first use; omit to keep the stored tags.
+  tags?: string[];
+  // 0 makes the asset a root; omit to keep the stored parent.
+  parent_id?: number;
+}
+
+// An asset in a subtree or ancestor listing.
+export interface AssetTreeNode {
+  id: number;
+  title: string;
+  station_name: string;
+  service?: string;
+  parent_id?: number;
+  depth: number;
+  children?: AssetTreeNode[];
+}
+
+export type CustomFieldValue = string | number | boolean;
+
+export type CustomFieldType = 'text' | 'number' | 'date' | 'enum' | 'bool';
+
+export interface CustomFieldDefinition {
+  name: string;
+  type: CustomFieldType;
+  required: boolean;
+  allowed_values: string[];
+  created_at?: string;
+  updated_at?: string;
+}
+
+// One violation in a 400 response; field is e.g. "end_date" or
+// "custom_fields.<name>".
+export interface AssetFieldError {
+  field: string;
+  rule: string;
+  message: string;
+}
+
+// An org's cross-field checks on asset writes, kept in the app settings.
+export interface AssetValidationRules {
+  dateOrder?: Array<{ before: string; after: string }>;
+  notInFuture?: string[];
+  patterns?: Array<{ field: string; pattern: string; message?: string }>;
+  maxPitch?: number;
+  maxRoll?: number;
+  requiredAttachments?: Array<{ service: string; minFiles: number }>;
+}
+
+// A stored date the RFC 3339 migration could not convert.
+export interface AssetDateIssue {
+  asset_id: number;
+  title: string;
+  field: 'entry_date' | 'commissioning_date' | 'start_date' | 'end_date';
+  value: string;
+}
+
+export interface Station {
+  id: number;
+  code: string;
+  name: string;
+  latitude: number | null;
+  longitude: number | null;
+  type: string;
+  owner: string;
+  active: boolean;
+  asset_count: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface StationPayload {
+  code: string;
+  name?: string;
+  latitude?: number | null;
+  longitude?: number | null;
+  type?: string;
+  owner?: string;
+  active?: boolean;
+}
+
+export type ScheduleIntervalUnit = 'days' | 'weeks' | 'months' | 'years' | 'hours';
+
+// A recurring maintenance task on a station or an asset and its
+// sub-components. The due fields are computed from the latest entry whose
+// service matches task_type.
+export interface Schedule {
+  id: number;
+  name: string;
+  station_id?: number;
+  asset_id?: number;
+  // Station code or asset title.
+  target: string;
+  task_type: string;
+  interval: number;
+  interval_unit: ScheduleIntervalUnit;
+  // Number custom field holding operating-hour readings; hours schedules only.
+  meter_field?: string;
+  anchor_date: string;
+  last_done?: string;
+  last_asset_id?: number;
+  // Absent while an hours schedule's usage cannot be projected.
+  next_due?: string;
+  days_until_due?: number;
+  overdue: boolean;
+  meter_hours?: number;
+  next_due_hours?: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface SchedulePayload {
+  name?: string;
+  station_id?: number;
+  asset_id?: number;
+  task_type: string;
+  interval: number;
+  interval_unit: ScheduleIntervalUnit;
+  meter_field?: string;
+  anchor_date: string;
+}
+
+export type WebhookEvent = 'asset.created' | 'asset.updated' | 'asset.deleted' | 'file.uploaded' | 'file.deleted';
+
+export interface Webhook {
+  id: number;
+  url: string;
+  events: WebhookEvent[];
+  active: boolean;
+  // Only returned when the webhook is created.
+  secret?: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface WebhookPayload {
+  url: string;
+  // Generated when omitted on create; omitted on update keeps the current one.
+  secret?: string;
+  events: WebhookEvent[];
+  active?: boolean;
+}
+
+// AssetEvent is sent to webhooks and pushed to the org's Grafana Live channel.
+export type AssetEvent = { org_id: number; occurred_at: string } & (
+  | { event: 'asset.created' | 'asset.updated'; data: AssetRecord }
+  | { event: 'asset.deleted'; data: { id: number } }
+  | { event: 'file.uploaded'; data: AssetFile }
+  | { event: 'file.deleted'; data: { id: number; asset_id: number } }
+);
+
+export type WebhookDeliveryStatus = 'pending' | 'delivered' | 'failed';
+
+export interface WebhookDelivery {
+  id: number;
+  webhook_id: number;
+  event: WebhookEvent;
+  payload: AssetEvent;
+  status: WebhookDeliveryStatus;
+  attempts: number;
+  next_attempt_at?: string;
+  last_status_code?: number;
+  last_error?: string;
+  created_at: string;
+  delivered_at?: string;
+}
+
+export interface Person {
+  id: number;
+  name: string;
+  organization_id?: number;
+  organization_name?: string;
+  asset_count: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface Organization {
+  id: number;
+  name: string;
+  people_count: number;
+  asset_count: number;
+  created_at: string;
+  updated_at: string;
 }
 
 export type AssetFilterKey =
@@ -51,7 +247,10 @@ export type AssetFilterKey =
   | 'commissioning_date'
   | 'station_name'
   | 'technician'
-  | 'service';
+  | 'service'
+  // Assets with any of the tags; tag[all] requires every one.
+  | 'tag'
+  | 'tag[all]';
 
 export type AssetFilterValue = string[];
 
@@ -80,11 +279,35 @@ export interface AssetListMeta {
   storageConfigured: boolean;
   maxUploadSizeBytes: number;
   maxUploadSizeMb: number;
-  page: number;
+  // Unset when the list was read at a cursor.
+  page?: number;
   pageSize: number;
-  pageCount: number;
-  totalCount: number;
+  // Unset when the matches were not counted, by default for cursor reads.
+  pageCount?: number;
+  totalCount?: number;
+  // Cursors of the pages after and before this one, unset at either end.
+  nextCursor?: string;
+  prevCursor?: string;
   filters: AssetListFilters;
   storageError?: string;
   sort?: AssetListSort | null;
+  // Tags over all assets matching the filters, most used first.
+  tagCounts?: AssetTagCount[];
+  includeDescendants?: boolean;
+}
+
+export interface Tag {
+  id: number;
+  name: string;
+  color: string;
+  asset_count: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface AssetTagCount {
+  id: number;
+  name: string;
+  color: string;
+  count: number;
 }
@@ -1,5 +1,8 @@
-import { getBackendSrv, isFetchError } from '@grafana/runtime';
+import { isLiveChannelMessageEvent, LiveChannelScope } from '@grafana/data';
+import { config, getBackendSrv, getGrafanaLiveSrv, isFetchError } from '@grafana/runtime';
 import type {
+  AssetDateIssue,
+  AssetEvent,
   AssetFile,
   AssetFilterKey,
   AssetListFilters,
@@ -7,11 +10,31 @@ import type {
   AssetListSort,
   AssetPayload,
   AssetRecord,
+  AssetTreeNode,
+  CustomFieldDefinition,
+  Organization,
+  Person,
+  Schedule,
+  SchedulePayload,
+  Station,
+  StationPayload,
+  Tag,
+  Webhook,
+  WebhookDelivery,
+  WebhookDeliveryStatus,
+  WebhookPayload,
 } from '../types/assets';
 import { EMPTY_FILTER_VALUE } from '../types/assets';
 
 const PLUGIN_ID = 'rpatt-assetlog-app';
 const BASE_URL = `/api/plugins/${PLUGIN_ID}/resources/assets`;
+const CUSTOM_FIELDS_URL = `/api/plugins/${PLUGIN_ID}/resources/custom-fields`;
+const STATIONS_URL = `/api/plugins/${PLUGIN_ID}/resources/stations`;
+const PEOPLE_URL = `/api/plugins/${PLUGIN_ID}/resources/people`;
+const ORGANIZATIONS_URL = `/api/plugins/${PLUGIN_ID}/resources/organizations`;
+const TAGS_URL = `/api/plugins/${PLUGIN_ID}/resources/tags`;
+const SCHEDULES_URL = `/api/plugins/${PLUGIN_ID}/resources/schedules`;
+const WEBHOOKS_URL = `/api/plugins/${PLUGIN_ID}/resources/webhooks`;
 
 interface ListResponse {
   data: AssetRecord[];
@@ -32,6 +55,12 @@ export interface AssetListQuery {
   pageSize?: number;
   filters?: AssetListFilters;
   sort?: AssetListSort | null;
+  // Widens the filters to the descendants of the matched assets.
+  includeDescendants?: boolean;
+  // Reads the page after or before a row instead of by page number.
+  cursor?: string;
+  // Whether to count the matches; cursor reads skip it unless set.
+  count?: boolean;
 }
 
 export async function fetchAssets(query?: AssetListQuery): Promise<AssetListResult> {
@@ -59,15 +88,302 @@ export async function createAsset(payload: AssetPayload): Promise<AssetRecord> {
   return response.data;
 }
 
-export async function updateAsset(assetId: number, payload: AssetPayload): Promise<AssetRecord> {
+export async function updateAsset(assetId: number, payload: AssetPayload, version?: number): Promise<AssetRecord> {
   const backend = getBackendOrThrow();
-  const response = await backend.put<ItemResponse<AssetRecord>>(`${BASE_URL}/${assetId}`, payload, { showErrorAlert: false });
+  const response = await backend.put<ItemResponse<AssetRecord>>(`${BASE_URL}/${assetId}`, payload, {
+    showErrorAlert: false,
+    headers: ifMatchHeaders(version),
+  });
+  return response.data;
+}
+
+// patchAsset applies a JSON merge patch: only the given fields change and null
+// clears an optional field.
+export async function patchAsset(
+  assetId: number,
+  patch: Partial<Record<keyof AssetPayload, unknown>>,
+  version?: number
+): Promise<AssetRecord> {
+  const backend = getBackendOrThrow();
+  const response = await backend.patch<ItemResponse<AssetRecord>>(`${BASE_URL}/${assetId}`, patch, {
+    showErrorAlert: false,
+    headers: { ...ifMatchHeaders(version), 'Content-Type': 'application/merge-patch+json' },
+  });
+  return response.data;
+}
+
+export async function deleteAsset(assetId: number, version?: number): Promise<void> {
+  const backend = getBackendOrThrow();
+  await backend.delete(`${BASE_URL}/${assetId}`, undefined, { showErrorAlert: false, headers: ifMatchHeaders(version) });
+}
+
+// isVersionConflict reports whether a mutation failed because the asset was
+// changed by someone else; the error data then holds the current record.
+export function isVersionConflict(error: unknown): error is { status: 412; data: { data: AssetRecord } } {
+  return isFetchError(error) && error.status === 412;
+}
+
+// fetchSubtree returns an asset with its descendants nested as children.
+export async function fetchSubtree(assetId: number, depth?: number): Promise<AssetTreeNode> {
+  const backend = getBackendOrThrow();
+  const params = depth !== undefined ? { depth } : undefined;
+  const response = await backend.get<ItemResponse<AssetTreeNode>>(`${BASE_URL}/${assetId}/subtree`, params, undefined, {
+    showErrorAlert: false,
+  });
+  return response.data;
+}
+
+// fetchAncestors returns the parents of an asset, root first.
+export async function fetchAncestors(assetId: number): Promise<AssetTreeNode[]> {
+  const backend = getBackendOrThrow();
+  const response = await backend.get<ItemResponse<AssetTreeNode[]>>(`${BASE_URL}/${assetId}/ancestors`, undefined, undefined, {
+    showErrorAlert: false,
+  });
+  return response.data ?? [];
+}
+
+// moveAsset nests an asset under another one, or makes it a root for null.
+export async function moveAsset(assetId: number, parentId: number | null, version?: number): Promise<AssetRecord> {
+  const backend = getBackendOrThrow();
+  const response = await backend.post<ItemResponse<AssetRecord>>(
+    `${BASE_URL}/${assetId}/move`,
+    { parent_id: parentId },
+    { showErrorAlert: false, headers: ifMatchHeaders(version) }
+  );
+  return response.data;
+}
+
+function ifMatchHeaders(version?: number): Record<string, string> | undefined {
+  return version ? { 'If-Match': `"${version}"` } : undefined;
+}
+
+export async function fetchCustomFields(): Promise<CustomFieldDefinition[]> {
+  const backend = getBackendOrThrow();
+  const response = await backend.get<ItemResponse<CustomFieldDefinition[]>>(CUSTOM_FIELDS_URL);
+  return response.data;
+}
+
+export async function createCustomField(definition: CustomFieldDefinition): Promise<CustomFieldDefinition> {
+  const backend = getBackendOrThrow();
+  const response = await backend.post<ItemResponse<CustomFieldDefinition>>(CUSTOM_FIELDS_URL, definition, {
+    showErrorAlert: false,
+  });
+  return response.data;
+}
+
+export async function updateCustomField(definition: CustomFieldDefinition): Promise<CustomFieldDefinition> {
+  const backend = getBackendOrThrow();
+  const response = await backend.put<ItemResponse<CustomFieldDefinition>>(
+    `${CUSTOM_FIELDS_URL}/${encodeURIComponent(definition.name)}`,
+    definition,
+    { showErrorAlert: false }
+  );
+  return response.data;
+}
+
+export async function deleteCustomField(name: string): Promise<void> {
+  const backend = getBackendOrThrow();
+  await backend.delete(`${CUSTOM_FIELDS_URL}/${encodeURIComponent(name)}`, undefined, { showErrorAlert: false });
+}
+
+export async function fetchDateIssues(): Promise<AssetDateIssue[]> {
+  const backend = getBackendOrThrow();
+  const response = await backend.get<ItemResponse<AssetDateIssue[]>>(`${BASE_URL}/date-issues`);
+  return response.data;
+}
+
+export async function fetchStations(activeOnly = false): Promise<Station[]> {
+  const backend = getBackendOrThrow();
+  const response = await backend.get<ItemResponse<Station[]>>(STATIONS_URL, activeOnly ? { active: 'true' } : undefined);
+  return response.data;
+}
+
+export async function createStation(payload: StationPayload): Promise<Station> {
+  const backend = getBackendOrThrow();
+  const response = await backend.post<ItemResponse<Station>>(STATIONS_URL, payload, { showErrorAlert: false });
+  return response.data;
+}
+
+export async function updateStation(stationId: number, payload: StationPayload): Promise<Station> {
+  const backend = getBackendOrThrow();
+  const response = await backend.put<ItemResponse<Station>>(`${STATIONS_URL}/${stationId}`, payload, {
+    showErrorAlert: false,
+  });
+  return response.data;
+}
+
+export async function deleteStation(stationId: number): Promise<void> {
+  const backend = getBackendOrThrow();
+  await backend.delete(`${STATIONS_URL}/${stationId}`, undefined, { showErrorAlert: false });
+}
+
+export async function searchPeople(query: string, organizationId?: number): Promise<Person[]> {
+  const backend = getBackendOrThrow();
+  const params: Record<string, string> = { q: query };
+  if (organizationId !== undefined) {
+    params.organization_id = String(organizationId);
+  }
+  const response = await backend.get<ItemResponse<Person[]>>(PEOPLE_URL, params);
+  return response.data;
+}
+
+export async function searchOrganizations(query: string): Promise<Organization[]> {
+  const backend = getBackendOrThrow();
+  const response = await backend.get<ItemResponse<Organization[]>>(ORGANIZATIONS_URL, { q: query });
   return response.data;
 }
 
-export async function deleteAsset(assetId: number): Promise<void> {
+export async function fetchTags(): Promise<Tag[]> {
   const backend = getBackendOrThrow();
-  await backend.delete(`${BASE_URL}/${assetId}`, undefined, { showErrorAlert: false });
+  const response = await backend.get<ItemResponse<Tag[]>>(TAGS_URL);
+  return response.data;
+}
+
+export async function createTag(name: string, color?: string): Promise<Tag> {
+  const backend = getBackendOrThrow();
+  const response = await backend.post<ItemResponse<Tag>>(TAGS_URL, { name, color }, { showErrorAlert: false });
+  return response.data;
+}
+
+export async function updateTag(tagId: number, changes: { name?: string; color?: string }): Promise<Tag> {
+  const backend = getBackendOrThrow();
+  const response = await backend.patch<ItemResponse<Tag>>(`${TAGS_URL}/${tagId}`, changes, { showErrorAlert: false });
+  return response.data;
+}
+
+// mergeTag moves the assets of a tag to another one and deletes it.
+export async function mergeTag(tagId: number, intoId: number): Promise<Tag> {
+  const backend = getBackendOrThrow();
+  const response = await backend.post<ItemResponse<Tag>>(`${TAGS_URL}/${tagId}/merge`, { into: intoId }, {
+    showErrorAlert: false,
+  });
+  return response.data;
+}
+
+export async function deleteTag(tagId: number): Promise<void> {
+  const backend = getBackendOrThrow();
+  await backend.delete(`${TAGS_URL}/${tagId}`, undefined, { showErrorAlert: false });
+}
+
+export async function fetchSchedules(): Promise<Schedule[]> {
+  const backend = getBackendOrThrow();
+  const response = await backend.get<ItemResponse<Schedule[]>>(SCHEDULES_URL, undefined, undefined, { showErrorAlert: false });
+  return response.data ?? [];
+}
+
+// fetchDueSchedules lists schedules falling due within a horizon such as 30d
+// or 6w, soonest first. Overdue schedules are listed by fetchOverdueSchedules.
+export async function fetchDueSchedules(within = '30d'): Promise<Schedule[]> {
+  const backend = getBackendOrThrow();
+  const response = await backend.get<ItemResponse<Schedule[]>>(`${SCHEDULES_URL}/due`, { within }, undefined, {
+    showErrorAlert: false,
+  });
+  return response.data ?? [];
+}
+
+export async function fetchOverdueSchedules(): Promise<Schedule[]> {
+  const backend = getBackendOrThrow();
+  const response = await backend.get<ItemResponse<Schedule[]>>(`${SCHEDULES_URL}/overdue`, undefined, undefined, {
+    showErrorAlert: false,
+  });
+  return response.data ?? [];
+}
+
+export async function createSchedule(payload: SchedulePayload): Promise<Schedule> {
+  const backend = getBackendOrThrow();
+  const response = await backend.post<ItemResponse<Schedule>>(SCHEDULES_URL, payload, { showErrorAlert: false });
+  return response.data;
+}
+
+export async function updateSchedule(scheduleId: number, payload: SchedulePayload): Promise<Schedule> {
+  const backend = getBackendOrThrow();
+  const response = await backend.put<ItemResponse<Schedule>>(`${SCHEDULES_URL}/${scheduleId}`, payload, {
+    showErrorAlert: false,
+  });
+  return response.data;
+}
+
+export async function deleteSchedule(scheduleId: number): Promise<void> {
+  const backend = getBackendOrThrow();
+  await backend.delete(`${SCHEDULES_URL}/${scheduleId}`, undefined, { showErrorAlert: false });
+}
+
+export async function fetchWebhooks(): Promise<Webhook[]> {
+  const backend = getBackendOrThrow();
+  const response = await backend.get<ItemResponse<Webhook[]>>(WEBHOOKS_URL, undefined, undefined, { showErrorAlert: false });
+  return response.data ?? [];
+}
+
+// createWebhook returns the webhook with its signing secret, which is not
+// returned again.
+export async function createWebhook(payload: WebhookPayload): Promise<Webhook> {
+  const backend = getBackendOrThrow();
+  const response = await backend.post<ItemResponse<Webhook>>(WEBHOOKS_URL, payload, { showErrorAlert: false });
+  return response.data;
+}
+
+export async function updateWebhook(webhookId: number, payload: WebhookPayload): Promise<Webhook> {
+  const backend = getBackendOrThrow();
+  const response = await backend.put<ItemResponse<Webhook>>(`${WEBHOOKS_URL}/${webhookId}`, payload, {
+    showErrorAlert: false,
+  });
+  return response.data;
+}
+
+export async function deleteWebhook(webhookId: number): Promise<void> {
+  const backend = getBackendOrThrow();
+  await backend.delete(`${WEBHOOKS_URL}/${webhookId}`, undefined, { showErrorAlert: false });
+}
+
+// fetchWebhookDeliveries lists a webhook's latest deliveries, newest first.
+export async function fetchWebhookDeliveries(
+  webhookId: number,
+  status?: WebhookDeliveryStatus,
+  limit?: number
+): Promise<WebhookDelivery[]> {
+  const backend = getBackendOrThrow();
+  const params: Record<string, string | number> = {};
+  if (status) {
+    params.status = status;
+  }
+  if (limit) {
+    params.limit = limit;
+  }
+  const response = await backend.get<ItemResponse<WebhookDelivery[]>>(
+    `${WEBHOOKS_URL}/${webhookId}/deliveries`,
+    params,
+    undefined,
+    { showErrorAlert: false }
+  );
+  return response.data ?? [];
+}
+
+export async function retryWebhookDelivery(webhookId: number, deliveryId: number): Promise<WebhookDelivery> {
+  const backend = getBackendOrThrow();
+  const response = await backend.post<ItemResponse<WebhookDelivery>>(
+    `${WEBHOOKS_URL}/${webhookId}/deliveries/${deliveryId}/retry`,
+    undefined,
+    { showErrorAlert: false }
+  );
+  return response.data;
+}
+
+// subscribeToAssetEvents calls onEvent for every asset and attachment change in
+// the current org, pushed through Grafana Live. It returns the unsubscribe
+// function.
+export function subscribeToAssetEvents(onEvent: (event: AssetEvent) => void): () => void {
+  const subscription = getGrafanaLiveSrv()
+    .getStream<AssetEvent>({
+      scope: LiveChannelScope.Plugin,
+      namespace: PLUGIN_ID,
+      path: `assets/${config.bootData.user.orgId}`,
+    })
+    .subscribe((event) => {
+      if (isLiveChannelMessageEvent(event)) {
+        onEvent(event.message);
+      }
+    });
+  return () => subscription.unsubscribe();
 }
 
 export async function uploadAttachment(assetId: number, file: File, signal?: AbortSignal): Promise<AssetFile> {
@@ -194,6 +510,15 @@ function buildListURL(query?: AssetListQuery): string {
   if (query.sort && query.sort.key && query.sort.direction) {
     params.set('sort', `${query.sort.key}:${query.sort.direction}`);
   }
+  if (query.includeDescendants) {
+    params.set('includeDescendants', 'true');
+  }
+  if (query.cursor) {
+    params.set('cursor', query.cursor);
+  }
+  if (query.count !== undefined) {
+    params.set('count', String(query.count));
+  }
   const queryString = params.toString();
   if (!queryString) {
     return BASE_URL;